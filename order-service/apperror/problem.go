@@ -0,0 +1,60 @@
+package apperror
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body extended with the stable
+// error code and the request id.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Field     string       `json:"field,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+func NewProblem(ctx *kp.Context, err error) Problem {
+	e := From(err)
+	status := e.Status()
+	return Problem{
+		Type:      "urn:problem:" + e.Code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Message,
+		Instance:  strings.SplitN(ctx.URL(), "?", 2)[0],
+		Code:      e.Code,
+		RequestID: ctx.RequestId(),
+		Field:     e.Field,
+		Errors:    e.Fields,
+	}
+}
+
+// Write maps err to its problem response. It is the one place handlers turn
+// errors into HTTP responses.
+func Write(ctx *kp.Context, err error) error {
+	problem := NewProblem(ctx, err)
+
+	if ctx.ResponseWriter != nil {
+		ctx.Header().Set("Content-Type", ContentType)
+		ctx.Header().Set("x-rid", problem.RequestID)
+		ctx.WriteHeader(problem.Status)
+		if err := json.NewEncoder(ctx.ResponseWriter).Encode(problem); err != nil {
+			return err
+		}
+		ctx.Log().Info(logger.NewOutbound("client", ""), problem)
+	}
+	ctx.Log().End(problem.Status, "")
+	return nil
+}
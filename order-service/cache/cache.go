@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+)
+
+// Cache is a byte cache keyed by string with a time to live per entry.
+// Get reports a miss with ok false and a nil error.
+type Cache interface {
+	Name() string
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// New picks the cache backend from CACHE_DRIVER ("memory" or "redis").
+func New(conf *config.Config) (Cache, error) {
+	switch conf.GetOrDefault("CACHE_DRIVER", "memory") {
+	case "memory":
+		size, err := strconv.Atoi(conf.GetOrDefault("CACHE_SIZE", "10000"))
+		if err != nil || size <= 0 {
+			return nil, errors.New("invalid CACHE_SIZE: " + conf.Get("CACHE_SIZE"))
+		}
+		return NewMemory(size), nil
+	case "redis":
+		db, _ := strconv.Atoi(conf.GetOrDefault("REDIS_DB", "0"))
+		return NewRedis(RedisConfig{
+			Addr:     conf.GetOrDefault("REDIS_ADDR", "localhost:6379"),
+			Password: conf.Get("REDIS_PASSWORD"),
+			DB:       db,
+			Prefix:   conf.GetOrDefault("REDIS_PREFIX", "order-service:"),
+		})
+	default:
+		return nil, errors.New("unsupported CACHE_DRIVER: " + conf.Get("CACHE_DRIVER"))
+	}
+}
+
+// TTL reads CACHE_TTL, five minutes by default.
+func TTL(conf *config.Config) time.Duration {
+	ttl, err := time.ParseDuration(conf.GetOrDefault("CACHE_TTL", "5m"))
+	if err != nil || ttl <= 0 {
+		return 5 * time.Minute
+	}
+	return ttl
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		touch   string // read before the third Set, so it is not the oldest
+		evicted string
+		kept    []string
+	}{
+		{name: "oldest goes first", evicted: "a", kept: []string{"b", "c"}},
+		{name: "a read keeps an entry", touch: "a", evicted: "b", kept: []string{"a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemory(2)
+			c.Set(ctx, "a", []byte("1"), 0)
+			c.Set(ctx, "b", []byte("2"), 0)
+			if tt.touch != "" {
+				if _, ok, _ := c.Get(ctx, tt.touch); !ok {
+					t.Fatalf("Get(%q) missed before the cache was full", tt.touch)
+				}
+			}
+			c.Set(ctx, "c", []byte("3"), 0)
+
+			if _, ok, _ := c.Get(ctx, tt.evicted); ok {
+				t.Errorf("Get(%q) hit, want it evicted", tt.evicted)
+			}
+			for _, key := range tt.kept {
+				if _, ok, _ := c.Get(ctx, key); !ok {
+					t.Errorf("Get(%q) missed, want it kept", key)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryOverwriteDoesNotEvict(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemory(2)
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "b", []byte("2"), 0)
+	c.Set(ctx, "a", []byte("3"), 0)
+
+	value, ok, _ := c.Get(ctx, "a")
+	if !ok || string(value) != "3" {
+		t.Errorf("Get(a) = %q, %v, want 3, true", value, ok)
+	}
+	if _, ok, _ := c.Get(ctx, "b"); !ok {
+		t.Error("Get(b) missed after overwriting a")
+	}
+}
+
+func TestMemoryTTL(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		wait time.Duration
+		hit  bool
+	}{
+		{name: "no ttl never expires", ttl: 0, wait: 20 * time.Millisecond, hit: true},
+		{name: "within ttl", ttl: time.Minute, wait: 0, hit: true},
+		{name: "past ttl", ttl: 10 * time.Millisecond, wait: 20 * time.Millisecond, hit: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemory(10)
+			c.Set(ctx, "k", []byte("v"), tt.ttl)
+			time.Sleep(tt.wait)
+			if _, ok, _ := c.Get(ctx, "k"); ok != tt.hit {
+				t.Errorf("Get(k) hit = %v, want %v", ok, tt.hit)
+			}
+		})
+	}
+}
+
+func TestMemoryDelete(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemory(10)
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "b", []byte("2"), 0)
+	c.Delete(ctx, "a", "missing")
+
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Error("Get(a) hit after Delete")
+	}
+	if _, ok, _ := c.Get(ctx, "b"); !ok {
+		t.Error("Get(b) missed, Delete removed too much")
+	}
+}
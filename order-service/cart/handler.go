@@ -0,0 +1,190 @@
+package cart
+
+import (
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/validation"
+)
+
+// customerHeader names the customer whose cart a request works on.
+const customerHeader = "X-Customer-ID"
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// customerID reads the customer the request is for from customerHeader.
+func customerID(ctx *kp.Context) (string, error) {
+	var id string
+	if r, ok := ctx.Request.(interface{ Header(string) string }); ok {
+		id = r.Header(customerHeader)
+	}
+	if err := validation.Var(customerHeader, id, "required"); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// HandleGetCart returns the cart of the customer, priced as of now
+func (h *Handler) HandleGetCart(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "get_cart",
+		Code:        "200",
+		Description: "",
+	}
+	id, err := customerID(ctx)
+	if err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("get cart", ""), map[string]any{
+		"customer_id": id,
+	})
+
+	cart, err := h.service.GetCart(ctx, id)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, cart)
+}
+
+// HandleAddItem adds a product to the cart
+func (h *Handler) HandleAddItem(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "add_cart_item",
+		Code:        "200",
+		Description: "",
+	}
+	id, err := customerID(ctx)
+	if err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var req AddItemRequest
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("add cart item", ""), map[string]any{
+		"customer_id": id,
+		"body":        req,
+	})
+
+	cart, err := h.service.AddItem(ctx, id, req)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, cart)
+}
+
+// HandleUpdateItem sets the quantity of a product in the cart
+func (h *Handler) HandleUpdateItem(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "update_cart_item",
+		Code:        "200",
+		Description: "",
+	}
+	id, err := customerID(ctx)
+	if err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	productID := ctx.PathParam("product_id")
+	if err := validation.Var("product_id", productID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var req UpdateItemRequest
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("update cart item", ""), map[string]any{
+		"customer_id": id,
+		"product_id":  productID,
+		"body":        req,
+	})
+
+	cart, err := h.service.UpdateItem(ctx, id, productID, req)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, cart)
+}
+
+// HandleRemoveItem removes a product from the cart
+func (h *Handler) HandleRemoveItem(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "remove_cart_item",
+		Code:        "200",
+		Description: "",
+	}
+	id, err := customerID(ctx)
+	if err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	productID := ctx.PathParam("product_id")
+	if err := validation.Var("product_id", productID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("remove cart item", ""), map[string]any{
+		"customer_id": id,
+		"product_id":  productID,
+	})
+
+	cart, err := h.service.RemoveItem(ctx, id, productID)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, cart)
+}
+
+// HandleCheckout places the cart as an order
+func (h *Handler) HandleCheckout(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "checkout_cart",
+		Code:        "200",
+		Description: "",
+	}
+	id, err := customerID(ctx)
+	if err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var req CheckoutRequest
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("checkout cart", ""), map[string]any{
+		"customer_id": id,
+		"body":        req,
+	})
+
+	order, err := h.service.Checkout(ctx, id, req)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, map[string]any{
+		"order_id":         order.ID,
+		"customer_id":      order.CustomerID,
+		"items":            order.Items,
+		"shipping_address": order.ShippingAddress,
+		"currency":         order.Currency,
+		"region":           order.Region,
+		"subtotal":         order.Subtotal,
+		"shipping_fee":     order.ShippingFee,
+		"discounts":        order.Discounts,
+		"discount_total":   order.DiscountTotal,
+		"taxes":            order.Taxes,
+		"tax_total":        order.TaxTotal,
+		"total_price":      order.TotalPrice,
+		"status":           order.Status,
+		"created_at":       order.CreatedAt,
+		"updated_at":       order.UpdatedAt,
+	})
+}
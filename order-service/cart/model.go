@@ -0,0 +1,45 @@
+package cart
+
+import "time"
+
+// Cart is the server-side cart of one customer. Only the product ids and
+// quantities are stored; names and prices are looked up on every read so
+// the cart always shows what checkout will charge.
+type Cart struct {
+	CustomerID  string     `json:"customer_id" bson:"_id"`
+	Items       []CartItem `json:"items" bson:"items"`
+	TotalPrice  float64    `json:"total_price" bson:"-"`
+	Unavailable int        `json:"unavailable,omitempty" bson:"-"`
+	CreatedAt   time.Time  `json:"created_at,omitzero" bson:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at,omitzero" bson:"updated_at"`
+	ExpiresAt   time.Time  `json:"expires_at,omitzero" bson:"expires_at"`
+}
+
+type CartItem struct {
+	ProductID   string  `json:"product_id" bson:"product_id"`
+	Quantity    int     `json:"quantity" bson:"quantity"`
+	Name        string  `json:"name,omitempty" bson:"-"`
+	Price       float64 `json:"price,omitempty" bson:"-"` // Price per unit, as of this read
+	Subtotal    float64 `json:"subtotal,omitempty" bson:"-"`
+	Unavailable bool    `json:"unavailable,omitempty" bson:"-"` // The product no longer exists
+}
+
+// AddItemRequest is the body of POST /cart/items. The quantity is added to
+// what the cart already holds of the product.
+type AddItemRequest struct {
+	ProductID string `json:"product_id" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"gt=0,lte=1000"`
+}
+
+// UpdateItemRequest is the body of PUT /cart/items/{product_id}.
+type UpdateItemRequest struct {
+	Quantity int `json:"quantity" validate:"gt=0,lte=1000"`
+}
+
+// CheckoutRequest is the optional body of POST /cart/checkout.
+type CheckoutRequest struct {
+	PromoCodes []string `json:"promo_codes,omitempty" validate:"max=5,dive,required"`
+	AddressID  string   `json:"address_id,omitempty"`
+	Currency   string   `json:"currency,omitempty" validate:"omitempty,iso4217"`
+	Region     string   `json:"region,omitempty" validate:"omitempty,iso3166_1_alpha2"`
+}
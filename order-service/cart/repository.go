@@ -0,0 +1,267 @@
+package cart
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository stores one cart per customer in the carts collection. Every
+// write pushes expires_at back by the cart TTL; the TTL index on it removes
+// carts left alone for longer, and reads ignore carts already past it.
+type Repository interface {
+	Get(ctx *kp.Context, customerID string) (Cart, error)
+	AddItem(ctx *kp.Context, customerID, productID string, quantity int) error
+	SetQuantity(ctx *kp.Context, customerID, productID string, quantity int) error
+	RemoveItem(ctx *kp.Context, customerID, productID string) error
+	Delete(ctx *kp.Context, customerID string) error
+}
+
+type repository struct {
+	col *mongo.Collection
+	ttl time.Duration
+}
+
+func NewRepository(col *mongo.Collection, ttl time.Duration) Repository {
+	return &repository{
+		col: col,
+		ttl: ttl,
+	}
+}
+
+var ErrItemNotFound = apperror.NotFound("cart_item_not_found", "the product is not in the cart")
+
+// maxAddAttempts bounds the retries of AddItem when the same product is
+// added twice at the same time.
+const maxAddAttempts = 3
+
+func (r *repository) Get(ctx *kp.Context, customerID string) (Cart, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "get_cart",
+		Code:        "200",
+		Description: "success",
+	}
+	filter := bson.M{"_id": customerID, "expires_at": bson.M{"$gt": time.Now()}}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "get cart"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	cart := Cart{CustomerID: customerID, Items: []CartItem{}}
+	err := r.col.FindOne(ctx, filter).Decode(&cart)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		summary.Code = "500"
+		summary.Description = "failed to get cart"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "get cart failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Cart{}, apperror.Internal(err)
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		summary.Description = "empty cart"
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "get cart success"), map[string]any{
+		"Return": cart,
+	})
+	return cart, nil
+}
+
+// AddItem adds quantity of the product, creating the cart or the line when
+// missing. A cart that has expired but is not removed yet is dropped first
+// so its old items do not come back.
+func (r *repository) AddItem(ctx *kp.Context, customerID, productID string, quantity int) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "add_cart_item",
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "add cart item"), map[string]any{
+		"collection":  r.col.Name(),
+		"customer_id": customerID,
+		"product_id":  productID,
+		"quantity":    quantity,
+	})
+
+	err := r.addItem(ctx, customerID, productID, quantity)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to add cart item"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "add cart item failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "add cart item success"), map[string]any{
+		"customer_id": customerID,
+		"product_id":  productID,
+	})
+	return nil
+}
+
+func (r *repository) addItem(ctx *kp.Context, customerID, productID string, quantity int) error {
+	now := time.Now()
+	if _, err := r.col.DeleteOne(ctx, bson.M{"_id": customerID, "expires_at": bson.M{"$lte": now}}); err != nil {
+		return err
+	}
+
+	for attempt := 0; attempt < maxAddAttempts; attempt++ {
+		result, err := r.col.UpdateOne(ctx,
+			bson.M{"_id": customerID, "items.product_id": productID},
+			bson.M{
+				"$inc": bson.M{"items.$.quantity": quantity},
+				"$set": r.touch(now),
+			})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 1 {
+			return nil
+		}
+
+		// The line is missing. Matching only carts without it means a
+		// line added in between makes the upsert collide on _id instead
+		// of adding the product twice; the next attempt increments it.
+		_, err = r.col.UpdateOne(ctx,
+			bson.M{"_id": customerID, "items.product_id": bson.M{"$ne": productID}},
+			bson.M{
+				"$push":        bson.M{"items": CartItem{ProductID: productID, Quantity: quantity}},
+				"$set":         r.touch(now),
+				"$setOnInsert": bson.M{"created_at": now},
+			},
+			options.Update().SetUpsert(true))
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		return err
+	}
+	return errors.New("cart kept changing while the item was added")
+}
+
+func (r *repository) SetQuantity(ctx *kp.Context, customerID, productID string, quantity int) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "update_cart_item",
+		Code:        "200",
+		Description: "success",
+	}
+	now := time.Now()
+	filter := bson.M{"_id": customerID, "items.product_id": productID, "expires_at": bson.M{"$gt": now}}
+	update := bson.M{"$set": bson.M{
+		"items.$.quantity": quantity,
+		"updated_at":       now,
+		"expires_at":       now.Add(r.ttl),
+	}}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "update cart item"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"update":     update,
+	})
+
+	result, err := r.col.UpdateOne(ctx, filter, update)
+	return r.itemResult(ctx, summary, start, "update cart item", result, err)
+}
+
+func (r *repository) RemoveItem(ctx *kp.Context, customerID, productID string) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "remove_cart_item",
+		Code:        "200",
+		Description: "success",
+	}
+	now := time.Now()
+	filter := bson.M{"_id": customerID, "items.product_id": productID, "expires_at": bson.M{"$gt": now}}
+	update := bson.M{
+		"$pull": bson.M{"items": bson.M{"product_id": productID}},
+		"$set":  r.touch(now),
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "remove cart item"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"update":     update,
+	})
+
+	result, err := r.col.UpdateOne(ctx, filter, update)
+	return r.itemResult(ctx, summary, start, "remove cart item", result, err)
+}
+
+// itemResult logs the outcome of a write to one cart line; no match means
+// the product is not in the cart.
+func (r *repository) itemResult(ctx *kp.Context, summary logger.LogEventTag, start time.Time, action string, result *mongo.UpdateResult, err error) error {
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to " + action
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, action+" failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return apperror.Internal(err)
+	}
+	if result.MatchedCount == 0 {
+		summary.Code = "404"
+		summary.Description = "cart item not found"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, action+" failed"), map[string]string{
+			"error": "cart item not found",
+		})
+		return ErrItemNotFound
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, action+" success"), map[string]any{
+		"Return": result,
+	})
+	return nil
+}
+
+func (r *repository) Delete(ctx *kp.Context, customerID string) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "delete_cart",
+		Code:        "200",
+		Description: "success",
+	}
+	filter := bson.M{"_id": customerID}
+	ctx.Log().Info(logger.NewDBRequest(logger.DELETE, "delete cart"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	result, err := r.col.DeleteOne(ctx, filter)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to delete cart"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.DELETE, "delete cart failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.DELETE, "delete cart success"), map[string]any{
+		"Return": result,
+	})
+	return nil
+}
+
+// touch is the $set that marks a cart as just used.
+func (r *repository) touch(now time.Time) bson.M {
+	return bson.M{
+		"updated_at": now,
+		"expires_at": now.Add(r.ttl),
+	}
+}
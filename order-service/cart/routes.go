@@ -0,0 +1,20 @@
+package cart
+
+import (
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-order-service/order"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func RegisterRoutes(app kp.IApplication, db *mongo.Database, orders order.OrderService, ttl time.Duration) {
+	repo := NewRepository(db.Collection("carts"), ttl)
+	service := NewService(repo, orders)
+	handler := NewHandler(service)
+	app.Get("/cart", handler.HandleGetCart)
+	app.Post("/cart/items", handler.HandleAddItem)
+	app.Put("/cart/items/{product_id}", handler.HandleUpdateItem)
+	app.Delete("/cart/items/{product_id}", handler.HandleRemoveItem)
+	app.Post("/cart/checkout", handler.HandleCheckout)
+}
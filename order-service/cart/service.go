@@ -0,0 +1,157 @@
+package cart
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/order"
+)
+
+type Service interface {
+	GetCart(ctx *kp.Context, customerID string) (Cart, error)
+	AddItem(ctx *kp.Context, customerID string, req AddItemRequest) (Cart, error)
+	UpdateItem(ctx *kp.Context, customerID, productID string, req UpdateItemRequest) (Cart, error)
+	RemoveItem(ctx *kp.Context, customerID, productID string) (Cart, error)
+	Checkout(ctx *kp.Context, customerID string, req CheckoutRequest) (order.Order, error)
+}
+
+type service struct {
+	repo   Repository
+	orders order.OrderService
+}
+
+// NewService prices carts and places their orders through orders.
+func NewService(repo Repository, orders order.OrderService) Service {
+	return &service{
+		repo:   repo,
+		orders: orders,
+	}
+}
+
+var (
+	ErrCartEmpty       = apperror.Conflict("cart_empty", "items", "the cart is empty")
+	ErrItemUnavailable = apperror.Conflict("cart_item_unavailable", "items", "the cart holds products that no longer exist; remove them first")
+)
+
+// TTL reads CART_TTL, how long a cart is kept after its last change;
+// a week by default.
+func TTL(conf *config.Config) time.Duration {
+	ttl, err := time.ParseDuration(conf.GetOrDefault("CART_TTL", "168h"))
+	if err != nil || ttl <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return ttl
+}
+
+func (s *service) GetCart(ctx *kp.Context, customerID string) (Cart, error) {
+	cart, err := s.repo.Get(ctx, customerID)
+	if err != nil {
+		return Cart{}, err
+	}
+	if err := s.price(ctx, &cart); err != nil {
+		return Cart{}, err
+	}
+	return cart, nil
+}
+
+func (s *service) AddItem(ctx *kp.Context, customerID string, req AddItemRequest) (Cart, error) {
+	if _, err := s.orders.GetProduct(ctx, req.ProductID); err != nil {
+		return Cart{}, err
+	}
+	if err := s.repo.AddItem(ctx, customerID, req.ProductID, req.Quantity); err != nil {
+		return Cart{}, err
+	}
+	return s.GetCart(ctx, customerID)
+}
+
+func (s *service) UpdateItem(ctx *kp.Context, customerID, productID string, req UpdateItemRequest) (Cart, error) {
+	if err := s.repo.SetQuantity(ctx, customerID, productID, req.Quantity); err != nil {
+		return Cart{}, err
+	}
+	return s.GetCart(ctx, customerID)
+}
+
+func (s *service) RemoveItem(ctx *kp.Context, customerID, productID string) (Cart, error) {
+	if err := s.repo.RemoveItem(ctx, customerID, productID); err != nil {
+		return Cart{}, err
+	}
+	return s.GetCart(ctx, customerID)
+}
+
+// Checkout places the cart as an order at the current prices, with the
+// promo codes, address, currency and region of req, and empties it. A cart holding a product that no
+// longer exists is refused rather than silently ordered without it.
+func (s *service) Checkout(ctx *kp.Context, customerID string, req CheckoutRequest) (order.Order, error) {
+	cart, err := s.GetCart(ctx, customerID)
+	if err != nil {
+		return order.Order{}, err
+	}
+	if len(cart.Items) == 0 {
+		return order.Order{}, ErrCartEmpty
+	}
+	if cart.Unavailable > 0 {
+		return order.Order{}, ErrItemUnavailable
+	}
+
+	o := order.Order{
+		CustomerID: customerID,
+		PromoCodes: req.PromoCodes,
+		AddressID:  req.AddressID,
+		Currency:   req.Currency,
+		Region:     req.Region,
+	}
+	for _, item := range cart.Items {
+		o.Items = append(o.Items, order.Item{
+			ID:       item.ProductID,
+			Quantity: item.Quantity,
+		})
+	}
+
+	placed, err := s.orders.CreateOrder(ctx, o)
+	if err != nil {
+		return order.Order{}, err
+	}
+	// The order is placed either way; the repository logs a failed delete
+	// and a cart left behind expires on its own.
+	_ = s.repo.Delete(ctx, customerID)
+	return placed, nil
+}
+
+// price fills in the name and current unit price of every line from
+// product-service and totals the cart. Products that are gone are flagged
+// and left out of the total.
+func (s *service) price(ctx *kp.Context, cart *Cart) error {
+	cart.TotalPrice = 0
+	cart.Unavailable = 0
+	for i := range cart.Items {
+		item := &cart.Items[i]
+		product, err := s.orders.GetProduct(ctx, item.ProductID)
+		if errors.Is(err, order.ErrProductNotFound) {
+			item.Unavailable = true
+			cart.Unavailable++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		price, err := strconv.ParseFloat(product.Price, 64)
+		if err != nil {
+			return apperror.Internal(err)
+		}
+		item.Name = product.Name
+		item.Price = price
+		item.Subtotal = roundCents(price * float64(item.Quantity))
+		cart.TotalPrice += item.Subtotal
+	}
+	cart.TotalPrice = roundCents(cart.TotalPrice)
+	return nil
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
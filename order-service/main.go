@@ -3,13 +3,23 @@ package main
 import (
 	"context"
 	"fmt"
+	"log"
 	"os"
 	"time"
 
 	config "github.com/sing3demons/go-common-kp/kp/configs"
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
 	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/cache"
+	"github.com/sing3demons/go-order-service/cart"
+	"github.com/sing3demons/go-order-service/migration"
 	"github.com/sing3demons/go-order-service/order"
+	"github.com/sing3demons/go-order-service/payment"
+	"github.com/sing3demons/go-order-service/pricing"
+	"github.com/sing3demons/go-order-service/promotion"
+	"github.com/sing3demons/go-order-service/returns"
+	"github.com/sing3demons/go-order-service/servicetoken"
+	"github.com/sing3demons/go-order-service/shipment"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -39,10 +49,52 @@ func main() {
 	}
 	conf.LoadEnv(path)
 
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if err := runToken(conf, os.Args[2:]); err != nil {
+			log.Fatalf("token: %v", err)
+		}
+		return
+	}
+
 	mongoDB := ConnectMongo(conf)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(mongoDB, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	if err := migration.New(mongoDB).Check(context.Background()); err != nil {
+		panic(fmt.Sprintf("Database schema is not up to date: %v", err))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "rebuild" {
+		if err := runRebuild(conf, mongoDB, os.Args[2:]); err != nil {
+			log.Fatalf("rebuild: %v", err)
+		}
+		return
+	}
+
 	app := kp.NewApplication(conf)
 	app.StartKafka()
 	app.CreateTopic("create_order_history")
+	app.CreateTopic("user_erased")
+	for _, topic := range order.UserTopics {
+		app.CreateTopic(topic)
+	}
+	for _, topic := range order.ProductTopics {
+		app.CreateTopic(topic)
+	}
+	for _, topic := range order.LifecycleTopics {
+		app.CreateTopic(topic)
+	}
+	for _, topic := range shipment.Topics {
+		app.CreateTopic(topic)
+	}
+	for _, topic := range returns.Topics {
+		app.CreateTopic(topic)
+	}
 
 	app.Get("/healthz", func(ctx *kp.Context) error {
 		return ctx.JSON(200, "OK")
@@ -93,6 +145,27 @@ func main() {
 		return ctx.JSON(200, "Consumer is running")
 	})
 
-	order.RegisterRoutes(app, mongoDB.Collection("orders"))
+	lookupCache, err := cache.New(conf)
+	if err != nil {
+		panic(err)
+	}
+	pricer, err := pricing.New(conf)
+	if err != nil {
+		panic(err)
+	}
+	pricer.Start(context.Background())
+
+	guard := servicetoken.NewGuard(conf)
+	promotions := promotion.RegisterRoutes(app, mongoDB, guard)
+	orders := order.RegisterRoutes(app, mongoDB, lookupCache, cache.TTL(conf), promotions, pricer, order.ShippingFee(conf), guard)
+	cart.RegisterRoutes(app, mongoDB, orders, cart.TTL(conf))
+
+	gateway, err := payment.NewGateway(conf)
+	if err != nil {
+		panic(err)
+	}
+	payments := payment.RegisterRoutes(app, mongoDB, orders, gateway, conf, guard)
+	shipments := shipment.RegisterRoutes(app, mongoDB, orders, conf, guard)
+	returns.RegisterRoutes(app, mongoDB, orders, shipments, payments, conf, guard)
 	app.Start()
 }
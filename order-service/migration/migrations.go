@@ -0,0 +1,224 @@
+package migration
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migrations lists every schema step for order_service, oldest first.
+// Never edit an applied entry; append a new version instead.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "orders and order_history indexes",
+		Collections: []Collection{
+			{
+				Name: "orders",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "id", Value: 1}},
+						Options: options.Index().SetName("unique_order_id").SetUnique(true),
+					},
+					{
+						Keys:    bson.D{{Key: "customerid", Value: 1}, {Key: "createdat", Value: -1}},
+						Options: options.Index().SetName("customer_orders"),
+					},
+					{
+						Keys:    bson.D{{Key: "status", Value: 1}},
+						Options: options.Index().SetName("order_status"),
+					},
+				},
+			},
+			{
+				Name: "order_history",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "order_id", Value: 1}},
+						Options: options.Index().SetName("unique_order_history_order_id").SetUnique(true),
+					},
+					{
+						Keys:    bson.D{{Key: "customer.id", Value: 1}},
+						Options: options.Index().SetName("order_history_customer"),
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     2,
+		Description: "orders and order_history schema validators",
+		Collections: []Collection{
+			{
+				Name: "orders",
+				Validator: bson.M{
+					"$jsonSchema": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"id", "customerid", "items", "totalprice", "status"},
+						"properties": bson.M{
+							"id":         bson.M{"bsonType": "string", "minLength": 1},
+							"customerid": bson.M{"bsonType": "string", "minLength": 1},
+							"items": bson.M{
+								"bsonType": "array",
+								"minItems": 1,
+								"items": bson.M{
+									"bsonType": "object",
+									"required": bson.A{"id", "quantity", "price"},
+									"properties": bson.M{
+										"id":       bson.M{"bsonType": "string"},
+										"name":     bson.M{"bsonType": "string"},
+										"quantity": bson.M{"bsonType": bson.A{"int", "long"}, "minimum": 1},
+										"price":    bson.M{"bsonType": "number", "minimum": 0},
+									},
+								},
+							},
+							"totalprice": bson.M{"bsonType": "number", "minimum": 0},
+							"status":     bson.M{"bsonType": "string"},
+							"createdat":  bson.M{"bsonType": "string"},
+							"updatedat":  bson.M{"bsonType": "string"},
+						},
+					},
+				},
+			},
+			{
+				Name: "order_history",
+				Validator: bson.M{
+					"$jsonSchema": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"order_id", "customer", "products"},
+						"properties": bson.M{
+							"order_id":    bson.M{"bsonType": "string", "minLength": 1},
+							"customer":    bson.M{"bsonType": "object"},
+							"products":    bson.M{"bsonType": "array"},
+							"total_price": bson.M{"bsonType": "number"},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     3,
+		Description: "order_view read model indexes",
+		Collections: []Collection{
+			{
+				Name: "order_view",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+						Options: options.Index().SetName("order_view_created"),
+					},
+					{
+						Keys:    bson.D{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+						Options: options.Index().SetName("order_view_customer"),
+					},
+					{
+						Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+						Options: options.Index().SetName("order_view_status"),
+					},
+					{
+						Keys:    bson.D{{Key: "customer_name", Value: "text"}, {Key: "items.name", Value: "text"}},
+						Options: options.Index().SetName("order_view_search"),
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     4,
+		Description: "carts expiry index",
+		Collections: []Collection{
+			{
+				Name: "carts",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "expires_at", Value: 1}},
+						Options: options.Index().SetName("cart_expiry").SetExpireAfterSeconds(0),
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     5,
+		Description: "payment_intents indexes",
+		Collections: []Collection{
+			{
+				Name: "payment_intents",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "order_id", Value: 1}, {Key: "created_at", Value: 1}},
+						Options: options.Index().SetName("payment_intents_order"),
+					},
+					{
+						Keys: bson.D{{Key: "provider", Value: 1}, {Key: "provider_ref", Value: 1}},
+						Options: options.Index().SetName("unique_payment_provider_ref").SetUnique(true).
+							SetPartialFilterExpression(bson.M{"provider_ref": bson.M{"$exists": true}}),
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     6,
+		Description: "promotions indexes",
+		Collections: []Collection{
+			{
+				Name: "promotions",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "created_at", Value: -1}},
+						Options: options.Index().SetName("promotions_created"),
+					},
+				},
+			},
+			{
+				Name: "promotion_redemptions",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "customer_id", Value: 1}, {Key: "code", Value: 1}},
+						Options: options.Index().SetName("promotion_redemptions_customer"),
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     7,
+		Description: "shipments indexes",
+		Collections: []Collection{
+			{
+				Name: "shipments",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "order_id", Value: 1}, {Key: "created_at", Value: 1}},
+						Options: options.Index().SetName("shipments_order"),
+					},
+					{
+						Keys:    bson.D{{Key: "carrier", Value: 1}, {Key: "tracking_number", Value: 1}},
+						Options: options.Index().SetName("unique_shipment_tracking").SetUnique(true),
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     8,
+		Description: "returns indexes",
+		Collections: []Collection{
+			{
+				Name: "returns",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "order_id", Value: 1}, {Key: "created_at", Value: 1}},
+						Options: options.Index().SetName("returns_order"),
+					},
+					{
+						Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+						Options: options.Index().SetName("returns_status"),
+					},
+				},
+			},
+		},
+	},
+}
@@ -0,0 +1,134 @@
+package order
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/servicetoken"
+)
+
+// ShippingAddress is where an order ships to. It is copied from the
+// customer's address book when the order is placed, so later edits to the
+// book do not move the order.
+type ShippingAddress struct {
+	ID         string `json:"id"`
+	Label      string `json:"label,omitempty"`
+	Name       string `json:"name"`
+	Phone      string `json:"phone,omitempty"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	State      string `json:"state,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+	Country    string `json:"country"`
+}
+
+var (
+	errAddressNotFound = apperror.Invalid(apperror.FieldError{Field: "address_id", Rule: "exists", Message: "is not an address of the customer"})
+	errAddressRegion   = apperror.Invalid(apperror.FieldError{Field: "region", Rule: "eqfield", Message: "must be the country of the shipping address"})
+	errAddressService  = apperror.Upstream("user_service_error", "user-service could not provide the address", nil)
+)
+
+// getAddress reads one address of the customer's address book. Addresses
+// are not cached: the order must ship where the book says now.
+func getAddress(ctx *kp.Context, customerID, addressID string) (ShippingAddress, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "user_service",
+		Command:     "get_address",
+		Code:        "200",
+		Description: "success",
+	}
+
+	userServiceURL := os.Getenv("USER_SERVICE_URL")
+	if userServiceURL == "" {
+		userServiceURL = "http://localhost:8080" // Default URL if not set
+	}
+	httpRequest := HttpRequest{
+		URL:      userServiceURL + "/internal/users/" + customerID + "/addresses/" + addressID,
+		Headers:  map[string]string{contentTypeHeader: "application/json"},
+		Params:   map[string]string{"user_id": customerID, "address_id": addressID},
+		Protocol: "http",
+		Method:   http.MethodGet,
+		Timeout:  10 * time.Second,
+	}
+
+	ctx.Log().Info(logger.NewHTTPRequest("get address", ""), map[string]any{
+		"uri":      httpRequest.URL,
+		"headers":  httpRequest.Headers,
+		"params":   httpRequest.Params,
+		"protocol": httpRequest.Protocol,
+		"method":   httpRequest.Method,
+		"timeout":  httpRequest.Timeout,
+	})
+	req, err := http.NewRequest(http.MethodGet, httpRequest.URL, nil)
+	if err != nil {
+		return ShippingAddress{}, errAddressService.Wrap(err)
+	}
+	req.Header.Set(contentTypeHeader, httpRequest.Headers[contentTypeHeader])
+	if err := servicetoken.Authorize(req, servicetoken.UserService); err != nil {
+		return ShippingAddress{}, errAddressService.Wrap(err)
+	}
+
+	httpClient := &http.Client{Timeout: httpRequest.Timeout}
+	resp, err := httpClient.Do(req)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to get address"
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("http get address", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return ShippingAddress{}, errAddressService.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		summary.Code = fmt.Sprintf("%d", resp.StatusCode)
+		summary.Description = resp.Status
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get address failed", ""), map[string]string{
+			"error": fmt.Sprintf("failed to get address: %s", resp.Status),
+		})
+		if resp.StatusCode == http.StatusNotFound {
+			return ShippingAddress{}, errAddressNotFound
+		}
+		return ShippingAddress{}, errAddressService.Wrap(fmt.Errorf("failed to get address: %s", resp.Status))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to read response body"
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get address failed", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return ShippingAddress{}, errAddressService.Wrap(err)
+	}
+	var address ShippingAddress
+	if err := json.Unmarshal(body, &address); err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get address failed", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return ShippingAddress{}, errAddressService.Wrap(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewHTTPResponse("get address success", ""), map[string]any{
+		"Status": resp.Status,
+		"Body":   address,
+	}, []logger.MaskingOptionDto{
+		{MaskingField: "Body.name", MaskingType: logger.Full},
+		{MaskingField: "Body.phone", MaskingType: logger.Msisdn},
+		{MaskingField: "Body.line1", MaskingType: logger.Full},
+		{MaskingField: "Body.line2", MaskingType: logger.Full},
+	}...)
+	return address, nil
+}
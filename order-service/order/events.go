@@ -0,0 +1,120 @@
+package order
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+)
+
+// The order lifecycle topics. Each message is
+// {"event_id": ..., "occurred_at": ..., "body": OrderEvent}.
+const (
+	orderCreatedTopic   = "order_created"
+	orderPaidTopic      = "order_paid"
+	orderShippedTopic   = "order_shipped"
+	orderDeliveredTopic = "order_delivered"
+	orderCanceledTopic  = "order_canceled"
+)
+
+// LifecycleTopics lists the order lifecycle topics, in the order an order
+// moves through them.
+var LifecycleTopics = []string{orderCreatedTopic, orderPaidTopic, orderShippedTopic, orderDeliveredTopic, orderCanceledTopic}
+
+// lifecycleStatus is the order status each lifecycle topic moves to.
+var lifecycleStatus = map[string]string{
+	orderCreatedTopic:   "pending",
+	orderPaidTopic:      "paid",
+	orderShippedTopic:   "shipped",
+	orderDeliveredTopic: "delivered",
+	orderCanceledTopic:  "canceled",
+}
+
+// statusRank orders the statuses the way an order moves through them. An
+// order never goes back, and it ends either delivered or canceled.
+var statusRank = map[string]int{
+	"pending":   1,
+	"paid":      2,
+	"shipped":   3,
+	"delivered": 4,
+	"canceled":  4,
+}
+
+// OrderEvent is the body of the lifecycle events. order_created carries the
+// whole order; the other events only need the id.
+type OrderEvent struct {
+	OrderID      string  `json:"order_id" validate:"required"`
+	CustomerID   string  `json:"customer_id,omitempty"`
+	CustomerName string  `json:"customer_name,omitempty"`
+	Items        []Item  `json:"items,omitempty"`
+	TotalPrice   float64 `json:"total_price,omitempty"`
+	Currency     string  `json:"currency,omitempty"`
+	PaymentID    string  `json:"payment_id,omitempty"`
+	ShipmentID   string  `json:"shipment_id,omitempty"`
+	Reason       string  `json:"reason,omitempty"`
+}
+
+// EventMessage is a lifecycle event as consumed from Kafka.
+type EventMessage struct {
+	EventID    string     `json:"event_id" validate:"required"`
+	OccurredAt time.Time  `json:"occurred_at"`
+	Body       OrderEvent `json:"body"`
+}
+
+var errPublishEvent = apperror.Upstream("event_publish_failed", "the order event could not be published", nil)
+
+// displayName is how the customer is shown on the order view: the full
+// name, or the username when no name is set.
+func displayName(user UserModel) string {
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		return name
+	}
+	return user.Username
+}
+
+// publishEvent sends body to topic wrapped the way the lifecycle consumers
+// bind it.
+func publishEvent(ctx *kp.Context, topic string, body OrderEvent) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "kafka",
+		Command:     topic,
+		Code:        "200",
+		Description: "success",
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	message, err := json.Marshal(EventMessage{
+		EventID:    id.String(),
+		OccurredAt: time.Now().UTC(),
+		Body:       body,
+	})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	ctx.Log().Info(logger.NewProducing(topic, ""), map[string]any{
+		"topic": topic,
+		"value": string(message),
+	})
+	if err := ctx.Publish(ctx, topic, message); err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to publish " + topic
+		summary.ResTime = time.Since(start).Milliseconds()
+		ctx.Log().SetSummary(summary).Error(logger.NewProduced(topic, ""), map[string]string{
+			"error": err.Error(),
+		})
+		return errPublishEvent.Wrap(err)
+	}
+	summary.ResTime = time.Since(start).Milliseconds()
+	ctx.Log().SetSummary(summary).Info(logger.NewProduced(topic, ""), map[string]any{
+		"topic": topic,
+	})
+	return nil
+}
@@ -1,10 +1,10 @@
 package order
 
 import (
-	"errors"
-
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
 	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/validation"
 )
 
 type Handler struct {
@@ -26,21 +26,8 @@ func (h *Handler) HandleCreateOrder(ctx *kp.Context) error {
 		Description: "",
 	}
 	var req Order
-	if err := ctx.Bind(&req); err != nil {
-		summary.Code = "400"
-		summary.Description = "invalid_request"
-		ctx.Log().SetSummary(summary).Error(logger.NewInbound("create order failed", ""), map[string]string{
-			"error": err.Error(),
-		})
-		return ctx.JSON(400, map[string]string{
-			"error": "invalid request",
-		})
-	}
-
-	if err := h.validateOrder(ctx, req); err != nil {
-		return ctx.JSON(400, map[string]string{
-			"error": err.Error(),
-		})
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
 	}
 	ctx.Log().SetSummary(summary).Info(logger.NewInbound("create order", ""), map[string]any{
 		"body": req,
@@ -48,65 +35,228 @@ func (h *Handler) HandleCreateOrder(ctx *kp.Context) error {
 
 	order, err := h.service.CreateOrder(ctx, req)
 	if err != nil {
-		return ctx.JSON(500, map[string]string{
-			"error": "failed to create order",
-		})
+		return apperror.Write(ctx, err)
 	}
 
 	return ctx.JSON(200, map[string]any{
-		"order_id":    order.ID,
-		"customer_id": order.CustomerID,
-		"items":       order.Items,
-		"total_price": order.TotalPrice,
-		"status":      order.Status,
-		"created_at":  order.CreatedAt,
-		"updated_at":  order.UpdatedAt,
+		"order_id":         order.ID,
+		"customer_id":      order.CustomerID,
+		"items":            order.Items,
+		"shipping_address": order.ShippingAddress,
+		"currency":         order.Currency,
+		"region":           order.Region,
+		"subtotal":         order.Subtotal,
+		"shipping_fee":     order.ShippingFee,
+		"discounts":        order.Discounts,
+		"discount_total":   order.DiscountTotal,
+		"taxes":            order.Taxes,
+		"tax_total":        order.TaxTotal,
+		"total_price":      order.TotalPrice,
+		"status":           order.Status,
+		"created_at":       order.CreatedAt,
+		"updated_at":       order.UpdatedAt,
 	})
 }
 
-func (h *Handler) validateOrder(ctx *kp.Context, req Order) error {
+// HandleGetCustomerData returns the orders and order history of a customer
+func (h *Handler) HandleGetCustomerData(ctx *kp.Context) error {
 	summary := logger.LogEventTag{
 		Node:        "client",
-		Command:     "create_order",
-		Code:        "400",
-		Description: "invalid_request",
+		Command:     "get_customer_data",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("get customer data", ""), map[string]any{
+		"param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+	})
+
+	data, err := h.service.GetCustomerData(ctx, id)
+	if err != nil {
+		return apperror.Write(ctx, err)
 	}
 
-	desc := "create order request validation failed"
+	return ctx.JSON(200, data)
+}
 
-	// validate the request
-	if req.CustomerID == "" {
-		ctx.Log().SetSummary(summary).Error(logger.NewInbound(desc, ""), map[string]string{
-			"error": "customer_id is required",
-		})
-		return errors.New(summary.Description)
+// HandleUserErased scrubs the customer snapshots of an erased user
+func (h *Handler) HandleUserErased(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "kafka",
+		Command:     "user_erased",
+		Code:        "200",
+		Description: "",
+	}
+	var data struct {
+		Body UserErasedEvent `json:"body"`
+	}
+	if err := validation.Bind(ctx, &data); err != nil {
+		return validation.Respond(ctx, summary, err)
 	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("user erased", ""), map[string]any{
+		"body": data.Body,
+	})
 
-	if len(req.Items) == 0 {
-		ctx.Log().SetSummary(summary).Error(logger.NewInbound(desc, ""), map[string]string{
-			"error": "items are required",
-		})
-		return errors.New(summary.Description)
+	if err := h.service.EraseCustomer(ctx, data.Body.UserID); err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, "Order history scrubbed")
+}
 
+// HandleUserChanged returns the consumer that drops the cached copy of a
+// user on one of UserTopics
+func (h *Handler) HandleUserChanged(topic string) func(ctx *kp.Context) error {
+	return func(ctx *kp.Context) error {
+		return h.handleChanged(ctx, topic, h.service.InvalidateCustomer)
 	}
+}
 
-	if len(req.Items) > 0 {
-		for _, item := range req.Items {
-			if item.ID == "" || item.Name == "" || item.Quantity <= 0 || item.Price <= 0 {
-				ctx.Log().SetSummary(summary).Error(logger.NewInbound(desc, ""), map[string]string{
-					"error": "invalid item data",
-				})
-				return errors.New(summary.Description)
+// HandleProductChanged returns the consumer that drops the cached copy of a
+// product on one of ProductTopics
+func (h *Handler) HandleProductChanged(topic string) func(ctx *kp.Context) error {
+	return func(ctx *kp.Context) error {
+		return h.handleChanged(ctx, topic, h.service.InvalidateProduct)
+	}
+}
 
-			}
-		}
+func (h *Handler) handleChanged(ctx *kp.Context, topic string, invalidate func(*kp.Context, string) error) error {
+	summary := logger.LogEventTag{
+		Node:        "kafka",
+		Command:     topic,
+		Code:        "200",
+		Description: "",
+	}
+	var data struct {
+		Body ChangedEvent `json:"body"`
+	}
+	if err := validation.Bind(ctx, &data); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(topic, ""), map[string]any{
+		"body": data.Body,
+	})
+
+	if err := invalidate(ctx, data.Body.ID); err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, "Cache invalidated")
+}
+
+// HandleListOrders lists and searches orders from the order_view read model
+func (h *Handler) HandleListOrders(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "list_orders",
+		Code:        "200",
+		Description: "",
+	}
+	query, err := ParseOrderQuery(ctx)
+	if err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("list orders", ""), map[string]any{
+		"query": query,
+	})
+
+	page, err := h.service.ListOrders(ctx, query)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, page)
+}
+
+// HandleListCustomerOrders lists the orders of one customer from the
+// order_view read model, without the customer, see PublicView
+func (h *Handler) HandleListCustomerOrders(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "list_customer_orders",
+		Code:        "200",
+		Description: "",
+	}
+	query, err := ParseCustomerOrderQuery(ctx)
+	if err != nil {
+		return validation.Respond(ctx, summary, err)
 	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("list customer orders", ""), map[string]any{
+		"query": query,
+	})
+
+	page, err := h.service.ListOrders(ctx, query)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, page.Public())
+}
 
-	if req.TotalPrice <= 0 {
-		ctx.Log().SetSummary(summary).Error(logger.NewInbound("create order", ""), map[string]string{
-			"error": "total_price must be greater than 0",
+// HandleGetOrder returns one order from the order_view read model, without
+// the customer, see PublicView
+func (h *Handler) HandleGetOrder(ctx *kp.Context) error {
+	return h.getOrder(ctx, true)
+}
+
+// HandleGetInternalOrder returns one order from the order_view read model,
+// customer included, to the other services
+func (h *Handler) HandleGetInternalOrder(ctx *kp.Context) error {
+	return h.getOrder(ctx, false)
+}
+
+func (h *Handler) getOrder(ctx *kp.Context, public bool) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "get_order",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("get order", ""), map[string]any{
+		"param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+	})
+
+	order, err := h.service.GetOrder(ctx, id)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	if public {
+		return ctx.JSON(200, order.Public())
+	}
+	return ctx.JSON(200, order)
+}
+
+// HandleOrderEvent returns the consumer that folds the events of a
+// lifecycle topic into the order_view read model
+func (h *Handler) HandleOrderEvent(topic string) func(ctx *kp.Context) error {
+	return func(ctx *kp.Context) error {
+		summary := logger.LogEventTag{
+			Node:        "kafka",
+			Command:     topic,
+			Code:        "200",
+			Description: "",
+		}
+		var data EventMessage
+		if err := validation.Bind(ctx, &data); err != nil {
+			return validation.Respond(ctx, summary, err)
+		}
+		ctx.Log().SetSummary(summary).Info(logger.NewInbound(topic, ""), map[string]any{
+			"event_id": data.EventID,
+			"body":     data.Body,
 		})
-		return errors.New(summary.Description)
+
+		if err := h.service.ApplyOrderEvent(ctx, topic, data); err != nil {
+			return apperror.Write(ctx, err)
+		}
+		return ctx.JSON(200, "Order view updated")
 	}
-	return nil
 }
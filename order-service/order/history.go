@@ -0,0 +1,122 @@
+package order
+
+import (
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// HistoryRepository reads and scrubs the order_history snapshots written by
+// the create_order_history consumer. Each document embeds the customer as it
+// looked when the order was placed, keyed by its JSON field names.
+type HistoryRepository interface {
+	FindByCustomer(ctx *kp.Context, customerID string) ([]map[string]any, error)
+	ScrubCustomer(ctx *kp.Context, customerID string) (int64, error)
+}
+
+type historyRepository struct {
+	col *mongo.Collection
+}
+
+func NewHistoryRepository(col *mongo.Collection) HistoryRepository {
+	return &historyRepository{
+		col: col,
+	}
+}
+
+func (r *historyRepository) FindByCustomer(ctx *kp.Context, customerID string) ([]map[string]any, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "find_order_history_by_customer",
+		Code:        "200",
+		Description: "success",
+	}
+
+	filter := bson.M{"customer.id": customerID}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find order history by customer"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	cursor, err := r.col.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 0}))
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to find order history"
+		summary.ResTime = time.Since(start).Milliseconds()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find order history failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	history := []map[string]any{}
+	err = cursor.All(ctx, &history)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to decode order history"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find order history failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find order history success"), map[string]any{
+		"count": len(history),
+	})
+	return history, nil
+}
+
+// ScrubCustomer blanks the personal fields of every customer snapshot that
+// belongs to customerID. The id is kept so orders still join to the user.
+func (r *historyRepository) ScrubCustomer(ctx *kp.Context, customerID string) (int64, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "scrub_order_history_customer",
+		Code:        "200",
+		Description: "success",
+	}
+
+	filter := bson.M{"customer.id": customerID}
+	update := bson.M{
+		"$set": bson.M{
+			"customer.first_name": "",
+			"customer.last_name":  "",
+			"customer.username":   "",
+			"customer.email":      "",
+			"customer.erased":     true,
+		},
+		"$unset": bson.M{
+			"customer.avatar":           "",
+			"customer.avatar_thumbnail": "",
+		},
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "scrub order history customer"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"update":     update,
+	})
+
+	result, err := r.col.UpdateMany(ctx, filter, update)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to scrub order history"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "scrub order history failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return 0, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "scrub order history success"), map[string]any{
+		"Return": result,
+	})
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,166 @@
+package order
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const defaultLookupCacheSize = 1000
+
+// lookupTransport is shared by the user and product lookups so they
+// revalidate what an earlier order already fetched. LOOKUP_CACHE_SIZE
+// bounds how many responses it keeps.
+var lookupTransport = newRevalidatingTransport(http.DefaultTransport, lookupCacheSize())
+
+func lookupCacheSize() int {
+	size, err := strconv.Atoi(os.Getenv("LOOKUP_CACHE_SIZE"))
+	if err != nil || size <= 0 {
+		return defaultLookupCacheSize
+	}
+	return size
+}
+
+type cachedResponse struct {
+	key          string
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+}
+
+// response rebuilds the cached 200 for req. X-Cache tells the caller, and
+// the logs, that the body came from the cache.
+func (c *cachedResponse) response(req *http.Request) *http.Response {
+	header := c.header.Clone()
+	header.Set("X-Cache", "REVALIDATED")
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       req,
+	}
+}
+
+// revalidatingTransport keeps the last 200 of each GET that came with an
+// ETag or Last-Modified and sends them back as If-None-Match and
+// If-Modified-Since. A 304 is answered from the copy, so an unchanged user
+// or product still costs a round trip but no body. The least recently used
+// entry is dropped once size is reached.
+type revalidatingTransport struct {
+	next http.RoundTripper
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front is the most recently used
+}
+
+func newRevalidatingTransport(next http.RoundTripper, size int) *revalidatingTransport {
+	return &revalidatingTransport{
+		next:    next,
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+func (t *revalidatingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	cached := t.get(key)
+	if cached != nil {
+		req = req.Clone(req.Context())
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		if cached == nil {
+			return resp, nil
+		}
+		resp.Body.Close()
+		return cached.response(req), nil
+	case http.StatusOK:
+		etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if etag == "" && lastModified == "" {
+			t.remove(key)
+			return resp, nil
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		t.put(&cachedResponse{
+			key:          key,
+			etag:         etag,
+			lastModified: lastModified,
+			header:       resp.Header.Clone(),
+			body:         body,
+		})
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	case http.StatusNotFound, http.StatusGone:
+		t.remove(key)
+	}
+	return resp, nil
+}
+
+func (t *revalidatingTransport) get(key string) *cachedResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	elem, ok := t.entries[key]
+	if !ok {
+		return nil
+	}
+	t.order.MoveToFront(elem)
+	return elem.Value.(*cachedResponse)
+}
+
+func (t *revalidatingTransport) put(entry *cachedResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if elem, ok := t.entries[entry.key]; ok {
+		elem.Value = entry
+		t.order.MoveToFront(elem)
+		return
+	}
+	t.entries[entry.key] = t.order.PushFront(entry)
+	for t.order.Len() > t.size {
+		oldest := t.order.Back()
+		t.order.Remove(oldest)
+		delete(t.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+func (t *revalidatingTransport) remove(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if elem, ok := t.entries[key]; ok {
+		t.order.Remove(elem)
+		delete(t.entries, key)
+	}
+}
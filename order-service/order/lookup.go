@@ -0,0 +1,110 @@
+package order
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/cache"
+)
+
+// UserTopics and ProductTopics are the events after which the cached copy
+// of a user or product is stale: it changed, was deleted or was repriced.
+var (
+	UserTopics    = []string{"user_updated", "user_deleted"}
+	ProductTopics = []string{"product_updated", "product_deleted", "price_changed"}
+)
+
+func userCacheKey(id string) string {
+	return "user:" + id
+}
+
+func productCacheKey(id string) string {
+	return "product:" + id
+}
+
+// readThrough returns the cached value of key, or loads it and caches it
+// for ttl. The summary of the cache read says hit or miss. A cache that
+// fails is logged and skipped: lookups then behave as if it were empty.
+func readThrough[T any](ctx *kp.Context, c cache.Cache, ttl time.Duration, cmd, key string, load func() (T, error)) (T, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "cache",
+		Command:     cmd,
+		Code:        "200",
+		Description: "miss",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, cmd), map[string]any{
+		"cache": c.Name(),
+		"key":   key,
+	})
+
+	var value T
+	data, ok, err := c.Get(ctx, key)
+	summary.ResTime = time.Since(start).Milliseconds()
+	switch {
+	case err != nil:
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, cmd), map[string]any{
+			"key":   key,
+			"error": err.Error(),
+		})
+	case ok && json.Unmarshal(data, &value) == nil:
+		summary.Description = "hit"
+		ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, cmd), map[string]any{
+			"key": key,
+		})
+		return value, nil
+	default:
+		ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, cmd), map[string]any{
+			"key": key,
+		})
+	}
+
+	value, err = load()
+	if err != nil {
+		return value, err
+	}
+	if data, err := json.Marshal(value); err == nil {
+		if err := c.Set(ctx, key, data, ttl); err != nil {
+			ctx.Log().Error(logger.NewDBResponse(logger.INSERT, cmd), map[string]any{
+				"key":   key,
+				"error": err.Error(),
+			})
+		}
+	}
+	return value, nil
+}
+
+// invalidate drops keys from the cache; cmd names the event behind it.
+func invalidate(ctx *kp.Context, c cache.Cache, cmd string, keys ...string) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "cache",
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.DELETE, cmd), map[string]any{
+		"cache": c.Name(),
+		"keys":  keys,
+	})
+
+	err := c.Delete(ctx, keys...)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.DELETE, cmd), map[string]any{
+			"keys":  keys,
+			"error": err.Error(),
+		})
+		return errCache.Wrap(err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.DELETE, cmd), map[string]any{
+		"keys": keys,
+	})
+	return nil
+}
@@ -1,22 +1,54 @@
 package order
 
-import "time"
+import (
+	"time"
 
+	"github.com/sing3demons/go-order-service/pricing"
+	"github.com/sing3demons/go-order-service/promotion"
+)
+
+// Item is one line of an order. Name, Category and Price are filled in
+// from product-service when the order is placed, and the amounts after
+// them computed; what the client sends for them is ignored.
 type Item struct {
-	ID       string  `json:"id"`
-	Name     string  `json:"name"`
-	Quantity int     `json:"quantity"`
-	Price    float64 `json:"price"` // Price per unit
+	ID       string            `json:"id" validate:"required"`
+	Name     string            `json:"name,omitempty"`
+	Category string            `json:"category,omitempty"`
+	Quantity int               `json:"quantity" validate:"gt=0"`
+	Price    float64           `json:"price,omitempty"` // Price per unit, in the currency of the order
+	Subtotal float64           `json:"subtotal,omitempty"`
+	Discount float64           `json:"discount,omitempty"` // Share of the order's item discounts
+	Taxes    []pricing.TaxLine `json:"taxes,omitempty"`
+	Tax      float64           `json:"tax,omitempty"`
+	Total    float64           `json:"total,omitempty"` // Subtotal - Discount + Tax
 }
 
+// Order is a placed order. AddressID names an address of the customer's
+// address book; it is copied to ShippingAddress and, unless given, Region
+// is its country. The amounts are computed when it is placed, in
+// Currency and under the tax rules of Region, so that
+// TotalPrice = Subtotal + ShippingFee - DiscountTotal + TaxTotal always
+// holds. FXRate is what one unit of the base currency was worth then.
 type Order struct {
-	ID         string  `json:"id"`
-	CustomerID string  `json:"customer_id"`
-	Items      []Item  `json:"items"`
-	TotalPrice float64 `json:"total_price"`
-	Status     string  `json:"status"`     // e.g., "pending", "completed", "canceled"
-	CreatedAt  string  `json:"created_at"` // ISO 8601 format
-	UpdatedAt  string  `json:"updated_at"` // ISO 8601 format
+	ID              string               `json:"id"`
+	CustomerID      string               `json:"customer_id" validate:"required"`
+	Items           []Item               `json:"items" validate:"required,min=1,dive"`
+	PromoCodes      []string             `json:"promo_codes,omitempty" validate:"max=5,dive,required"`
+	AddressID       string               `json:"address_id,omitempty"`
+	ShippingAddress *ShippingAddress     `json:"shipping_address,omitempty"`
+	Currency        string               `json:"currency" validate:"omitempty,iso4217"`
+	Region          string               `json:"region" validate:"omitempty,iso3166_1_alpha2"`
+	FXRate          float64              `json:"fx_rate,omitempty"`
+	Subtotal        float64              `json:"subtotal"`
+	ShippingFee     float64              `json:"shipping_fee"`
+	Discounts       []promotion.Discount `json:"discounts,omitempty"`
+	DiscountTotal   float64              `json:"discount_total"`
+	Taxes           []pricing.TaxLine    `json:"taxes,omitempty"`
+	TaxTotal        float64              `json:"tax_total"`
+	TotalPrice      float64              `json:"total_price"`
+	Status          string               `json:"status"`     // pending on create, then moved by payment, shipment and cancellation; what the client sends is ignored
+	CreatedAt       string               `json:"created_at"` // ISO 8601 format
+	UpdatedAt       string               `json:"updated_at"` // ISO 8601 format
 }
 
 type UserModel struct {
@@ -37,7 +69,28 @@ type ProductModel struct {
 	Name        string    `json:"name"`
 	Href        string    `json:"href,omitempty"`
 	Price       string    `json:"price"`
+	Category    string    `json:"category,omitempty"`
 	Description string    `json:"description,omitempty"`
 	CreatedAt   time.Time `json:"createdAt,omitzero"`
 	UpdatedAt   time.Time `json:"updatedAt,omitzero"`
 }
+
+// CustomerData is everything order-service stores about one customer, served
+// to user-service for personal data exports.
+type CustomerData struct {
+	CustomerID   string           `json:"customer_id"`
+	Orders       []Order          `json:"orders"`
+	OrderHistory []map[string]any `json:"order_history"`
+}
+
+// UserErasedEvent is consumed from the user_erased topic.
+type UserErasedEvent struct {
+	UserID   string `json:"user_id" validate:"required"`
+	ErasedAt string `json:"erased_at"`
+}
+
+// ChangedEvent is consumed from UserTopics and ProductTopics. Only the id
+// is needed to drop the cached copy.
+type ChangedEvent struct {
+	ID string `json:"id" validate:"required"`
+}
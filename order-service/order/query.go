@@ -0,0 +1,204 @@
+package order
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/validation"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// OrderQuery holds the paging and filtering options of GET
+// /internal/orders and GET /customers/{id}/orders. Orders are listed newest
+// first.
+type OrderQuery struct {
+	Limit       int    `json:"limit" validate:"min=1,max=100"`
+	After       string `json:"after"`
+	CustomerID  string `json:"customer_id"`
+	ProductID   string `json:"product_id"`
+	Status      string `json:"status" validate:"omitempty,oneof=pending paid shipped delivered canceled"`
+	Q           string `json:"q" validate:"omitempty,max=100"`
+	CreatedFrom string `json:"created_from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedTo   string `json:"created_to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+
+	cursor *orderCursor
+	// path is where the next page is read, when not /internal/orders
+	path string
+}
+
+// orderCursor marks the last order of a page: its creation time and the id,
+// which breaks ties between orders created at the same time.
+type orderCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"id"`
+}
+
+// OrderPage is one page of GET /internal/orders.
+type OrderPage struct {
+	Orders []*OrderView `json:"orders"`
+	Count  int          `json:"count"`
+	Next   string       `json:"next,omitempty"`
+}
+
+// PublicOrderPage is one page of GET /customers/{id}/orders, whose orders
+// leave out the customer like GET /orders/{id} does.
+type PublicOrderPage struct {
+	Orders []PublicView `json:"orders"`
+	Count  int          `json:"count"`
+	Next   string       `json:"next,omitempty"`
+}
+
+// Public returns the page with the public view of each order.
+func (p OrderPage) Public() PublicOrderPage {
+	orders := make([]PublicView, 0, len(p.Orders))
+	for _, order := range p.Orders {
+		orders = append(orders, order.Public())
+	}
+	return PublicOrderPage{Orders: orders, Count: p.Count, Next: p.Next}
+}
+
+// ParseOrderQuery reads the GET /internal/orders query string.
+func ParseOrderQuery(ctx *kp.Context) (OrderQuery, error) {
+	q := OrderQuery{
+		Limit:       defaultPageSize,
+		After:       ctx.Param("after"),
+		CustomerID:  ctx.Param("customer_id"),
+		ProductID:   ctx.Param("product_id"),
+		Status:      ctx.Param("status"),
+		Q:           ctx.Param("q"),
+		CreatedFrom: ctx.Param("created_from"),
+		CreatedTo:   ctx.Param("created_to"),
+	}
+	if v := ctx.Param("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return q, apperror.Invalid(apperror.FieldError{Field: "limit", Rule: "numeric", Message: "must be a number"})
+		}
+		q.Limit = limit
+	}
+	if err := validation.Struct(q); err != nil {
+		return q, err
+	}
+
+	if q.After != "" {
+		cursor, err := decodeCursor(q.After)
+		if err != nil {
+			return q, apperror.Invalid(apperror.FieldError{Field: "after", Rule: "cursor", Message: "is not a valid cursor"})
+		}
+		q.cursor = cursor
+	}
+	return q, nil
+}
+
+// ParseCustomerOrderQuery reads the GET /customers/{id}/orders query
+// string. The orders are those of the customer in the path, whatever
+// customer_id says.
+func ParseCustomerOrderQuery(ctx *kp.Context) (OrderQuery, error) {
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required"); err != nil {
+		return OrderQuery{}, err
+	}
+	q, err := ParseOrderQuery(ctx)
+	q.CustomerID = id
+	q.path = "/customers/" + url.PathEscape(id) + "/orders"
+	return q, err
+}
+
+// filter builds the Mongo filter for the query, including the keyset
+// condition that resumes after the cursor. q searches the customer name and
+// the item names through the order_view text index.
+func (q OrderQuery) filter() bson.M {
+	filter := bson.M{}
+	if q.CustomerID != "" {
+		filter["customer_id"] = q.CustomerID
+	}
+	if q.ProductID != "" {
+		filter["items.id"] = q.ProductID
+	}
+	if q.Status != "" {
+		filter["status"] = q.Status
+	}
+	if q.Q != "" {
+		filter["$text"] = bson.M{"$search": q.Q}
+	}
+	created := bson.M{}
+	if t, err := time.Parse(time.RFC3339, q.CreatedFrom); err == nil {
+		created["$gte"] = t.UTC()
+	}
+	if t, err := time.Parse(time.RFC3339, q.CreatedTo); err == nil {
+		created["$lt"] = t.UTC()
+	}
+	if len(created) > 0 {
+		filter["created_at"] = created
+	}
+
+	if q.cursor != nil {
+		filter["$or"] = bson.A{
+			bson.M{"created_at": bson.M{"$lt": q.cursor.CreatedAt}},
+			bson.M{"created_at": q.cursor.CreatedAt, "_id": bson.M{"$lt": q.cursor.ID}},
+		}
+	}
+	return filter
+}
+
+// next returns the link to the page after orders, which must hold one
+// order more than the limit for a next page to exist.
+func (q OrderQuery) next(ctx *kp.Context, orders []*OrderView) string {
+	if len(orders) <= q.Limit {
+		return ""
+	}
+	last := orders[q.Limit-1]
+
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(q.Limit))
+	params.Set("after", encodeCursor(orderCursor{CreatedAt: last.CreatedAt, ID: last.ID}))
+	filters := map[string]string{
+		"product_id":   q.ProductID,
+		"status":       q.Status,
+		"q":            q.Q,
+		"created_from": q.CreatedFrom,
+		"created_to":   q.CreatedTo,
+	}
+	path := q.path
+	if path == "" {
+		path = "/internal/orders"
+		filters["customer_id"] = q.CustomerID
+	}
+	for key, value := range filters {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	return fmt.Sprintf("%s%s?%s", ctx.HostName(), path, params.Encode())
+}
+
+func encodeCursor(c orderCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*orderCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c orderCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if c.ID == "" {
+		return nil, fmt.Errorf("cursor has no id")
+	}
+	return &c, nil
+}
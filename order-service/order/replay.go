@@ -0,0 +1,266 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RebuildView rebuilds order_view without ever serving a partial one. The
+// new view is built in order_view_rebuild: every order of the orders
+// collection is seeded first, so orders whose events Kafka no longer keeps
+// are not lost, then every lifecycle event still kept is folded in, from
+// the first offset of each partition up to the last one at the time of the
+// call. user_erased is replayed last so the names it removed stay removed.
+// Only when that succeeds does the new view replace order_view.
+//
+// The service can keep consuming while a rebuild runs. What it folds into
+// the old view in the meantime is replayed once more into the new one right
+// after the swap; applying events is idempotent, so nothing is lost or
+// counted twice.
+func RebuildView(ctx context.Context, db *mongo.Database, brokers []string) error {
+	live := db.Collection("order_view")
+	rebuild := db.Collection("order_view_rebuild")
+	if err := rebuild.Drop(ctx); err != nil {
+		return fmt.Errorf("clear %s: %w", rebuild.Name(), err)
+	}
+	if err := copyIndexes(ctx, live, rebuild); err != nil {
+		return err
+	}
+	n, err := seedViews(ctx, db.Collection("orders"), rebuild)
+	if err != nil {
+		return fmt.Errorf("seed %s: %w", rebuild.Name(), err)
+	}
+	log.Printf("seeded %d orders", n)
+
+	ends, err := replayEvents(ctx, brokers, rebuild, nil)
+	if err != nil {
+		return err
+	}
+
+	err = db.Client().Database("admin").RunCommand(ctx, bson.D{
+		{Key: "renameCollection", Value: db.Name() + "." + rebuild.Name()},
+		{Key: "to", Value: db.Name() + "." + live.Name()},
+		{Key: "dropTarget", Value: true},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("replace %s: %w", live.Name(), err)
+	}
+
+	_, err = replayEvents(ctx, brokers, live, ends)
+	return err
+}
+
+// copyIndexes creates the indexes of from on to, so the rebuilt view is
+// searchable the moment it replaces the old one. The index documents are
+// passed back as listed, which keeps text index weights intact.
+func copyIndexes(ctx context.Context, from, to *mongo.Collection) error {
+	cursor, err := from.Indexes().List(ctx)
+	if err != nil {
+		return fmt.Errorf("list %s indexes: %w", from.Name(), err)
+	}
+	var specs []bson.M
+	if err := cursor.All(ctx, &specs); err != nil {
+		return fmt.Errorf("list %s indexes: %w", from.Name(), err)
+	}
+	var indexes bson.A
+	for _, spec := range specs {
+		if spec["name"] == "_id_" {
+			continue
+		}
+		delete(spec, "v")
+		delete(spec, "ns")
+		indexes = append(indexes, spec)
+	}
+	if len(indexes) == 0 {
+		return nil
+	}
+	err = to.Database().RunCommand(ctx, bson.D{
+		{Key: "createIndexes", Value: to.Name()},
+		{Key: "indexes", Value: indexes},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("create %s indexes: %w", to.Name(), err)
+	}
+	return nil
+}
+
+// seedViews writes a view of every order in orders to col, with the state
+// the order itself records. The events replayed afterwards add the
+// timeline and the customer name but cannot take the status back, so an
+// event that was only folded into the old view, see announce, is not lost.
+func seedViews(ctx context.Context, orders, col *mongo.Collection) (int, error) {
+	cursor, err := orders.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	const batch = 500
+	n := 0
+	views := make([]any, 0, batch)
+	flush := func() error {
+		if len(views) == 0 {
+			return nil
+		}
+		if _, err := col.InsertMany(ctx, views); err != nil {
+			return err
+		}
+		n += len(views)
+		views = views[:0]
+		return nil
+	}
+	for cursor.Next(ctx) {
+		var o Order
+		if err := cursor.Decode(&o); err != nil {
+			return n, err
+		}
+		createdAt, _ := time.Parse(time.RFC3339, o.CreatedAt)
+		updatedAt, _ := time.Parse(time.RFC3339, o.UpdatedAt)
+		views = append(views, OrderView{
+			ID:         o.ID,
+			CustomerID: o.CustomerID,
+			Items:      o.Items,
+			TotalPrice: o.TotalPrice,
+			Currency:   o.Currency,
+			Status:     o.Status,
+			Timeline:   []StatusChange{},
+			CreatedAt:  createdAt,
+			UpdatedAt:  updatedAt,
+			Version:    1,
+		})
+		if len(views) == batch {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+// replayEvents folds the lifecycle events and then user_erased into col,
+// each partition from its offset in from, or its first offset when from
+// has none. It returns the offset each partition was replayed up to.
+func replayEvents(ctx context.Context, brokers []string, col *mongo.Collection, from map[string]map[int]int64) (map[string]map[int]int64, error) {
+	ends := map[string]map[int]int64{}
+	for _, topic := range LifecycleTopics {
+		end, err := replayTopic(ctx, brokers, topic, from[topic], func(value []byte) error {
+			var m EventMessage
+			if err := json.Unmarshal(value, &m); err != nil || m.EventID == "" || m.Body.OrderID == "" {
+				log.Printf("replay %s: skipping malformed event: %s", topic, value)
+				return nil
+			}
+			_, err := applyEvent(ctx, col, topic, m)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		ends[topic] = end
+	}
+
+	end, err := replayTopic(ctx, brokers, "user_erased", from["user_erased"], func(value []byte) error {
+		var data struct {
+			Body UserErasedEvent `json:"body"`
+		}
+		if err := json.Unmarshal(value, &data); err != nil || data.Body.UserID == "" {
+			log.Printf("replay user_erased: skipping malformed event: %s", value)
+			return nil
+		}
+		_, err := scrubViewCustomer(ctx, col, data.Body.UserID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	ends["user_erased"] = end
+	return ends, nil
+}
+
+// replayTopic calls apply with the value of every message of topic, one
+// partition after the other, starting each partition at its offset in from.
+// It returns the offset each partition was replayed up to. A topic that
+// does not exist has nothing to replay.
+func replayTopic(ctx context.Context, brokers []string, topic string, from map[int]int64, apply func([]byte) error) (map[int]int64, error) {
+	conn, err := kafkago.DialContext(ctx, "tcp", brokers[0])
+	if err != nil {
+		return nil, fmt.Errorf("replay %s: %w", topic, err)
+	}
+	partitions, err := conn.ReadPartitions(topic)
+	conn.Close()
+	if errors.Is(err, kafkago.UnknownTopicOrPartition) {
+		log.Printf("replay %s: topic does not exist", topic)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("replay %s: %w", topic, err)
+	}
+
+	ends := map[int]int64{}
+	for _, p := range partitions {
+		n, end, err := replayPartition(ctx, brokers, topic, p.ID, from[p.ID], apply)
+		if err != nil {
+			return nil, fmt.Errorf("replay %s[%d]: %w", topic, p.ID, err)
+		}
+		ends[p.ID] = end
+		log.Printf("replay %s[%d]: %d events", topic, p.ID, n)
+	}
+	return ends, nil
+}
+
+// replayPartition applies the messages of one partition from offset from,
+// or its first offset if that is later, up to its last offset. It returns
+// how many it applied and the offset it stopped at.
+func replayPartition(ctx context.Context, brokers []string, topic string, partition int, from int64, apply func([]byte) error) (int, int64, error) {
+	leader, err := kafkago.DialLeader(ctx, "tcp", brokers[0], topic, partition)
+	if err != nil {
+		return 0, 0, err
+	}
+	first, last, err := leader.ReadOffsets()
+	leader.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+	if from > first {
+		first = from
+	}
+	if first >= last {
+		return 0, last, nil
+	}
+
+	reader := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     topic,
+		Partition: partition,
+		MaxBytes:  10e6,
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(first); err != nil {
+		return 0, 0, err
+	}
+
+	n := 0
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return n, 0, err
+		}
+		if err := apply(msg.Value); err != nil {
+			return n, 0, err
+		}
+		n++
+		if msg.Offset+1 >= last {
+			return n, last, nil
+		}
+	}
+}
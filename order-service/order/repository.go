@@ -1,16 +1,25 @@
 package order
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
 	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Repository interface {
 	CreateOrder(ctx *kp.Context, order Order) (Order, error)
+	FindByCustomer(ctx *kp.Context, customerID string) ([]Order, error)
+	FindByID(ctx *kp.Context, id string) (Order, error)
+	UpdateStatus(ctx *kp.Context, id string, from []string, to string) (Order, error)
+	ScrubCustomer(ctx *kp.Context, customerID string) (int64, error)
 }
 
 type repository struct {
@@ -43,9 +52,13 @@ func (r *repository) CreateOrder(ctx *kp.Context, order Order) (Order, error) {
 		ctx.Log().Error(logger.NewDBRequest(logger.INSERT, "insert order"), map[string]string{
 			"error": err.Error(),
 		})
-		return Order{}, err
+		return Order{}, apperror.Internal(err)
 	}
 	order.ID = id.String()
+	order.Status = "pending"
+	now := time.Now().UTC().Format(time.RFC3339)
+	order.CreatedAt = now
+	order.UpdatedAt = now
 
 	result, err := r.col.InsertOne(ctx, order)
 	summary.ResTime = time.Since(start).Milliseconds()
@@ -55,7 +68,7 @@ func (r *repository) CreateOrder(ctx *kp.Context, order Order) (Order, error) {
 		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.INSERT, "insert order failed"), map[string]string{
 			"error": err.Error(),
 		})
-		return Order{}, err
+		return Order{}, apperror.Internal(err)
 	}
 
 	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.INSERT, "insert order success"), map[string]any{
@@ -64,3 +77,197 @@ func (r *repository) CreateOrder(ctx *kp.Context, order Order) (Order, error) {
 
 	return order, nil
 }
+
+func (r *repository) FindByCustomer(ctx *kp.Context, customerID string) ([]Order, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "find_orders_by_customer",
+		Code:        "200",
+		Description: "success",
+	}
+
+	filter := bson.M{"customerid": customerID}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find orders by customer"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	cursor, err := r.col.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdat", Value: -1}}))
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to find orders"
+		summary.ResTime = time.Since(start).Milliseconds()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find orders failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	orders := []Order{}
+	err = cursor.All(ctx, &orders)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to decode orders"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find orders failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find orders success"), map[string]any{
+		"count": len(orders),
+	})
+	return orders, nil
+}
+
+func (r *repository) FindByID(ctx *kp.Context, id string) (Order, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "find_order_by_id",
+		Code:        "200",
+		Description: "success",
+	}
+
+	filter := bson.M{"id": id}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find order by id"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	var order Order
+	err := r.col.FindOne(ctx, filter).Decode(&order)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			summary.Code = "404"
+			summary.Description = "order not found"
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find order failed"), map[string]string{
+				"error": err.Error(),
+			})
+			return Order{}, ErrOrderNotFound
+		}
+		summary.Code = "500"
+		summary.Description = "failed to find order"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find order failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Order{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find order success"), map[string]any{
+		"Return": order,
+	})
+	return order, nil
+}
+
+var errOrderStatus = apperror.Conflict("order_status_conflict", "status", "the order cannot move to this status from its current one")
+
+// UpdateStatus moves the order to status to, provided it is in one of the
+// statuses from. It returns the updated order.
+func (r *repository) UpdateStatus(ctx *kp.Context, id string, from []string, to string) (Order, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "update_order_status",
+		Code:        "200",
+		Description: "success",
+	}
+
+	filter := bson.M{"id": id, "status": bson.M{"$in": from}}
+	update := bson.M{"$set": bson.M{
+		"status":    to,
+		"updatedat": time.Now().UTC().Format(time.RFC3339),
+	}}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "update order status"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"update":     update,
+	})
+
+	var order Order
+	err := r.col.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&order)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		var n int64
+		n, err = r.col.CountDocuments(ctx, bson.M{"id": id})
+		if err == nil {
+			summary.ResTime = time.Since(start).Milliseconds()
+			if n == 0 {
+				summary.Code = "404"
+				summary.Description = "order not found"
+				ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "update order status failed"), map[string]string{
+					"error": "order not found",
+				})
+				return Order{}, ErrOrderNotFound
+			}
+			summary.Code = "409"
+			summary.Description = "order status conflict"
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "update order status failed"), map[string]string{
+				"error": "order is not in any of the statuses " + fmt.Sprint(from),
+			})
+			return Order{}, errOrderStatus
+		}
+	}
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to update order status"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "update order status failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Order{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "update order status success"), map[string]any{
+		"Return": order,
+	})
+	return order, nil
+}
+
+// ScrubCustomer blanks who the orders of an erased customer were shipped
+// to. The city, postal code and country stay for the tax records.
+func (r *repository) ScrubCustomer(ctx *kp.Context, customerID string) (int64, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "scrub_order_customer",
+		Code:        "200",
+		Description: "success",
+	}
+
+	filter := bson.M{"customerid": customerID, "shippingaddress": bson.M{"$type": "object"}}
+	update := bson.M{
+		"$set": bson.M{
+			"shippingaddress.name":  "",
+			"shippingaddress.line1": "",
+		},
+		"$unset": bson.M{
+			"shippingaddress.label": "",
+			"shippingaddress.phone": "",
+			"shippingaddress.line2": "",
+		},
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "scrub order customer"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"update":     update,
+	})
+
+	result, err := r.col.UpdateMany(ctx, filter, update)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to scrub orders"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "scrub order customer failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return 0, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "scrub order customer success"), map[string]any{
+		"Return": result,
+	})
+	return result.ModifiedCount, nil
+}
@@ -1,13 +1,47 @@
 package order
 
 import (
+	"time"
+
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-order-service/cache"
+	"github.com/sing3demons/go-order-service/pricing"
+	"github.com/sing3demons/go-order-service/promotion"
+	"github.com/sing3demons/go-order-service/servicetoken"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func RegisterRoutes(app kp.IApplication, col *mongo.Collection) {
-	repo := NewRepository(col)
-	service := NewOrderService(repo)
+// RegisterRoutes registers the order routes and consumers and returns the
+// service behind them for the packages that build on orders.
+func RegisterRoutes(app kp.IApplication, db *mongo.Database, c cache.Cache, ttl time.Duration, promotions promotion.Service, pricer *pricing.Pricer, shippingFee float64, guard *servicetoken.Guard) OrderService {
+	repo := NewRepository(db.Collection("orders"))
+	history := NewHistoryRepository(db.Collection("order_history"))
+	views := NewViewRepository(db.Collection("order_view"))
+	service := NewOrderService(repo, history, views, c, ttl, promotions, pricer, shippingFee)
 	handler := NewHandler(service)
 	app.Post("/orders", handler.HandleCreateOrder)
+	app.Get("/orders/{id}", handler.HandleGetOrder)
+	app.Get("/customers/{id}/orders", handler.HandleListCustomerOrders)
+
+	// Internal routes for the other services, see servicetoken: user-service
+	// reads everything stored about a customer for its data export, and
+	// product-service reads the order a review is for to know who bought
+	// it. Listing every customer's orders tells whose they are, so the
+	// public list is always scoped to one customer and leaves them out; the
+	// search over all of them is for operators with an admin token.
+	app.Get("/internal/customers/{id}/data", guard.Require(handler.HandleGetCustomerData))
+	app.Get("/internal/orders", guard.Admin().Require(handler.HandleListOrders))
+	app.Get("/internal/orders/{id}", guard.Require(handler.HandleGetInternalOrder))
+
+	app.Consumer("user_erased", handler.HandleUserErased)
+	for _, topic := range UserTopics {
+		app.Consumer(topic, handler.HandleUserChanged(topic))
+	}
+	for _, topic := range ProductTopics {
+		app.Consumer(topic, handler.HandleProductChanged(topic))
+	}
+	for _, topic := range LifecycleTopics {
+		app.Consumer(topic, handler.HandleOrderEvent(topic))
+	}
+	return service
 }
@@ -2,64 +2,282 @@ package order
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
+	"github.com/google/uuid"
+	config "github.com/sing3demons/go-common-kp/kp/configs"
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
 	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/cache"
+	"github.com/sing3demons/go-order-service/pricing"
+	"github.com/sing3demons/go-order-service/promotion"
+	"github.com/sing3demons/go-order-service/servicetoken"
 )
 
 type OrderService interface {
 	CreateOrder(ctx *kp.Context, order Order) (Order, error)
-	// GetOrderByID(id string) (Order, error)
+	GetCustomerData(ctx *kp.Context, customerID string) (CustomerData, error)
+	EraseCustomer(ctx *kp.Context, customerID string) error
+	InvalidateCustomer(ctx *kp.Context, customerID string) error
+	InvalidateProduct(ctx *kp.Context, productID string) error
+	CancelOrder(ctx *kp.Context, id, reason string) (Order, error)
+	GetOrder(ctx *kp.Context, id string) (*OrderView, error)
+	ListOrders(ctx *kp.Context, q OrderQuery) (OrderPage, error)
+	ApplyOrderEvent(ctx *kp.Context, topic string, m EventMessage) error
+	GetProduct(ctx *kp.Context, productID string) (ProductModel, error)
+	GetOrderByID(ctx *kp.Context, id string) (Order, error)
+	MarkPaid(ctx *kp.Context, id, paymentID string) (Order, error)
+	MarkShipped(ctx *kp.Context, id, shipmentID string) (Order, error)
+	MarkDelivered(ctx *kp.Context, id, shipmentID string) (Order, error)
 	// UpdateOrder(order Order) (Order, error)
 	// DeleteOrder(id string) error
 	// ListOrders(customerID string) ([]Order, error)
 	// CalculateTotalPrice(order Order) float64
 }
 type orderService struct {
-	repo Repository
+	repo        Repository
+	history     HistoryRepository
+	views       ViewRepository
+	cache       cache.Cache
+	ttl         time.Duration
+	promotions  promotion.Service
+	pricer      *pricing.Pricer
+	shippingFee float64
 }
 
-func NewOrderService(repo Repository) OrderService {
+// NewOrderService reads customers and products through c, keeping each
+// for ttl unless an event of UserTopics or ProductTopics drops it first.
+// Orders are discounted through promotions, converted and taxed by pricer
+// and charged shippingFee.
+func NewOrderService(repo Repository, history HistoryRepository, views ViewRepository, c cache.Cache, ttl time.Duration, promotions promotion.Service, pricer *pricing.Pricer, shippingFee float64) OrderService {
 	return &orderService{
-		repo: repo,
+		repo:        repo,
+		history:     history,
+		views:       views,
+		cache:       c,
+		ttl:         ttl,
+		promotions:  promotions,
+		pricer:      pricer,
+		shippingFee: shippingFee,
 	}
 }
 
+// ShippingFee reads SHIPPING_FEE, the flat fee charged on every order in
+// the base currency; nothing by default.
+func ShippingFee(conf *config.Config) float64 {
+	fee, err := strconv.ParseFloat(conf.GetOrDefault("SHIPPING_FEE", "0"), 64)
+	if err != nil || fee < 0 {
+		return 0
+	}
+	return fee
+}
+
+func (s *orderService) GetCustomerData(ctx *kp.Context, customerID string) (CustomerData, error) {
+	orders, err := s.repo.FindByCustomer(ctx, customerID)
+	if err != nil {
+		return CustomerData{}, err
+	}
+	history, err := s.history.FindByCustomer(ctx, customerID)
+	if err != nil {
+		return CustomerData{}, err
+	}
+	return CustomerData{
+		CustomerID:   customerID,
+		Orders:       orders,
+		OrderHistory: history,
+	}, nil
+}
+
+func (s *orderService) EraseCustomer(ctx *kp.Context, customerID string) error {
+	if _, err := s.repo.ScrubCustomer(ctx, customerID); err != nil {
+		return err
+	}
+	if _, err := s.history.ScrubCustomer(ctx, customerID); err != nil {
+		return err
+	}
+	if _, err := s.views.ScrubCustomer(ctx, customerID); err != nil {
+		return err
+	}
+	return s.InvalidateCustomer(ctx, customerID)
+}
+
+func (s *orderService) InvalidateCustomer(ctx *kp.Context, customerID string) error {
+	return invalidate(ctx, s.cache, "invalidate_user", userCacheKey(customerID))
+}
+
+func (s *orderService) InvalidateProduct(ctx *kp.Context, productID string) error {
+	return invalidate(ctx, s.cache, "invalidate_product", productCacheKey(productID))
+}
+
+func (s *orderService) getUser(ctx *kp.Context, userID string) (UserModel, error) {
+	return readThrough(ctx, s.cache, s.ttl, "get_user_by_id", userCacheKey(userID), func() (UserModel, error) {
+		return getUserByID(ctx, userID)
+	})
+}
+
+// GetProduct returns the product through the lookup cache.
+func (s *orderService) GetProduct(ctx *kp.Context, productID string) (ProductModel, error) {
+	return readThrough(ctx, s.cache, s.ttl, "get_product_by_id", productCacheKey(productID), func() (ProductModel, error) {
+		return getProductByID(ctx, productID)
+	})
+}
+
 func (s *orderService) CreateOrder(ctx *kp.Context, order Order) (Order, error) {
 
-	user, err := getUserByID(ctx, order.CustomerID)
+	user, err := s.getUser(ctx, order.CustomerID)
+	if err != nil {
+		return Order{}, err
+	}
+
+	order.ShippingAddress = nil
+	if order.AddressID != "" {
+		address, err := getAddress(ctx, order.CustomerID, order.AddressID)
+		if err != nil {
+			return Order{}, err
+		}
+		if order.Region != "" && order.Region != address.Country {
+			return Order{}, errAddressRegion
+		}
+		order.Region = address.Country
+		order.ShippingAddress = &address
+	}
+
+	quote, err := s.pricer.Quote(order.Currency, order.Region)
 	if err != nil {
 		return Order{}, err
 	}
 
 	products := []ProductModel{}
-	for _, item := range order.Items {
-		product, err := getProductByID(ctx, item.ID)
+	basket := promotion.Basket{ShippingFee: quote.Convert(s.shippingFee), Rate: quote.Rate}
+	lines := []pricing.Line{}
+	for i := range order.Items {
+		item := &order.Items[i]
+		product, err := s.GetProduct(ctx, item.ID)
 		if err != nil {
 			return Order{}, err
 		}
+		price, err := strconv.ParseFloat(product.Price, 64)
+		if err != nil {
+			return Order{}, apperror.Internal(err)
+		}
+		item.Name = product.Name
+		item.Category = product.Category
+		item.Price = quote.Convert(price)
 		products = append(products, product)
+		basket.Lines = append(basket.Lines, promotion.Line{ProductID: item.ID, Quantity: item.Quantity, Price: item.Price})
+		basket.Subtotal += item.Price * float64(item.Quantity)
+		lines = append(lines, pricing.Line{Category: item.Category, Quantity: item.Quantity, UnitPrice: item.Price})
 	}
+	basket.Subtotal = pricing.Round(basket.Subtotal)
+
+	var discounts []promotion.Discount
+	if len(order.PromoCodes) > 0 {
+		discounts, err = s.promotions.Apply(ctx, order.CustomerID, order.PromoCodes, basket)
+		if err != nil {
+			return Order{}, err
+		}
+	}
+	var itemDiscount, shippingDiscount float64
+	for _, d := range discounts {
+		if d.Type == promotion.TypeFreeShipping {
+			shippingDiscount += d.Amount
+		} else {
+			itemDiscount += d.Amount
+		}
+	}
+
+	totals := quote.Price(lines, basket.ShippingFee, itemDiscount, shippingDiscount)
+	for i, lt := range totals.Lines {
+		item := &order.Items[i]
+		item.Subtotal = lt.Subtotal
+		item.Discount = lt.Discount
+		item.Taxes = lt.Taxes
+		item.Tax = lt.Tax
+		item.Total = lt.Total
+	}
+	order.Currency = quote.Currency
+	order.Region = quote.Region
+	order.FXRate = quote.Rate
+	order.Subtotal = totals.Subtotal
+	order.ShippingFee = totals.Shipping
+	order.Discounts = discounts
+	order.DiscountTotal = totals.Discount
+	order.Taxes = totals.Taxes
+	order.TaxTotal = totals.Tax
+	order.TotalPrice = totals.Total
 
 	o, err := s.repo.CreateOrder(ctx, order)
 	if err != nil {
+		// The codes were not used after all.
+		s.promotions.Release(ctx, order.CustomerID, discounts)
 		return Order{}, err
 	}
 
+	// The order is stored and its promo codes are spent, so from here on
+	// nothing fails the request: an error would make the client retry and
+	// place the order twice or trip the usage limit of its codes.
+	s.publishCreated(ctx, o, user, products)
+	return o, nil
+}
+
+// publishCreated announces a stored order. The failures are logged by the
+// publishers and otherwise ignored, see announce for order_created.
+func (s *orderService) publishCreated(ctx *kp.Context, o Order, user UserModel, products []ProductModel) {
 	data := map[string]any{
 		"body": map[string]any{
 			"order_id":    o.ID,
 			"customer":    user,
 			"products":    products,
 			"total_price": o.TotalPrice,
+			"currency":    o.Currency,
 		},
 	}
+	publishHistory(ctx, data)
+
+	event := OrderEvent{
+		OrderID:      o.ID,
+		CustomerID:   o.CustomerID,
+		CustomerName: displayName(user),
+		Items:        o.Items,
+		TotalPrice:   o.TotalPrice,
+		Currency:     o.Currency,
+	}
+	s.announce(ctx, orderCreatedTopic, event)
+}
 
+// announce publishes a lifecycle event. The order is already stored, so a
+// failed publish is not the caller's error: the event is logged and folded
+// into the view here instead, so reads still see the new status.
+func (s *orderService) announce(ctx *kp.Context, topic string, event OrderEvent) {
+	if err := publishEvent(ctx, topic, event); err == nil {
+		return
+	}
+	id, err := uuid.NewV7()
+	if err == nil {
+		_, err = s.views.Apply(ctx, topic, EventMessage{
+			EventID:    id.String(),
+			OccurredAt: time.Now().UTC(),
+			Body:       event,
+		})
+	}
+	if err != nil {
+		ctx.Log().Error(logger.NewDBResponse(logger.UPDATE, "apply "+topic), map[string]string{
+			"order_id": event.OrderID,
+			"error":    err.Error(),
+		})
+	}
+}
+
+// publishHistory sends the order history record to create_order_history,
+// logging a failure.
+func publishHistory(ctx *kp.Context, data map[string]any) {
 	start := time.Now()
 	summary := logger.LogEventTag{
 		Node:        "kafka",
@@ -70,7 +288,12 @@ func (s *orderService) CreateOrder(ctx *kp.Context, order Order) (Order, error)
 	}
 	message, err := json.Marshal(data)
 	if err != nil {
-		return Order{}, err
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewProduced(summary.Command, ""), map[string]string{
+			"error": err.Error(),
+		})
+		return
 	}
 	ctx.Log().Info(logger.NewProducing(summary.Command, ""), map[string]any{
 		"topic":  summary.Command,
@@ -85,16 +308,121 @@ func (s *orderService) CreateOrder(ctx *kp.Context, order Order) (Order, error)
 		ctx.Log().SetSummary(summary).Error(logger.NewProduced(summary.Command, ""), map[string]string{
 			"error": err.Error(),
 		})
-		return Order{}, err
+		return
 	}
 	summary.ResTime = time.Since(start).Milliseconds()
 	ctx.Log().SetSummary(summary).Info(logger.NewProduced(summary.Command, ""), map[string]any{
 		"topic":  summary.Command,
 		"broker": "localhost:9092",
 	})
+}
+
+// CancelOrder cancels an order that has not shipped yet and announces
+// order_canceled. An order canceled already is returned as is, so a cancel
+// retried to settle its payments announces it once.
+func (s *orderService) CancelOrder(ctx *kp.Context, id, reason string) (Order, error) {
+	o, err := s.repo.UpdateStatus(ctx, id, []string{"pending", "paid"}, "canceled")
+	if errors.Is(err, errOrderStatus) {
+		current, findErr := s.repo.FindByID(ctx, id)
+		if findErr == nil && current.Status == "canceled" {
+			return current, nil
+		}
+	}
+	if err != nil {
+		return Order{}, err
+	}
+	s.announce(ctx, orderCanceledTopic, OrderEvent{
+		OrderID:    o.ID,
+		CustomerID: o.CustomerID,
+		Reason:     reason,
+	})
+	return o, nil
+}
+
+func (s *orderService) GetOrderByID(ctx *kp.Context, id string) (Order, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// MarkPaid moves a pending order to paid and publishes order_paid. An order
+// that is already paid is returned as is, so a capture reported twice, once
+// by the API call and once by the webhook, pays it once.
+func (s *orderService) MarkPaid(ctx *kp.Context, id, paymentID string) (Order, error) {
+	o, err := s.repo.UpdateStatus(ctx, id, []string{"pending"}, "paid")
+	if errors.Is(err, errOrderStatus) {
+		current, findErr := s.repo.FindByID(ctx, id)
+		if findErr == nil && current.Status == "paid" {
+			return current, nil
+		}
+	}
+	if err != nil {
+		return Order{}, err
+	}
+	s.announce(ctx, orderPaidTopic, OrderEvent{
+		OrderID:    o.ID,
+		CustomerID: o.CustomerID,
+		PaymentID:  paymentID,
+	})
 	return o, nil
 }
 
+// MarkShipped moves a paid order to shipped when its first shipment leaves
+// and publishes order_shipped. Later shipments of the same order find it
+// shipped already and change nothing.
+func (s *orderService) MarkShipped(ctx *kp.Context, id, shipmentID string) (Order, error) {
+	return s.advance(ctx, id, []string{"paid"}, "shipped", orderShippedTopic, shipmentID)
+}
+
+// MarkDelivered moves a shipped order to delivered and publishes
+// order_delivered. It is up to the caller to know every item arrived.
+func (s *orderService) MarkDelivered(ctx *kp.Context, id, shipmentID string) (Order, error) {
+	return s.advance(ctx, id, []string{"shipped"}, "delivered", orderDeliveredTopic, shipmentID)
+}
+
+// advance moves the order from one of the statuses from to to and
+// announces topic. An order already in status to is returned as is, so the
+// event is announced once, by the call that moved the order.
+func (s *orderService) advance(ctx *kp.Context, id string, from []string, to, topic, shipmentID string) (Order, error) {
+	o, err := s.repo.UpdateStatus(ctx, id, from, to)
+	if errors.Is(err, errOrderStatus) {
+		current, findErr := s.repo.FindByID(ctx, id)
+		if findErr == nil && current.Status == to {
+			return current, nil
+		}
+	}
+	if err != nil {
+		return Order{}, err
+	}
+	s.announce(ctx, topic, OrderEvent{
+		OrderID:    o.ID,
+		CustomerID: o.CustomerID,
+		ShipmentID: shipmentID,
+	})
+	return o, nil
+}
+
+func (s *orderService) GetOrder(ctx *kp.Context, id string) (*OrderView, error) {
+	return s.views.FindByID(ctx, id)
+}
+
+func (s *orderService) ListOrders(ctx *kp.Context, q OrderQuery) (OrderPage, error) {
+	views, err := s.views.Find(ctx, q)
+	if err != nil {
+		return OrderPage{}, err
+	}
+	page := OrderPage{Next: q.next(ctx, views)}
+	if len(views) > q.Limit {
+		views = views[:q.Limit]
+	}
+	page.Orders = views
+	page.Count = len(views)
+	return page, nil
+}
+
+func (s *orderService) ApplyOrderEvent(ctx *kp.Context, topic string, m EventMessage) error {
+	_, err := s.views.Apply(ctx, topic, m)
+	return err
+}
+
 type HttpRequest struct {
 	URL      string            `json:"url"`
 	Headers  map[string]string `json:"headers"`
@@ -106,6 +434,21 @@ type HttpRequest struct {
 
 const contentTypeHeader = "Content-Type"
 
+// customerFields and productFields are the sparse fieldsets asked of
+// user-service and product-service: only what the order history needs.
+const (
+	customerFields = "id,first_name,last_name,username,email"
+	productFields  = "id,name,price,category"
+)
+
+var (
+	ErrCustomerNotFound = apperror.NotFound("customer_not_found", "customer not found")
+	ErrProductNotFound  = apperror.NotFound("product_not_found", "product not found")
+	errUserService      = apperror.Upstream("user_service_error", "user-service could not provide the customer", nil)
+	errProductService   = apperror.Upstream("product_service_error", "product-service could not provide the product", nil)
+	errCache            = apperror.Upstream("cache_error", "the lookup cache could not be updated", nil)
+)
+
 func getUserByID(ctx *kp.Context, userID string) (UserModel, error) {
 	start := time.Now()
 	summary := logger.LogEventTag{
@@ -122,9 +465,9 @@ func getUserByID(ctx *kp.Context, userID string) (UserModel, error) {
 	}
 
 	httpRequest := HttpRequest{
-		URL:      userServiceURL + "/users/" + userID,
+		URL:      userServiceURL + "/internal/users/" + userID + "?fields=" + customerFields,
 		Headers:  map[string]string{contentTypeHeader: "application/json"},
-		Params:   map[string]string{"user_id": userID},
+		Params:   map[string]string{"user_id": userID, "fields": customerFields},
 		Protocol: "http",
 		Method:   http.MethodGet,
 		Timeout:  10 * time.Second,
@@ -140,12 +483,16 @@ func getUserByID(ctx *kp.Context, userID string) (UserModel, error) {
 	})
 	req, err := http.NewRequest(http.MethodGet, httpRequest.URL, nil)
 	if err != nil {
-		return UserModel{}, err
+		return UserModel{}, errUserService.Wrap(err)
 	}
 	req.Header.Set(contentTypeHeader, httpRequest.Headers[contentTypeHeader])
+	if err := servicetoken.Authorize(req, servicetoken.UserService); err != nil {
+		return UserModel{}, errUserService.Wrap(err)
+	}
 
 	httpClient := &http.Client{
-		Timeout: httpRequest.Timeout,
+		Timeout:   httpRequest.Timeout,
+		Transport: lookupTransport,
 	}
 
 	resp, err := httpClient.Do(req)
@@ -156,7 +503,7 @@ func getUserByID(ctx *kp.Context, userID string) (UserModel, error) {
 		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("http get user", ""), map[string]string{
 			"error": err.Error(),
 		})
-		return UserModel{}, err
+		return UserModel{}, errUserService.Wrap(err)
 	}
 	defer resp.Body.Close()
 
@@ -166,7 +513,10 @@ func getUserByID(ctx *kp.Context, userID string) (UserModel, error) {
 		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get user by id failed", ""), map[string]string{
 			"error": fmt.Sprintf("failed to get user by ID: %s", resp.Status),
 		})
-		return UserModel{}, fmt.Errorf("failed to get user by ID: %s", resp.Status)
+		if resp.StatusCode == http.StatusNotFound {
+			return UserModel{}, ErrCustomerNotFound
+		}
+		return UserModel{}, errUserService.Wrap(fmt.Errorf("failed to get user by ID: %s", resp.Status))
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
@@ -176,7 +526,7 @@ func getUserByID(ctx *kp.Context, userID string) (UserModel, error) {
 		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get user by ID failed", ""), map[string]string{
 			"error": err.Error(),
 		})
-		return UserModel{}, err
+		return UserModel{}, errUserService.Wrap(err)
 	}
 
 	var user UserModel
@@ -186,7 +536,7 @@ func getUserByID(ctx *kp.Context, userID string) (UserModel, error) {
 		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get user by ID failed", ""), map[string]string{
 			"error": err.Error(),
 		})
-		return UserModel{}, err
+		return UserModel{}, errUserService.Wrap(err)
 	}
 
 	ctx.Log().SetSummary(summary).Info(logger.NewHTTPResponse("get user by ID success", ""), map[string]any{
@@ -212,9 +562,9 @@ func getProductByID(ctx *kp.Context, productID string) (ProductModel, error) {
 		productServiceURL = "http://localhost:8082" // Default URL if not set
 	}
 	httpRequest := HttpRequest{
-		URL:      productServiceURL + "/products/" + productID,
+		URL:      productServiceURL + "/internal/products/" + productID + "?fields=" + productFields,
 		Headers:  map[string]string{contentTypeHeader: "application/json"},
-		Params:   map[string]string{"product_id": productID},
+		Params:   map[string]string{"product_id": productID, "fields": productFields},
 		Protocol: "http",
 		Method:   http.MethodGet,
 		Timeout:  10 * time.Second,
@@ -235,12 +585,16 @@ func getProductByID(ctx *kp.Context, productID string) (ProductModel, error) {
 		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("http get product", ""), map[string]string{
 			"error": err.Error(),
 		})
-		return ProductModel{}, err
+		return ProductModel{}, errProductService.Wrap(err)
 	}
 	req.Header.Set(contentTypeHeader, httpRequest.Headers[contentTypeHeader])
+	if err := servicetoken.Authorize(req, servicetoken.ProductService); err != nil {
+		return ProductModel{}, errProductService.Wrap(err)
+	}
 
 	httpClient := &http.Client{
-		Timeout: httpRequest.Timeout,
+		Timeout:   httpRequest.Timeout,
+		Transport: lookupTransport,
 	}
 
 	resp, err := httpClient.Do(req)
@@ -251,7 +605,7 @@ func getProductByID(ctx *kp.Context, productID string) (ProductModel, error) {
 		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("http get product", ""), map[string]string{
 			"error": err.Error(),
 		})
-		return ProductModel{}, err
+		return ProductModel{}, errProductService.Wrap(err)
 	}
 	defer resp.Body.Close()
 
@@ -261,7 +615,10 @@ func getProductByID(ctx *kp.Context, productID string) (ProductModel, error) {
 		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get product by ID failed", ""), map[string]string{
 			"error": fmt.Sprintf("failed to get product by ID: %s", resp.Status),
 		})
-		return ProductModel{}, fmt.Errorf("failed to get product by ID: %s", resp.Status)
+		if resp.StatusCode == http.StatusNotFound {
+			return ProductModel{}, ErrProductNotFound
+		}
+		return ProductModel{}, errProductService.Wrap(fmt.Errorf("failed to get product by ID: %s", resp.Status))
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
@@ -271,7 +628,7 @@ func getProductByID(ctx *kp.Context, productID string) (ProductModel, error) {
 		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get product by ID failed", ""), map[string]string{
 			"error": err.Error(),
 		})
-		return ProductModel{}, err
+		return ProductModel{}, errProductService.Wrap(err)
 	}
 	var product ProductModel
 	if err := json.Unmarshal(bodyBytes, &product); err != nil {
@@ -280,7 +637,7 @@ func getProductByID(ctx *kp.Context, productID string) (ProductModel, error) {
 		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get product by ID failed", ""), map[string]string{
 			"error": err.Error(),
 		})
-		return ProductModel{}, err
+		return ProductModel{}, errProductService.Wrap(err)
 	}
 	ctx.Log().SetSummary(summary).Info(logger.NewHTTPResponse("get product by ID success", ""), map[string]any{
 		"Headers": resp.Header,
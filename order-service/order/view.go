@@ -0,0 +1,363 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OrderView is the order_view read model: one document per order, folded
+// from the lifecycle events so list and search never join orders, history
+// and user-service.
+type OrderView struct {
+	ID             string         `json:"id" bson:"_id"`
+	Href           string         `json:"href,omitempty" bson:"-"`
+	CustomerID     string         `json:"customer_id" bson:"customer_id"`
+	CustomerName   string         `json:"customer_name" bson:"customer_name"`
+	CustomerErased bool           `json:"customer_erased,omitempty" bson:"customer_erased,omitempty"`
+	Items          []Item         `json:"items" bson:"items"`
+	TotalPrice     float64        `json:"total_price" bson:"total_price"`
+	Currency       string         `json:"currency,omitempty" bson:"currency,omitempty"`
+	Status         string         `json:"status" bson:"status"`
+	Timeline       []StatusChange `json:"timeline" bson:"timeline"`
+	CreatedAt      time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at" bson:"updated_at"`
+	Version        int64          `json:"-" bson:"version"`
+}
+
+// PublicView is the part of a view anyone holding the order id may read:
+// what was ordered and where it stands, but not who ordered it.
+type PublicView struct {
+	ID         string         `json:"id"`
+	Href       string         `json:"href,omitempty"`
+	Items      []Item         `json:"items"`
+	TotalPrice float64        `json:"total_price"`
+	Currency   string         `json:"currency,omitempty"`
+	Status     string         `json:"status"`
+	Timeline   []StatusChange `json:"timeline"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// Public returns the view without the customer.
+func (v *OrderView) Public() PublicView {
+	return PublicView{
+		ID:         v.ID,
+		Href:       v.Href,
+		Items:      v.Items,
+		TotalPrice: v.TotalPrice,
+		Currency:   v.Currency,
+		Status:     v.Status,
+		Timeline:   v.Timeline,
+		CreatedAt:  v.CreatedAt,
+		UpdatedAt:  v.UpdatedAt,
+	}
+}
+
+// StatusChange is one entry of the status timeline.
+type StatusChange struct {
+	EventID string    `json:"-" bson:"event_id"`
+	Status  string    `json:"status" bson:"status"`
+	At      time.Time `json:"at" bson:"at"`
+	Reason  string    `json:"reason,omitempty" bson:"reason,omitempty"`
+}
+
+// Apply folds one lifecycle event into the view and reports whether it
+// changed anything. Events may come twice and in any order: a repeated
+// event_id is ignored and the timeline is kept sorted by time, the status
+// being that of its latest entry. So replaying every event, in whatever
+// order, always ends in the same view. The status never moves back, see
+// statusRank: a view seeded from the orders collection keeps the status
+// the order records even when the events that led there are gone.
+func (v *OrderView) Apply(topic string, m EventMessage) bool {
+	status, ok := lifecycleStatus[topic]
+	if !ok {
+		return false
+	}
+	for _, change := range v.Timeline {
+		if change.EventID == m.EventID {
+			return false
+		}
+	}
+
+	at := m.OccurredAt.UTC()
+	if topic == orderCreatedTopic {
+		v.CustomerID = m.Body.CustomerID
+		if !v.CustomerErased {
+			v.CustomerName = m.Body.CustomerName
+		}
+		v.Items = m.Body.Items
+		v.TotalPrice = m.Body.TotalPrice
+		v.Currency = m.Body.Currency
+		v.CreatedAt = at
+	}
+
+	v.Timeline = append(v.Timeline, StatusChange{
+		EventID: m.EventID,
+		Status:  status,
+		At:      at,
+		Reason:  m.Body.Reason,
+	})
+	sort.SliceStable(v.Timeline, func(i, j int) bool {
+		a, b := v.Timeline[i], v.Timeline[j]
+		if !a.At.Equal(b.At) {
+			return a.At.Before(b.At)
+		}
+		return a.EventID < b.EventID
+	})
+	last := v.Timeline[len(v.Timeline)-1]
+	if statusRank[last.Status] >= statusRank[v.Status] {
+		v.Status = last.Status
+	}
+	if last.At.After(v.UpdatedAt) {
+		v.UpdatedAt = last.At
+	}
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = v.Timeline[0].At
+	}
+	return true
+}
+
+// maxApplyAttempts bounds the retries of applyEvent when another consumer
+// changes the same view in between.
+const maxApplyAttempts = 5
+
+var errViewConflict = errors.New("order view kept changing while the event was applied")
+
+// applyEvent folds m into the view of its order. The write is guarded by the
+// view's version, so two events of one order consumed at the same time
+// cannot overwrite each other; the loser reads the view again and retries.
+func applyEvent(ctx context.Context, col *mongo.Collection, topic string, m EventMessage) (bool, error) {
+	for attempt := 0; attempt < maxApplyAttempts; attempt++ {
+		var view OrderView
+		err := col.FindOne(ctx, bson.M{"_id": m.Body.OrderID}).Decode(&view)
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			view = OrderView{ID: m.Body.OrderID}
+		case err != nil:
+			return false, err
+		}
+
+		version := view.Version
+		if !view.Apply(topic, m) {
+			return false, nil
+		}
+		view.Version++
+
+		if version == 0 {
+			_, err = col.InsertOne(ctx, view)
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			return err == nil, err
+		}
+		result, err := col.ReplaceOne(ctx, bson.M{"_id": view.ID, "version": version}, view)
+		if err != nil {
+			return false, err
+		}
+		if result.MatchedCount == 1 {
+			return true, nil
+		}
+	}
+	return false, errViewConflict
+}
+
+// scrubViewCustomer blanks the display name of every view of customerID and
+// marks it erased, so a later order_created replay does not bring it back.
+func scrubViewCustomer(ctx context.Context, col *mongo.Collection, customerID string) (*mongo.UpdateResult, error) {
+	return col.UpdateMany(ctx, bson.M{"customer_id": customerID}, bson.M{
+		"$set": bson.M{"customer_name": "", "customer_erased": true},
+		"$inc": bson.M{"version": 1},
+	})
+}
+
+// ViewRepository reads and maintains the order_view collection.
+type ViewRepository interface {
+	Apply(ctx *kp.Context, topic string, m EventMessage) (bool, error)
+	FindByID(ctx *kp.Context, id string) (*OrderView, error)
+	Find(ctx *kp.Context, q OrderQuery) ([]*OrderView, error)
+	ScrubCustomer(ctx *kp.Context, customerID string) (int64, error)
+}
+
+type viewRepository struct {
+	col *mongo.Collection
+}
+
+func NewViewRepository(col *mongo.Collection) ViewRepository {
+	return &viewRepository{
+		col: col,
+	}
+}
+
+var ErrOrderNotFound = apperror.NotFound("order_not_found", "order not found")
+
+func orderHref(ctx *kp.Context, id string) string {
+	return fmt.Sprintf("%s/orders/%s", ctx.HostName(), id)
+}
+
+func (r *viewRepository) Apply(ctx *kp.Context, topic string, m EventMessage) (bool, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "apply_order_event",
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "apply order event"), map[string]any{
+		"collection": r.col.Name(),
+		"topic":      topic,
+		"event_id":   m.EventID,
+		"order_id":   m.Body.OrderID,
+	})
+
+	applied, err := applyEvent(ctx, r.col, topic, m)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to apply order event"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "apply order event failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return false, apperror.Internal(err)
+	}
+	if !applied {
+		summary.Description = "already applied"
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "apply order event success"), map[string]any{
+		"applied": applied,
+	})
+	return applied, nil
+}
+
+func (r *viewRepository) FindByID(ctx *kp.Context, id string) (*OrderView, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "find_order_view",
+		Code:        "200",
+		Description: "success",
+	}
+	filter := bson.M{"_id": id}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find order view"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	var view OrderView
+	err := r.col.FindOne(ctx, filter).Decode(&view)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			summary.Code = "404"
+			summary.Description = "order not found"
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find order view failed"), map[string]string{
+				"error": err.Error(),
+			})
+			return nil, ErrOrderNotFound
+		}
+		summary.Code = "500"
+		summary.Description = "failed to find order view"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find order view failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	view.Href = orderHref(ctx, view.ID)
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find order view success"), map[string]any{
+		"Return": view,
+	})
+	return &view, nil
+}
+
+// Find returns the views matching q, newest first, and one view more than
+// the limit when there is a next page.
+func (r *viewRepository) Find(ctx *kp.Context, q OrderQuery) ([]*OrderView, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "find_order_views",
+		Code:        "200",
+		Description: "success",
+	}
+	filter := q.filter()
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(q.Limit + 1))
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find order views"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"limit":      q.Limit + 1,
+	})
+
+	cursor, err := r.col.Find(ctx, filter, opts)
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to find order views"
+		summary.ResTime = time.Since(start).Milliseconds()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find order views failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	views := []*OrderView{}
+	err = cursor.All(ctx, &views)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to decode order views"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find order views failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	for _, view := range views {
+		view.Href = orderHref(ctx, view.ID)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find order views success"), map[string]any{
+		"count": len(views),
+	})
+	return views, nil
+}
+
+func (r *viewRepository) ScrubCustomer(ctx *kp.Context, customerID string) (int64, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "scrub_order_view_customer",
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "scrub order view customer"), map[string]any{
+		"collection":  r.col.Name(),
+		"customer_id": customerID,
+	})
+
+	result, err := scrubViewCustomer(ctx, r.col, customerID)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to scrub order views"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "scrub order view failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return 0, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "scrub order view success"), map[string]any{
+		"Return": result,
+	})
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,44 @@
+package order
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOrderViewApply(t *testing.T) {
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	event := func(id string, at time.Duration) EventMessage {
+		return EventMessage{EventID: id, OccurredAt: t0.Add(at), Body: OrderEvent{OrderID: "o1"}}
+	}
+	type applied struct {
+		topic string
+		m     EventMessage
+	}
+	created := applied{orderCreatedTopic, event("e1", 0)}
+	paid := applied{orderPaidTopic, event("e2", time.Minute)}
+	shipped := applied{orderShippedTopic, event("e3", 2*time.Minute)}
+
+	tests := []struct {
+		name   string
+		seeded string
+		events []applied
+		want   string
+	}{
+		{name: "in order", events: []applied{created, paid, shipped}, want: "shipped"},
+		{name: "out of order", events: []applied{shipped, created, paid}, want: "shipped"},
+		{name: "repeated", events: []applied{created, paid, created}, want: "paid"},
+		{name: "seeded status kept", seeded: "paid", events: []applied{created}, want: "paid"},
+		{name: "seeded status moves on", seeded: "paid", events: []applied{created, shipped}, want: "shipped"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := OrderView{ID: "o1", Status: tt.seeded}
+			for _, e := range tt.events {
+				v.Apply(e.topic, e.m)
+			}
+			if v.Status != tt.want {
+				t.Errorf("Status = %q, want %q", v.Status, tt.want)
+			}
+		})
+	}
+}
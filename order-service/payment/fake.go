@@ -0,0 +1,134 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// The payment methods the fake provider understands. Any other method is
+// authorized like FakeMethodOK.
+const (
+	FakeMethodOK       = "pm_fake_ok"
+	FakeMethodDeclined = "pm_fake_declined"
+	FakeMethodAsync    = "pm_fake_async"
+)
+
+// FakeGateway is an in-process provider for local runs and tests. It is
+// deterministic: the ref of a payment is derived from its intent, and the
+// outcome of an authorization from the payment method. It keeps the
+// amounts of every payment in memory and rejects what a real provider
+// would: capturing more than was authorized, refunding more than was
+// captured, voiding a captured payment. The state does not survive a
+// restart.
+type FakeGateway struct {
+	mu       sync.Mutex
+	payments map[string]*fakePayment
+}
+
+type fakePayment struct {
+	status     string
+	authorized float64
+	captured   float64
+	refunded   float64
+}
+
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		payments: map[string]*fakePayment{},
+	}
+}
+
+func (g *FakeGateway) Name() string {
+	return "fake"
+}
+
+// Authorize holds the amount. FakeMethodDeclined fails it. FakeMethodAsync
+// answers pending, as a provider that settles later would, but holds the
+// amount at once: the outcome is then whatever webhook the test posts.
+func (g *FakeGateway) Authorize(_ context.Context, req AuthorizeRequest) (Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ref := "fake_" + req.IntentID
+	if p, ok := g.payments[ref]; ok {
+		return Result{Ref: ref, Status: p.status}, nil
+	}
+
+	switch req.PaymentMethod {
+	case FakeMethodDeclined:
+		g.payments[ref] = &fakePayment{status: StatusFailed}
+		return Result{Ref: ref, Status: StatusFailed, FailureReason: "card_declined"}, nil
+	case FakeMethodAsync:
+		g.payments[ref] = &fakePayment{status: StatusAuthorized, authorized: req.Amount}
+		return Result{Ref: ref, Status: StatusPending}, nil
+	default:
+		g.payments[ref] = &fakePayment{status: StatusAuthorized, authorized: req.Amount}
+		return Result{Ref: ref, Status: StatusAuthorized}, nil
+	}
+}
+
+func (g *FakeGateway) Capture(_ context.Context, ref string, amount float64) (Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p, err := g.payment(ref)
+	if err != nil {
+		return Result{}, err
+	}
+	if p.status != StatusAuthorized {
+		return Result{}, fmt.Errorf("%w: %s is %s", ErrRejected, ref, p.status)
+	}
+	if amount > p.authorized {
+		return Result{}, fmt.Errorf("%w: capture of %.2f exceeds the %.2f authorized", ErrRejected, amount, p.authorized)
+	}
+	p.captured = amount
+	p.status = StatusCaptured
+	return Result{Ref: ref, Status: StatusCaptured}, nil
+}
+
+func (g *FakeGateway) Refund(_ context.Context, ref string, amount float64) (Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p, err := g.payment(ref)
+	if err != nil {
+		return Result{}, err
+	}
+	if p.status != StatusCaptured {
+		return Result{}, fmt.Errorf("%w: %s is %s", ErrRejected, ref, p.status)
+	}
+	if amount > p.captured-p.refunded {
+		return Result{}, fmt.Errorf("%w: refund of %.2f exceeds the %.2f left", ErrRejected, amount, p.captured-p.refunded)
+	}
+	p.refunded += amount
+	status := StatusCaptured
+	if p.refunded >= p.captured {
+		p.status = StatusRefunded
+		status = StatusRefunded
+	}
+	return Result{Ref: ref, Status: status}, nil
+}
+
+func (g *FakeGateway) Void(_ context.Context, ref string) (Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p, err := g.payment(ref)
+	if err != nil {
+		return Result{}, err
+	}
+	if p.status != StatusAuthorized {
+		return Result{}, fmt.Errorf("%w: %s is %s", ErrRejected, ref, p.status)
+	}
+	p.status = StatusVoided
+	return Result{Ref: ref, Status: StatusVoided}, nil
+}
+
+func (g *FakeGateway) payment(ref string) (*fakePayment, error) {
+	p, ok := g.payments[ref]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown payment %s", ErrRejected, ref)
+	}
+	return p, nil
+}
@@ -0,0 +1,154 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFakeGatewayAuthorize(t *testing.T) {
+	tests := []struct {
+		method string
+		status string
+		reason string
+	}{
+		{method: FakeMethodOK, status: StatusAuthorized},
+		{method: FakeMethodDeclined, status: StatusFailed, reason: "card_declined"},
+		{method: FakeMethodAsync, status: StatusPending},
+		{method: "pm_anything_else", status: StatusAuthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			g := NewFakeGateway()
+			req := AuthorizeRequest{IntentID: "pi_1", Amount: 10, PaymentMethod: tt.method}
+			res, err := g.Authorize(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Ref != "fake_pi_1" || res.Status != tt.status || res.FailureReason != tt.reason {
+				t.Errorf("Authorize = %+v, want ref fake_pi_1, status %s, reason %q", res, tt.status, tt.reason)
+			}
+
+			again, err := g.Authorize(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.Ref != res.Ref {
+				t.Errorf("second Authorize ref = %s, want %s", again.Ref, res.Ref)
+			}
+		})
+	}
+}
+
+func TestFakeGatewayLifecycle(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		steps  func(g *FakeGateway, ref string) (Result, error)
+		status string // empty when the last step is rejected
+	}{
+		{
+			name:   "capture within the hold",
+			method: FakeMethodOK,
+			steps: func(g *FakeGateway, ref string) (Result, error) {
+				return g.Capture(context.Background(), ref, 8)
+			},
+			status: StatusCaptured,
+		},
+		{
+			name:   "capture over the hold",
+			method: FakeMethodOK,
+			steps: func(g *FakeGateway, ref string) (Result, error) {
+				return g.Capture(context.Background(), ref, 11)
+			},
+		},
+		{
+			name:   "capture a declined payment",
+			method: FakeMethodDeclined,
+			steps: func(g *FakeGateway, ref string) (Result, error) {
+				return g.Capture(context.Background(), ref, 10)
+			},
+		},
+		{
+			name:   "partial refund",
+			method: FakeMethodOK,
+			steps: func(g *FakeGateway, ref string) (Result, error) {
+				g.Capture(context.Background(), ref, 10)
+				return g.Refund(context.Background(), ref, 4)
+			},
+			status: StatusCaptured,
+		},
+		{
+			name:   "refunds up to the capture",
+			method: FakeMethodOK,
+			steps: func(g *FakeGateway, ref string) (Result, error) {
+				g.Capture(context.Background(), ref, 10)
+				g.Refund(context.Background(), ref, 4)
+				return g.Refund(context.Background(), ref, 6)
+			},
+			status: StatusRefunded,
+		},
+		{
+			name:   "refund over what is left",
+			method: FakeMethodOK,
+			steps: func(g *FakeGateway, ref string) (Result, error) {
+				g.Capture(context.Background(), ref, 10)
+				g.Refund(context.Background(), ref, 4)
+				return g.Refund(context.Background(), ref, 7)
+			},
+		},
+		{
+			name:   "refund before capture",
+			method: FakeMethodOK,
+			steps: func(g *FakeGateway, ref string) (Result, error) {
+				return g.Refund(context.Background(), ref, 1)
+			},
+		},
+		{
+			name:   "void a hold",
+			method: FakeMethodAsync,
+			steps: func(g *FakeGateway, ref string) (Result, error) {
+				return g.Void(context.Background(), ref)
+			},
+			status: StatusVoided,
+		},
+		{
+			name:   "void a captured payment",
+			method: FakeMethodOK,
+			steps: func(g *FakeGateway, ref string) (Result, error) {
+				g.Capture(context.Background(), ref, 10)
+				return g.Void(context.Background(), ref)
+			},
+		},
+		{
+			name:   "unknown payment",
+			method: FakeMethodOK,
+			steps: func(g *FakeGateway, ref string) (Result, error) {
+				return g.Capture(context.Background(), "fake_missing", 1)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewFakeGateway()
+			auth, err := g.Authorize(context.Background(), AuthorizeRequest{IntentID: "pi_1", Amount: 10, PaymentMethod: tt.method})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := tt.steps(g, auth.Ref)
+			if tt.status == "" {
+				if !errors.Is(err, ErrRejected) {
+					t.Errorf("err = %v, want ErrRejected", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != tt.status {
+				t.Errorf("status = %s, want %s", res.Status, tt.status)
+			}
+		})
+	}
+}
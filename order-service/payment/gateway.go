@@ -0,0 +1,52 @@
+package payment
+
+import (
+	"context"
+	"errors"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+)
+
+// PaymentGateway is a payment provider. Every call is keyed by the intent,
+// so a provider that deduplicates on it makes retries safe. A provider that
+// settles asynchronously answers StatusPending and reports the outcome
+// later through the webhook.
+type PaymentGateway interface {
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (Result, error)
+	Capture(ctx context.Context, ref string, amount float64) (Result, error)
+	Refund(ctx context.Context, ref string, amount float64) (Result, error)
+	Void(ctx context.Context, ref string) (Result, error)
+}
+
+// AuthorizeRequest asks the provider to hold amount on a payment method.
+type AuthorizeRequest struct {
+	IntentID      string
+	OrderID       string
+	Amount        float64
+	Currency      string
+	PaymentMethod string
+}
+
+// Result is what the provider answered. Ref is the provider's id of the
+// payment; FailureReason is set when Status is StatusFailed.
+type Result struct {
+	Ref           string
+	Status        string
+	FailureReason string
+}
+
+// ErrRejected is returned, wrapped, by a provider that refuses an operation
+// on a payment in its current state, such as capturing more than was
+// authorized.
+var ErrRejected = errors.New("payment operation rejected by the provider")
+
+// NewGateway returns the provider selected by PAYMENT_GATEWAY.
+func NewGateway(conf *config.Config) (PaymentGateway, error) {
+	switch conf.GetOrDefault("PAYMENT_GATEWAY", "fake") {
+	case "fake":
+		return NewFakeGateway(), nil
+	default:
+		return nil, errors.New("unsupported PAYMENT_GATEWAY: " + conf.Get("PAYMENT_GATEWAY"))
+	}
+}
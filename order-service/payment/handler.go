@@ -0,0 +1,253 @@
+package payment
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/validation"
+	"github.com/sing3demons/go-order-service/webhook"
+)
+
+type Handler struct {
+	service   Service
+	secret    string
+	tolerance time.Duration
+}
+
+// NewHandler verifies webhooks with secret, accepting signatures made up to
+// tolerance away from now.
+func NewHandler(service Service, secret string, tolerance time.Duration) *Handler {
+	return &Handler{
+		service:   service,
+		secret:    secret,
+		tolerance: tolerance,
+	}
+}
+
+var errSignature = apperror.Unauthorized("invalid_signature", "the webhook signature is missing or wrong")
+
+// HandleCancelOrder cancels an order that has not shipped yet and voids or
+// refunds its payments
+func (h *Handler) HandleCancelOrder(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "cancel_order",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var req struct {
+		Reason string `json:"reason" validate:"max=200"`
+	}
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("cancel order", ""), map[string]any{
+		"id":   id,
+		"body": req,
+	})
+
+	order, err := h.service.CancelOrder(ctx, id, req.Reason)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, map[string]any{
+		"order_id":   order.ID,
+		"status":     order.Status,
+		"updated_at": order.UpdatedAt,
+	})
+}
+
+// HandleCreateIntent authorizes the payment of an order
+func (h *Handler) HandleCreateIntent(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "create_payment",
+		Code:        "200",
+		Description: "",
+	}
+	orderID := ctx.PathParam("id")
+	if err := validation.Var("id", orderID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var req CreateIntentRequest
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("create payment", ""), map[string]any{
+		"order_id": orderID,
+		"body":     req,
+	})
+
+	intent, err := h.service.CreateIntent(ctx, orderID, req)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(201, h.withHref(ctx, intent))
+}
+
+// HandleListIntents lists the payments of an order
+func (h *Handler) HandleListIntents(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "list_payments",
+		Code:        "200",
+		Description: "",
+	}
+	orderID := ctx.PathParam("id")
+	if err := validation.Var("id", orderID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("list payments", ""), map[string]any{
+		"order_id": orderID,
+	})
+
+	intents, err := h.service.ListIntents(ctx, orderID)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	for i := range intents {
+		intents[i] = h.withHref(ctx, intents[i])
+	}
+	return ctx.JSON(200, intents)
+}
+
+// HandleGetIntent returns one payment
+func (h *Handler) HandleGetIntent(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "get_payment",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("get payment", ""), map[string]any{
+		"id": id,
+	})
+
+	intent, err := h.service.GetIntent(ctx, id)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, h.withHref(ctx, intent))
+}
+
+// HandleCapture captures an authorized payment
+func (h *Handler) HandleCapture(ctx *kp.Context) error {
+	return h.handleAmount(ctx, "capture_payment", h.service.Capture)
+}
+
+// HandleRefund refunds a captured payment
+func (h *Handler) HandleRefund(ctx *kp.Context) error {
+	return h.handleAmount(ctx, "refund_payment", h.service.Refund)
+}
+
+func (h *Handler) handleAmount(ctx *kp.Context, cmd string, op func(*kp.Context, string, AmountRequest) (Intent, error)) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     cmd,
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var req AmountRequest
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"id":   id,
+		"body": req,
+	})
+
+	intent, err := op(ctx, id, req)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, h.withHref(ctx, intent))
+}
+
+// HandleVoid releases an authorized payment
+func (h *Handler) HandleVoid(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "void_payment",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("void payment", ""), map[string]any{
+		"id": id,
+	})
+
+	intent, err := h.service.Void(ctx, id)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, h.withHref(ctx, intent))
+}
+
+// HandleWebhook applies a signed asynchronous result of the provider
+func (h *Handler) HandleWebhook(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "payment_gateway",
+		Command:     "payment_webhook",
+		Code:        "200",
+		Description: "",
+	}
+	r, ok := ctx.Request.(interface {
+		Header(string) string
+		Body() (string, error)
+	})
+	if !ok {
+		return apperror.Write(ctx, errSignature)
+	}
+	body, err := r.Body()
+	if err != nil {
+		return validation.Respond(ctx, summary, apperror.Invalid(apperror.FieldError{Field: "body", Rule: "format", Message: "must be a valid request body"}))
+	}
+	if err := webhook.Verify(h.secret, r.Header(SignatureHeader), []byte(body), h.tolerance, time.Now()); err != nil {
+		summary.Code = "401"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewInbound("payment webhook", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return apperror.Write(ctx, errSignature)
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return validation.Respond(ctx, summary, apperror.Invalid(apperror.FieldError{Field: "body", Rule: "format", Message: "must be a valid request body"}))
+	}
+	if err := validation.Struct(event); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("payment webhook", ""), map[string]any{
+		"body": event,
+	})
+
+	intent, err := h.service.HandleWebhook(ctx, event)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, h.withHref(ctx, intent))
+}
+
+func (h *Handler) withHref(ctx *kp.Context, intent Intent) Intent {
+	intent.Href = ctx.HostName() + "/payments/" + intent.ID
+	return intent
+}
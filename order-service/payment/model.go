@@ -0,0 +1,42 @@
+package payment
+
+import "time"
+
+// The statuses of a payment intent.
+const (
+	StatusPending    = "pending"    // The provider has not decided yet
+	StatusAuthorized = "authorized" // The amount is held, not taken
+	StatusCaptured   = "captured"   // The amount is taken; part may be refunded
+	StatusRefunded   = "refunded"   // Everything captured was given back
+	StatusVoided     = "voided"     // The hold was released
+	StatusFailed     = "failed"     // The provider refused the payment
+)
+
+// Intent is one attempt to pay an order. An order may have several, e.g. a
+// declined card followed by another one.
+type Intent struct {
+	ID             string    `json:"id" bson:"_id"`
+	Href           string    `json:"href,omitempty" bson:"-"`
+	OrderID        string    `json:"order_id" bson:"order_id"`
+	Amount         float64   `json:"amount" bson:"amount"`
+	Currency       string    `json:"currency" bson:"currency"`
+	AmountCaptured float64   `json:"amount_captured" bson:"amount_captured"`
+	AmountRefunded float64   `json:"amount_refunded" bson:"amount_refunded"`
+	Status         string    `json:"status" bson:"status"`
+	Provider       string    `json:"provider" bson:"provider"`
+	ProviderRef    string    `json:"provider_ref,omitempty" bson:"provider_ref,omitempty"`
+	FailureReason  string    `json:"failure_reason,omitempty" bson:"failure_reason,omitempty"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// CreateIntentRequest is the body of POST /orders/{id}/payments.
+type CreateIntentRequest struct {
+	PaymentMethod string `json:"payment_method" validate:"required"`
+}
+
+// AmountRequest is the body of capture and refund. A zero amount means
+// everything that is left.
+type AmountRequest struct {
+	Amount float64 `json:"amount" validate:"gte=0"`
+}
@@ -0,0 +1,293 @@
+package payment
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository stores payment intents in the payment_intents collection.
+// Every change is a conditional update on the current status, so the API
+// and the webhook racing on one intent cannot both apply.
+type Repository interface {
+	Create(ctx *kp.Context, intent Intent) (Intent, error)
+	FindByID(ctx *kp.Context, id string) (Intent, error)
+	FindByRef(ctx *kp.Context, ref string) (Intent, error)
+	FindByOrder(ctx *kp.Context, orderID string) ([]Intent, error)
+	SetResult(ctx *kp.Context, id string, from []string, result Result) (Intent, error)
+	SetCaptured(ctx *kp.Context, id string, amount float64) (Intent, error)
+	AddRefund(ctx *kp.Context, id string, amount float64) (Intent, error)
+	SetRefunded(ctx *kp.Context, id string, total float64) (Intent, error)
+}
+
+type repository struct {
+	col *mongo.Collection
+}
+
+func NewRepository(col *mongo.Collection) Repository {
+	return &repository{
+		col: col,
+	}
+}
+
+var (
+	ErrIntentNotFound = apperror.NotFound("payment_not_found", "payment not found")
+	ErrStatusConflict = apperror.Conflict("payment_status_conflict", "status", "the payment is not in a status that allows this")
+)
+
+func (r *repository) Create(ctx *kp.Context, intent Intent) (Intent, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "create_payment_intent",
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.INSERT, "insert payment intent"), map[string]any{
+		"collection": r.col.Name(),
+		"intent":     intent,
+	})
+
+	result, err := r.col.InsertOne(ctx, intent)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to insert payment intent"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.INSERT, "insert payment intent failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Intent{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.INSERT, "insert payment intent success"), map[string]any{
+		"Return": result,
+	})
+	return intent, nil
+}
+
+func (r *repository) FindByID(ctx *kp.Context, id string) (Intent, error) {
+	return r.findOne(ctx, "find_payment_intent", bson.M{"_id": id})
+}
+
+func (r *repository) FindByRef(ctx *kp.Context, ref string) (Intent, error) {
+	return r.findOne(ctx, "find_payment_intent_by_ref", bson.M{"provider_ref": ref})
+}
+
+func (r *repository) findOne(ctx *kp.Context, cmd string, filter bson.M) (Intent, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, cmd), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	var intent Intent
+	err := r.col.FindOne(ctx, filter).Decode(&intent)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			summary.Code = "404"
+			summary.Description = "payment not found"
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, cmd+" failed"), map[string]string{
+				"error": err.Error(),
+			})
+			return Intent{}, ErrIntentNotFound
+		}
+		summary.Code = "500"
+		summary.Description = "failed to find payment intent"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, cmd+" failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Intent{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, cmd+" success"), map[string]any{
+		"Return": intent,
+	})
+	return intent, nil
+}
+
+func (r *repository) FindByOrder(ctx *kp.Context, orderID string) ([]Intent, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "find_payment_intents_by_order",
+		Code:        "200",
+		Description: "success",
+	}
+	filter := bson.M{"order_id": orderID}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find payment intents by order"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	cursor, err := r.col.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to find payment intents"
+		summary.ResTime = time.Since(start).Milliseconds()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find payment intents failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	intents := []Intent{}
+	err = cursor.All(ctx, &intents)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to decode payment intents"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find payment intents failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find payment intents success"), map[string]any{
+		"count": len(intents),
+	})
+	return intents, nil
+}
+
+// SetResult records the answer of the provider to an intent in one of the
+// statuses from.
+func (r *repository) SetResult(ctx *kp.Context, id string, from []string, result Result) (Intent, error) {
+	set := bson.M{
+		"status":     result.Status,
+		"updated_at": time.Now().UTC(),
+	}
+	if result.Ref != "" {
+		set["provider_ref"] = result.Ref
+	}
+	if result.FailureReason != "" {
+		set["failure_reason"] = result.FailureReason
+	}
+	return r.transition(ctx, "set_payment_result",
+		bson.M{"_id": id, "status": bson.M{"$in": from}},
+		bson.M{"$set": set})
+}
+
+// SetCaptured records the capture of amount on an authorized intent.
+func (r *repository) SetCaptured(ctx *kp.Context, id string, amount float64) (Intent, error) {
+	return r.transition(ctx, "capture_payment",
+		bson.M{"_id": id, "status": StatusAuthorized},
+		bson.M{"$set": bson.M{
+			"status":          StatusCaptured,
+			"amount_captured": amount,
+			"updated_at":      time.Now().UTC(),
+		}})
+}
+
+// AddRefund adds amount to what was refunded of a captured intent, as long
+// as it does not exceed what was captured. The intent is refunded once
+// everything captured was given back.
+func (r *repository) AddRefund(ctx *kp.Context, id string, amount float64) (Intent, error) {
+	filter := bson.M{
+		"_id":    id,
+		"status": StatusCaptured,
+		"$expr": bson.M{"$lte": bson.A{
+			bson.M{"$add": bson.A{"$amount_refunded", amount}},
+			"$amount_captured",
+		}},
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"amount_refunded": bson.M{"$add": bson.A{"$amount_refunded", amount}}}}},
+		{{Key: "$set", Value: refundedStatus()}},
+	}
+	return r.transition(ctx, "refund_payment", filter, update)
+}
+
+// SetRefunded records total as what was refunded of a captured intent, as
+// reported by the provider. It never lowers the amount, so an old or
+// repeated report changes nothing.
+func (r *repository) SetRefunded(ctx *kp.Context, id string, total float64) (Intent, error) {
+	filter := bson.M{"_id": id, "status": bson.M{"$in": bson.A{StatusCaptured, StatusRefunded}}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"amount_refunded": bson.M{"$min": bson.A{
+			bson.M{"$max": bson.A{"$amount_refunded", total}},
+			"$amount_captured",
+		}}}}},
+		{{Key: "$set", Value: refundedStatus()}},
+	}
+	return r.transition(ctx, "set_payment_refunded", filter, update)
+}
+
+// refundedStatus is the pipeline stage that derives the status from the
+// refunded amount.
+func refundedStatus() bson.M {
+	return bson.M{
+		"status": bson.M{"$cond": bson.A{
+			bson.M{"$gte": bson.A{"$amount_refunded", "$amount_captured"}},
+			StatusRefunded,
+			StatusCaptured,
+		}},
+		"updated_at": time.Now().UTC(),
+	}
+}
+
+// transition applies update to the intent matching filter and returns it
+// updated. No match is ErrIntentNotFound when the intent does not exist and
+// ErrStatusConflict when it is not in a state filter accepts.
+func (r *repository) transition(ctx *kp.Context, cmd string, filter bson.M, update any) (Intent, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, cmd), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"update":     update,
+	})
+
+	var intent Intent
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.col.FindOneAndUpdate(ctx, filter, update, opts).Decode(&intent)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		var n int64
+		n, err = r.col.CountDocuments(ctx, bson.M{"_id": filter["_id"]})
+		if err == nil {
+			summary.ResTime = time.Since(start).Milliseconds()
+			fail := ErrStatusConflict
+			summary.Code = "409"
+			summary.Description = "payment status conflict"
+			if n == 0 {
+				fail = ErrIntentNotFound
+				summary.Code = "404"
+				summary.Description = "payment not found"
+			}
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, cmd+" failed"), map[string]string{
+				"error": fail.Message,
+			})
+			return Intent{}, fail
+		}
+	}
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to update payment intent"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, cmd+" failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Intent{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, cmd+" success"), map[string]any{
+		"Return": intent,
+	})
+	return intent, nil
+}
@@ -0,0 +1,38 @@
+package payment
+
+import (
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-order-service/order"
+	"github.com/sing3demons/go-order-service/servicetoken"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RegisterRoutes registers the payment routes. The service is returned for
+// refunds made by returns. Canceling an order is here too, since it gives
+// back what was paid for it. Whoever can capture, refund, void or cancel
+// moves money, and there is no user login to check whose payment it is,
+// so those routes take an admin token, see servicetoken.
+func RegisterRoutes(app kp.IApplication, db *mongo.Database, orders order.OrderService, gateway PaymentGateway, conf *config.Config, guard *servicetoken.Guard) Service {
+	tolerance, err := time.ParseDuration(conf.GetOrDefault("PAYMENT_WEBHOOK_TOLERANCE", "5m"))
+	if err != nil || tolerance <= 0 {
+		tolerance = 5 * time.Minute
+	}
+
+	repo := NewRepository(db.Collection("payment_intents"))
+	service := NewService(repo, gateway, orders)
+	handler := NewHandler(service, conf.Get("PAYMENT_WEBHOOK_SECRET"), tolerance)
+	app.Post("/orders/{id}/payments", handler.HandleCreateIntent)
+	app.Get("/orders/{id}/payments", handler.HandleListIntents)
+	app.Get("/payments/{id}", handler.HandleGetIntent)
+	app.Post("/payments/webhook", handler.HandleWebhook)
+
+	admin := guard.Admin()
+	app.Post("/internal/orders/{id}/cancel", admin.Require(handler.HandleCancelOrder))
+	app.Post("/internal/payments/{id}/capture", admin.Require(handler.HandleCapture))
+	app.Post("/internal/payments/{id}/refund", admin.Require(handler.HandleRefund))
+	app.Post("/internal/payments/{id}/void", admin.Require(handler.HandleVoid))
+	return service
+}
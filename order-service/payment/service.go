@@ -0,0 +1,339 @@
+package payment
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/order"
+)
+
+type Service interface {
+	CreateIntent(ctx *kp.Context, orderID string, req CreateIntentRequest) (Intent, error)
+	GetIntent(ctx *kp.Context, id string) (Intent, error)
+	ListIntents(ctx *kp.Context, orderID string) ([]Intent, error)
+	Capture(ctx *kp.Context, id string, req AmountRequest) (Intent, error)
+	Refund(ctx *kp.Context, id string, req AmountRequest) (Intent, error)
+	Void(ctx *kp.Context, id string) (Intent, error)
+	HandleWebhook(ctx *kp.Context, event WebhookEvent) (Intent, error)
+	CancelOrder(ctx *kp.Context, orderID, reason string) (order.Order, error)
+}
+
+type service struct {
+	repo    Repository
+	gateway PaymentGateway
+	orders  order.OrderService
+}
+
+// NewService takes payments through gateway and marks the orders of
+// captured payments as paid through orders.
+func NewService(repo Repository, gateway PaymentGateway, orders order.OrderService) Service {
+	return &service{
+		repo:    repo,
+		gateway: gateway,
+		orders:  orders,
+	}
+}
+
+var (
+	ErrOrderNotPayable = apperror.Conflict("order_not_payable", "status", "only pending orders can be paid")
+	ErrAlreadyPaying   = apperror.Conflict("payment_in_progress", "order_id", "the order already has a payment that is pending, authorized or captured")
+	ErrAmountTooLarge  = apperror.Invalid(apperror.FieldError{Field: "amount", Rule: "lte", Message: "must not exceed what is left on the payment"})
+	errRejected        = apperror.Conflict("payment_rejected", "status", "the payment provider rejected the operation")
+	errGateway         = apperror.Upstream("payment_gateway_error", "the payment provider could not be reached", nil)
+)
+
+// CreateIntent authorizes the total of a pending order. A declined payment
+// is not an error: the intent is returned failed, with the reason.
+func (s *service) CreateIntent(ctx *kp.Context, orderID string, req CreateIntentRequest) (Intent, error) {
+	o, err := s.orders.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return Intent{}, err
+	}
+	if o.Status != "pending" {
+		return Intent{}, ErrOrderNotPayable
+	}
+	intents, err := s.repo.FindByOrder(ctx, orderID)
+	if err != nil {
+		return Intent{}, err
+	}
+	for _, intent := range intents {
+		switch intent.Status {
+		case StatusPending, StatusAuthorized, StatusCaptured:
+			return Intent{}, ErrAlreadyPaying
+		}
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Intent{}, apperror.Internal(err)
+	}
+	now := time.Now().UTC()
+	intent, err := s.repo.Create(ctx, Intent{
+		ID:        id.String(),
+		OrderID:   orderID,
+		Amount:    o.TotalPrice,
+		Currency:  o.Currency,
+		Status:    StatusPending,
+		Provider:  s.gateway.Name(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return Intent{}, err
+	}
+
+	result, err := s.call(ctx, "authorize_payment", func() (Result, error) {
+		return s.gateway.Authorize(ctx, AuthorizeRequest{
+			IntentID:      intent.ID,
+			OrderID:       orderID,
+			Amount:        intent.Amount,
+			Currency:      intent.Currency,
+			PaymentMethod: req.PaymentMethod,
+		})
+	})
+	if err != nil {
+		// Leave no pending intent behind to block the next attempt.
+		_, _ = s.repo.SetResult(ctx, intent.ID, []string{StatusPending}, Result{Status: StatusFailed, FailureReason: "provider_error"})
+		return Intent{}, err
+	}
+	return s.repo.SetResult(ctx, intent.ID, []string{StatusPending}, result)
+}
+
+func (s *service) GetIntent(ctx *kp.Context, id string) (Intent, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+func (s *service) ListIntents(ctx *kp.Context, orderID string) ([]Intent, error) {
+	if _, err := s.orders.GetOrderByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByOrder(ctx, orderID)
+}
+
+// Capture takes the authorized amount, or part of it, and marks the order
+// paid. Should anything fail after the provider took the money, the
+// provider's payment.captured webhook completes the rest.
+func (s *service) Capture(ctx *kp.Context, id string, req AmountRequest) (Intent, error) {
+	intent, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Intent{}, err
+	}
+	if intent.Status != StatusAuthorized {
+		return Intent{}, ErrStatusConflict
+	}
+	o, err := s.orders.GetOrderByID(ctx, intent.OrderID)
+	if err != nil {
+		return Intent{}, err
+	}
+	if o.Status != "pending" {
+		return Intent{}, ErrOrderNotPayable
+	}
+	amount := req.Amount
+	if amount == 0 {
+		amount = intent.Amount
+	}
+	if amount > intent.Amount {
+		return Intent{}, ErrAmountTooLarge
+	}
+
+	if _, err := s.call(ctx, "capture_payment", func() (Result, error) {
+		return s.gateway.Capture(ctx, intent.ProviderRef, amount)
+	}); err != nil {
+		return Intent{}, err
+	}
+	return s.captured(ctx, intent.ID, amount)
+}
+
+// captured records a capture the provider made and pays the order. Both
+// steps tolerate having been done already. An order canceled while its
+// payment was being captured is not paid: the capture is refunded.
+func (s *service) captured(ctx *kp.Context, id string, amount float64) (Intent, error) {
+	intent, err := s.repo.SetCaptured(ctx, id, amount)
+	if errors.Is(err, ErrStatusConflict) {
+		intent, err = s.repo.FindByID(ctx, id)
+		if err == nil && intent.Status != StatusCaptured && intent.Status != StatusRefunded {
+			return Intent{}, ErrStatusConflict
+		}
+	}
+	if err != nil {
+		return Intent{}, err
+	}
+	if _, err := s.orders.MarkPaid(ctx, intent.OrderID, intent.ID); err != nil {
+		o, findErr := s.orders.GetOrderByID(ctx, intent.OrderID)
+		if findErr == nil && o.Status == "canceled" {
+			return s.settle(ctx, intent)
+		}
+		return Intent{}, err
+	}
+	return intent, nil
+}
+
+// Refund gives back part or all of what is left of a captured payment.
+func (s *service) Refund(ctx *kp.Context, id string, req AmountRequest) (Intent, error) {
+	intent, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Intent{}, err
+	}
+	if intent.Status != StatusCaptured {
+		return Intent{}, ErrStatusConflict
+	}
+	left := intent.AmountCaptured - intent.AmountRefunded
+	amount := req.Amount
+	if amount == 0 {
+		amount = left
+	}
+	if amount > left {
+		return Intent{}, ErrAmountTooLarge
+	}
+
+	if _, err := s.call(ctx, "refund_payment", func() (Result, error) {
+		return s.gateway.Refund(ctx, intent.ProviderRef, amount)
+	}); err != nil {
+		return Intent{}, err
+	}
+	return s.repo.AddRefund(ctx, intent.ID, amount)
+}
+
+// Void releases an authorization that was not captured.
+func (s *service) Void(ctx *kp.Context, id string) (Intent, error) {
+	intent, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Intent{}, err
+	}
+	if intent.Status != StatusAuthorized {
+		return Intent{}, ErrStatusConflict
+	}
+
+	result, err := s.call(ctx, "void_payment", func() (Result, error) {
+		return s.gateway.Void(ctx, intent.ProviderRef)
+	})
+	if err != nil {
+		return Intent{}, err
+	}
+	return s.repo.SetResult(ctx, intent.ID, []string{StatusAuthorized}, result)
+}
+
+// CancelOrder cancels an order that has not shipped yet, then releases its
+// authorized payments and refunds what is left of its captured ones. The
+// order is canceled first, so no payment of it is captured afterwards. Should
+// settling a payment fail, canceling the order again settles the rest.
+func (s *service) CancelOrder(ctx *kp.Context, orderID, reason string) (order.Order, error) {
+	o, err := s.orders.CancelOrder(ctx, orderID, reason)
+	if err != nil {
+		return order.Order{}, err
+	}
+	intents, err := s.repo.FindByOrder(ctx, orderID)
+	if err != nil {
+		return order.Order{}, err
+	}
+	for _, intent := range intents {
+		if _, err := s.settle(ctx, intent); err != nil {
+			return order.Order{}, err
+		}
+	}
+	return o, nil
+}
+
+// settle gives back the money of a payment whose order was canceled: an
+// authorization is voided and what is left of a capture is refunded.
+// Anything else is returned as is.
+func (s *service) settle(ctx *kp.Context, intent Intent) (Intent, error) {
+	switch {
+	case intent.Status == StatusAuthorized:
+		return s.Void(ctx, intent.ID)
+	case intent.Status == StatusCaptured && intent.AmountCaptured > intent.AmountRefunded:
+		return s.Refund(ctx, intent.ID, AmountRequest{})
+	}
+	return intent, nil
+}
+
+// releaseCanceled voids an authorization whose order was canceled while the
+// provider was deciding on it.
+func (s *service) releaseCanceled(ctx *kp.Context, intent Intent) (Intent, error) {
+	if intent.Status != StatusAuthorized {
+		return intent, nil
+	}
+	o, err := s.orders.GetOrderByID(ctx, intent.OrderID)
+	if err != nil {
+		return Intent{}, err
+	}
+	if o.Status != "canceled" {
+		return intent, nil
+	}
+	return s.settle(ctx, intent)
+}
+
+// HandleWebhook applies an asynchronous result of the provider. Providers
+// retry and may repeat themselves, so a result that was already applied
+// returns the intent unchanged.
+func (s *service) HandleWebhook(ctx *kp.Context, event WebhookEvent) (Intent, error) {
+	intent, err := s.repo.FindByRef(ctx, event.Data.Ref)
+	if err != nil {
+		return Intent{}, err
+	}
+
+	switch event.Type {
+	case "payment.authorized":
+		intent, err = s.repo.SetResult(ctx, intent.ID, []string{StatusPending}, Result{Status: StatusAuthorized})
+		if errors.Is(err, ErrStatusConflict) {
+			intent, err = s.repo.FindByRef(ctx, event.Data.Ref)
+		}
+		if err != nil {
+			return Intent{}, err
+		}
+		return s.releaseCanceled(ctx, intent)
+	case "payment.failed":
+		intent, err = s.repo.SetResult(ctx, intent.ID, []string{StatusPending, StatusAuthorized}, Result{Status: StatusFailed, FailureReason: event.Data.FailureReason})
+	case "payment.voided":
+		intent, err = s.repo.SetResult(ctx, intent.ID, []string{StatusPending, StatusAuthorized}, Result{Status: StatusVoided})
+	case "payment.captured":
+		amount := event.Data.Amount
+		if amount == 0 {
+			amount = intent.Amount
+		}
+		return s.captured(ctx, intent.ID, amount)
+	case "payment.refunded":
+		intent, err = s.repo.SetRefunded(ctx, intent.ID, event.Data.Amount)
+	}
+	if errors.Is(err, ErrStatusConflict) {
+		return s.repo.FindByRef(ctx, event.Data.Ref)
+	}
+	return intent, err
+}
+
+// call runs one gateway operation and logs it like any other outbound call.
+func (s *service) call(ctx *kp.Context, cmd string, op func() (Result, error)) (Result, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "payment_gateway",
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewHTTPRequest(cmd, ""), map[string]any{
+		"gateway": s.gateway.Name(),
+	})
+
+	result, err := op()
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse(cmd+" failed", ""), map[string]string{
+			"error": err.Error(),
+		})
+		if errors.Is(err, ErrRejected) {
+			return Result{}, errRejected.Wrap(err)
+		}
+		return Result{}, errGateway.Wrap(err)
+	}
+	summary.Description = result.Status
+	ctx.Log().SetSummary(summary).Info(logger.NewHTTPResponse(cmd+" success", ""), map[string]any{
+		"Return": result,
+	})
+	return result, nil
+}
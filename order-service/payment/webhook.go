@@ -0,0 +1,16 @@
+package payment
+
+// SignatureHeader carries the webhook signature, in the format of package
+// webhook.
+const SignatureHeader = "X-Payment-Signature"
+
+// WebhookEvent is an asynchronous result sent by the provider.
+type WebhookEvent struct {
+	ID   string `json:"id" validate:"required"`
+	Type string `json:"type" validate:"required,oneof=payment.authorized payment.failed payment.captured payment.refunded payment.voided"`
+	Data struct {
+		Ref           string  `json:"ref" validate:"required"`
+		Amount        float64 `json:"amount" validate:"gte=0"`
+		FailureReason string  `json:"failure_reason,omitempty"`
+	} `json:"data"`
+}
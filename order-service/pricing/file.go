@@ -0,0 +1,97 @@
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// File is a JSON document read from a path and read again whenever the
+// file changes, so rates and rules are updated without a restart. A change
+// that does not parse or validate is logged and the last good content is
+// kept.
+type File[T any] struct {
+	path     string
+	validate func(T) error
+
+	mu      sync.RWMutex
+	value   T
+	modTime time.Time
+}
+
+// LoadFile reads path, which must hold a valid document.
+func LoadFile[T any](path string, validate func(T) error) (*File[T], error) {
+	f := &File[T]{path: path, validate: validate}
+	if _, err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Get returns the current content.
+func (f *File[T]) Get() T {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.value
+}
+
+// Reload reads the file again if it changed since it was last read and
+// reports whether it did.
+func (f *File[T]) Reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, err
+	}
+	f.mu.RLock()
+	unchanged := info.ModTime().Equal(f.modTime)
+	f.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, err
+	}
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return false, fmt.Errorf("%s: %w", f.path, err)
+	}
+	if err := f.validate(value); err != nil {
+		return false, fmt.Errorf("%s: %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	f.value = value
+	f.modTime = info.ModTime()
+	f.mu.Unlock()
+	return true, nil
+}
+
+// Watch calls Reload on every interval until ctx is cancelled.
+func (f *File[T]) Watch(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			changed, err := f.Reload()
+			if err != nil {
+				log.Printf("pricing: %v", err)
+				continue
+			}
+			if changed {
+				log.Printf("pricing: reloaded %s", f.path)
+			}
+		}
+	}()
+}
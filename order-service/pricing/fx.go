@@ -0,0 +1,36 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Rates is the FX rate table: how many units of each currency one unit of
+// Base buys. Product prices are in Base.
+//
+//	{"base": "USD", "rates": {"EUR": 0.92, "THB": 36.5}}
+type Rates struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// Rate returns what one unit of Base is worth in currency.
+func (r Rates) Rate(currency string) (float64, bool) {
+	if currency == r.Base {
+		return 1, true
+	}
+	rate, ok := r.Rates[currency]
+	return rate, ok
+}
+
+func validateRates(r Rates) error {
+	if r.Base == "" {
+		return errors.New("base currency is missing")
+	}
+	for currency, rate := range r.Rates {
+		if rate <= 0 {
+			return fmt.Errorf("rate of %s must be positive", currency)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package pricing
+
+import (
+	"context"
+	"math"
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-order-service/apperror"
+)
+
+// Pricer converts prices to the currency of an order and taxes them under
+// the rules of its region.
+type Pricer struct {
+	rates    *File[Rates]
+	taxes    *File[TaxRules]
+	interval time.Duration
+}
+
+// New reads the FX table from FX_RATES_FILE and the tax rules from
+// TAX_RULES_FILE. Both are read again when they change, checked every
+// PRICING_REFRESH.
+func New(conf *config.Config) (*Pricer, error) {
+	interval, err := time.ParseDuration(conf.GetOrDefault("PRICING_REFRESH", "1m"))
+	if err != nil || interval <= 0 {
+		interval = time.Minute
+	}
+	rates, err := LoadFile(conf.GetOrDefault("FX_RATES_FILE", "configs/fx_rates.json"), validateRates)
+	if err != nil {
+		return nil, err
+	}
+	taxes, err := LoadFile(conf.GetOrDefault("TAX_RULES_FILE", "configs/tax_rules.json"), validateTaxRules)
+	if err != nil {
+		return nil, err
+	}
+	return &Pricer{
+		rates:    rates,
+		taxes:    taxes,
+		interval: interval,
+	}, nil
+}
+
+// Start watches both files until ctx is cancelled.
+func (p *Pricer) Start(ctx context.Context) {
+	p.rates.Watch(ctx, p.interval)
+	p.taxes.Watch(ctx, p.interval)
+}
+
+// Quote fixes the rate and tax rules an order is priced with. An empty
+// currency is the base currency and an empty region the default region.
+func (p *Pricer) Quote(currency, region string) (Quote, error) {
+	rates := p.rates.Get()
+	taxes := p.taxes.Get()
+	if currency == "" {
+		currency = rates.Base
+	}
+	if region == "" {
+		region = taxes.DefaultRegion
+	}
+
+	rate, ok := rates.Rate(currency)
+	if !ok {
+		return Quote{}, apperror.Invalid(apperror.FieldError{Field: "currency", Rule: "supported", Message: currency + " is not a supported currency"})
+	}
+	if !taxes.HasRegion(region) {
+		return Quote{}, apperror.Invalid(apperror.FieldError{Field: "region", Rule: "supported", Message: region + " is not a region we sell in"})
+	}
+	return Quote{
+		Base:     rates.Base,
+		Currency: currency,
+		Region:   region,
+		Rate:     rate,
+		taxes:    taxes,
+	}, nil
+}
+
+// Quote prices one order: Rate converts from Base to Currency and the
+// taxes are those of Region.
+type Quote struct {
+	Base     string
+	Currency string
+	Region   string
+	Rate     float64
+	taxes    TaxRules
+}
+
+// Convert converts an amount in the base currency to the currency of the
+// quote.
+func (q Quote) Convert(amount float64) float64 {
+	return Round(amount * q.Rate)
+}
+
+// Line is one order line, priced in the currency of the quote.
+type Line struct {
+	Category  string
+	Quantity  int
+	UnitPrice float64
+}
+
+// TaxLine is one tax on a line or an order.
+type TaxLine struct {
+	Name   string  `json:"name" bson:"name"`
+	Rate   float64 `json:"rate" bson:"rate"` // Percent
+	Amount float64 `json:"amount" bson:"amount"`
+}
+
+// LineTotals are the amounts of one line: Total = Subtotal - Discount + Tax.
+type LineTotals struct {
+	Subtotal float64
+	Discount float64
+	Taxes    []TaxLine
+	Tax      float64
+	Total    float64
+}
+
+// Totals are the amounts of an order, in the currency of the quote:
+// Total = Subtotal + Shipping - Discount + Tax, where Tax is the tax of
+// the lines plus ShippingTax. Taxes adds up the taxes of the lines and of
+// the shipping fee per tax and rate.
+type Totals struct {
+	Lines       []LineTotals
+	Subtotal    float64
+	Shipping    float64
+	Discount    float64
+	Taxes       []TaxLine
+	ShippingTax float64
+	Tax         float64
+	Total       float64
+}
+
+// Price totals lines and shipping. itemDiscount is spread over the lines in
+// proportion to their subtotals and shippingDiscount comes off the
+// shipping fee; each is taxed on what is left after its discount.
+func (q Quote) Price(lines []Line, shipping, itemDiscount, shippingDiscount float64) Totals {
+	var t Totals
+	t.Lines = make([]LineTotals, len(lines))
+	for i, line := range lines {
+		t.Lines[i].Subtotal = Round(line.UnitPrice * float64(line.Quantity))
+		t.Subtotal += t.Lines[i].Subtotal
+	}
+	t.Subtotal = Round(t.Subtotal)
+	itemDiscount = math.Min(itemDiscount, t.Subtotal)
+	shippingDiscount = math.Min(shippingDiscount, shipping)
+
+	// Spread the discount by share; the last line takes the rounding rest
+	// so the lines add up to the order.
+	left := itemDiscount
+	for i := range t.Lines {
+		if t.Subtotal == 0 {
+			break
+		}
+		share := Round(itemDiscount * t.Lines[i].Subtotal / t.Subtotal)
+		if i == len(t.Lines)-1 || share > left {
+			share = left
+		}
+		t.Lines[i].Discount = share
+		left = Round(left - share)
+	}
+
+	taxes := []TaxLine{}
+	add := func(rules []TaxRule, base float64) ([]TaxLine, float64) {
+		var lines []TaxLine
+		var total float64
+		for _, rule := range rules {
+			tax := TaxLine{Name: rule.Name, Rate: rule.Rate, Amount: Round(base * rule.Rate / 100)}
+			lines = append(lines, tax)
+			total += tax.Amount
+			taxes = addTax(taxes, tax)
+		}
+		return lines, Round(total)
+	}
+	for i, line := range lines {
+		lt := &t.Lines[i]
+		lt.Taxes, lt.Tax = add(q.taxes.For(q.Region, line.Category), lt.Subtotal-lt.Discount)
+		lt.Total = Round(lt.Subtotal - lt.Discount + lt.Tax)
+		t.Tax += lt.Tax
+	}
+	_, t.ShippingTax = add(q.taxes.For(q.Region, ShippingCategory), shipping-shippingDiscount)
+	t.Tax = Round(t.Tax + t.ShippingTax)
+
+	t.Shipping = Round(shipping)
+	t.Discount = Round(itemDiscount + shippingDiscount)
+	t.Taxes = taxes
+	t.Total = Round(t.Subtotal + t.Shipping - t.Discount + t.Tax)
+	return t
+}
+
+// addTax adds tax to the total of the same tax and rate in taxes.
+func addTax(taxes []TaxLine, tax TaxLine) []TaxLine {
+	for i := range taxes {
+		if taxes[i].Name == tax.Name && taxes[i].Rate == tax.Rate {
+			taxes[i].Amount = Round(taxes[i].Amount + tax.Amount)
+			return taxes
+		}
+	}
+	return append(taxes, tax)
+}
+
+// Round rounds an amount to cents.
+func Round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,164 @@
+package pricing
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQuotePrice(t *testing.T) {
+	rules := TaxRules{
+		DefaultRegion: "TH",
+		Rules: []TaxRule{
+			{Region: "TH", Category: AnyCategory, Name: "VAT", Rate: 7},
+			{Region: "TH", Category: "books", Name: "VAT", Rate: 0},
+			{Region: "DE", Category: AnyCategory, Name: "MwSt", Rate: 19},
+			{Region: "DE", Category: "books", Name: "MwSt", Rate: 7},
+		},
+	}
+	tests := []struct {
+		name             string
+		region           string
+		lines            []Line
+		shipping         float64
+		itemDiscount     float64
+		shippingDiscount float64
+		lineDiscounts    []float64
+		taxes            []TaxLine
+		discount         float64
+		tax              float64
+		total            float64
+	}{
+		{
+			name:          "taxed per category with shipping",
+			lines:         []Line{{Category: "toys", Quantity: 2, UnitPrice: 10}, {Category: "books", Quantity: 1, UnitPrice: 15}},
+			shipping:      5,
+			lineDiscounts: []float64{0, 0},
+			taxes:         []TaxLine{{Name: "VAT", Rate: 7, Amount: 1.75}, {Name: "VAT", Rate: 0, Amount: 0}},
+			tax:           1.75,
+			total:         41.75,
+		},
+		{
+			name:          "discount spread by share, the last line takes the rest",
+			lines:         []Line{{Category: "toys", Quantity: 1, UnitPrice: 10}, {Category: "toys", Quantity: 1, UnitPrice: 10}, {Category: "toys", Quantity: 1, UnitPrice: 10}},
+			itemDiscount:  10,
+			lineDiscounts: []float64{3.33, 3.33, 3.34},
+			taxes:         []TaxLine{{Name: "VAT", Rate: 7, Amount: 1.41}},
+			discount:      10,
+			tax:           1.41,
+			total:         21.41,
+		},
+		{
+			name:          "discount smaller than a cent per line",
+			lines:         []Line{{Category: "books", Quantity: 1, UnitPrice: 0.01}, {Category: "books", Quantity: 1, UnitPrice: 0.01}, {Category: "books", Quantity: 1, UnitPrice: 0.01}},
+			itemDiscount:  0.02,
+			lineDiscounts: []float64{0.01, 0.01, 0},
+			taxes:         []TaxLine{{Name: "VAT", Rate: 0, Amount: 0}, {Name: "VAT", Rate: 7, Amount: 0}},
+			discount:      0.02,
+			total:         0.01,
+		},
+		{
+			name:          "100% discount leaves only shipping",
+			lines:         []Line{{Category: "toys", Quantity: 2, UnitPrice: 12.5}, {Category: "books", Quantity: 1, UnitPrice: 5}},
+			shipping:      4,
+			itemDiscount:  30,
+			lineDiscounts: []float64{25, 5},
+			taxes:         []TaxLine{{Name: "VAT", Rate: 7, Amount: 0.28}, {Name: "VAT", Rate: 0, Amount: 0}},
+			discount:      30,
+			tax:           0.28,
+			total:         4.28,
+		},
+		{
+			name:          "discount capped at the subtotal",
+			lines:         []Line{{Category: "toys", Quantity: 2, UnitPrice: 12.5}, {Category: "books", Quantity: 1, UnitPrice: 5}},
+			shipping:      4,
+			itemDiscount:  50,
+			lineDiscounts: []float64{25, 5},
+			taxes:         []TaxLine{{Name: "VAT", Rate: 7, Amount: 0.28}, {Name: "VAT", Rate: 0, Amount: 0}},
+			discount:      30,
+			tax:           0.28,
+			total:         4.28,
+		},
+		{
+			name:             "shipping taxed after free shipping",
+			lines:            []Line{{Category: "toys", Quantity: 1, UnitPrice: 20}},
+			shipping:         5,
+			shippingDiscount: 5,
+			lineDiscounts:    []float64{0},
+			taxes:            []TaxLine{{Name: "VAT", Rate: 7, Amount: 1.4}},
+			discount:         5,
+			tax:              1.4,
+			total:            21.4,
+		},
+		{
+			name:          "zero subtotal",
+			lines:         []Line{{Category: "toys", Quantity: 2, UnitPrice: 0}},
+			itemDiscount:  5,
+			lineDiscounts: []float64{0},
+			taxes:         []TaxLine{{Name: "VAT", Rate: 7, Amount: 0}},
+			total:         0,
+		},
+		{
+			name:          "no lines",
+			shipping:      3,
+			lineDiscounts: []float64{},
+			taxes:         []TaxLine{{Name: "VAT", Rate: 7, Amount: 0.21}},
+			tax:           0.21,
+			total:         3.21,
+		},
+		{
+			name:          "taxes added up by name and rate",
+			region:        "DE",
+			lines:         []Line{{Category: "electronics", Quantity: 1, UnitPrice: 100}, {Category: "books", Quantity: 2, UnitPrice: 10}},
+			shipping:      10,
+			lineDiscounts: []float64{0, 0},
+			taxes:         []TaxLine{{Name: "MwSt", Rate: 19, Amount: 20.9}, {Name: "MwSt", Rate: 7, Amount: 1.4}},
+			tax:           22.3,
+			total:         152.3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			region := tt.region
+			if region == "" {
+				region = "TH"
+			}
+			q := Quote{Base: "THB", Currency: "THB", Region: region, Rate: 1, taxes: rules}
+			got := q.Price(tt.lines, tt.shipping, tt.itemDiscount, tt.shippingDiscount)
+
+			discounts := []float64{}
+			for _, line := range got.Lines {
+				discounts = append(discounts, line.Discount)
+			}
+			if !reflect.DeepEqual(discounts, tt.lineDiscounts) {
+				t.Errorf("line discounts = %v, want %v", discounts, tt.lineDiscounts)
+			}
+			if !reflect.DeepEqual(got.Taxes, tt.taxes) {
+				t.Errorf("Taxes = %+v, want %+v", got.Taxes, tt.taxes)
+			}
+			if got.Discount != tt.discount || got.Tax != tt.tax || got.Total != tt.total {
+				t.Errorf("Discount, Tax, Total = %.2f, %.2f, %.2f, want %.2f, %.2f, %.2f", got.Discount, got.Tax, got.Total, tt.discount, tt.tax, tt.total)
+			}
+
+			// The lines and the shipping add up to the order.
+			var lineDiscount, lineTax, lineTotal, taxes float64
+			for _, line := range got.Lines {
+				lineDiscount += line.Discount
+				lineTax += line.Tax
+				lineTotal += line.Total
+				if line.Total != Round(line.Subtotal-line.Discount+line.Tax) {
+					t.Errorf("line %+v does not add up", line)
+				}
+			}
+			for _, tax := range got.Taxes {
+				taxes += tax.Amount
+			}
+			shippingDiscount := Round(got.Discount - lineDiscount)
+			if Round(lineTax+got.ShippingTax) != got.Tax || Round(taxes) != got.Tax {
+				t.Errorf("taxes of the lines %.2f, shipping %.2f and by rate %.2f do not add up to %.2f", lineTax, got.ShippingTax, taxes, got.Tax)
+			}
+			if Round(lineTotal+got.Shipping-shippingDiscount+got.ShippingTax) != got.Total {
+				t.Errorf("lines %.2f and shipping %.2f - %.2f + %.2f do not add up to %.2f", lineTotal, got.Shipping, shippingDiscount, got.ShippingTax, got.Total)
+			}
+		})
+	}
+}
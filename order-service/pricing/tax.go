@@ -0,0 +1,78 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AnyCategory is the category of a rule that applies to every category
+// without a rule of its own.
+const AnyCategory = "*"
+
+// ShippingCategory is the category the shipping fee is taxed as.
+const ShippingCategory = "shipping"
+
+// TaxRule is one tax of a region on a product category.
+type TaxRule struct {
+	Region   string  `json:"region"`
+	Category string  `json:"category"`
+	Name     string  `json:"name"` // e.g. VAT
+	Rate     float64 `json:"rate"` // Percent
+}
+
+// TaxRules are the tax rules of every region sold in. Orders that name no
+// region are taxed as DefaultRegion.
+//
+//	{"default_region": "TH", "rules": [
+//	    {"region": "TH", "category": "*", "name": "VAT", "rate": 7},
+//	    {"region": "TH", "category": "books", "name": "VAT", "rate": 0}]}
+type TaxRules struct {
+	DefaultRegion string    `json:"default_region"`
+	Rules         []TaxRule `json:"rules"`
+}
+
+// For returns the taxes on category in region: for every tax name, the
+// rule for the category if there is one, else the rule for AnyCategory.
+func (t TaxRules) For(region, category string) []TaxRule {
+	var taxes []TaxRule
+	index := map[string]int{}
+	for _, rule := range t.Rules {
+		if rule.Region != region || (rule.Category != category && rule.Category != AnyCategory) {
+			continue
+		}
+		i, seen := index[rule.Name]
+		switch {
+		case !seen:
+			index[rule.Name] = len(taxes)
+			taxes = append(taxes, rule)
+		case rule.Category == category:
+			taxes[i] = rule
+		}
+	}
+	return taxes
+}
+
+// HasRegion reports whether region has any rule.
+func (t TaxRules) HasRegion(region string) bool {
+	for _, rule := range t.Rules {
+		if rule.Region == region {
+			return true
+		}
+	}
+	return false
+}
+
+func validateTaxRules(t TaxRules) error {
+	if t.DefaultRegion == "" {
+		return errors.New("default region is missing")
+	}
+	for i, rule := range t.Rules {
+		if rule.Region == "" || rule.Category == "" || rule.Name == "" {
+			return fmt.Errorf("rule %d needs a region, category and name", i)
+		}
+		if rule.Rate < 0 || rule.Rate > 100 {
+			return fmt.Errorf("rule %d: rate must be a percent", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package promotion
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sing3demons/go-order-service/apperror"
+)
+
+// rejected is the validation error for a code that cannot be used on this
+// order; rule says why.
+func rejected(code, rule, message string) error {
+	return apperror.Invalid(apperror.FieldError{
+		Field:   "promo_codes",
+		Rule:    rule,
+		Message: code + " " + message,
+	})
+}
+
+// Check reports why p cannot be used at now on a basket, or nil. Usage
+// limits are not checked here: they are enforced when the code is redeemed.
+func (p Promotion) Check(b Basket, now time.Time) error {
+	switch {
+	case !p.Active:
+		return rejected(p.Code, "inactive", "is not active")
+	case p.StartsAt != nil && now.Before(*p.StartsAt):
+		return rejected(p.Code, "not_started", "is not valid yet")
+	case p.EndsAt != nil && !now.Before(*p.EndsAt):
+		return rejected(p.Code, "expired", "has expired")
+	case b.Subtotal < b.convert(p.MinOrder):
+		return rejected(p.Code, "min_order", fmt.Sprintf("needs an order of at least %.2f", b.convert(p.MinOrder)))
+	}
+	return nil
+}
+
+// Discount returns what p takes off the basket. Discounts on items never
+// exceed what is left of the subtotal after the discounts already applied;
+// free shipping waives the shipping fee.
+func (p Promotion) Discount(b Basket, applied []Discount) Discount {
+	left := b.Subtotal
+	for _, d := range applied {
+		if d.Type != TypeFreeShipping {
+			left -= d.Amount
+		}
+	}
+
+	var amount float64
+	switch p.Type {
+	case TypePercent:
+		amount = b.Subtotal * p.Percent / 100
+	case TypeFixed:
+		amount = b.convert(p.Amount)
+	case TypeBuyXGetY:
+		amount = p.freeItems(b)
+	case TypeFreeShipping:
+		return Discount{Code: p.Code, Type: p.Type, Amount: roundCents(b.ShippingFee)}
+	}
+	return Discount{Code: p.Code, Type: p.Type, Amount: roundCents(math.Max(0, math.Min(amount, left)))}
+}
+
+// freeItems is the value of the units a buy-X-get-Y makes free: on every
+// eligible line, GetQuantity of every BuyQuantity+GetQuantity units.
+func (p Promotion) freeItems(b Basket) float64 {
+	group := p.BuyQuantity + p.GetQuantity
+	if group <= 0 || p.GetQuantity <= 0 {
+		return 0
+	}
+	var amount float64
+	for _, line := range b.Lines {
+		if !p.appliesTo(line.ProductID) {
+			continue
+		}
+		free := line.Quantity / group * p.GetQuantity
+		amount += float64(free) * line.Price
+	}
+	return amount
+}
+
+func (p Promotion) appliesTo(productID string) bool {
+	if len(p.ProductIDs) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIDs {
+		if id == productID {
+			return true
+		}
+	}
+	return false
+}
+
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,121 @@
+package promotion
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sing3demons/go-order-service/apperror"
+)
+
+func TestDiscount(t *testing.T) {
+	basket := Basket{
+		Lines: []Line{
+			{ProductID: "p1", Quantity: 3, Price: 10},
+			{ProductID: "p2", Quantity: 5, Price: 4.99},
+		},
+		Subtotal:    54.95,
+		ShippingFee: 7.5,
+	}
+	tests := []struct {
+		name    string
+		promo   Promotion
+		basket  Basket
+		applied []Discount
+		want    float64
+	}{
+		{name: "percent", promo: Promotion{Type: TypePercent, Percent: 10}, want: 5.5},
+		{name: "percent rounds to cents", promo: Promotion{Type: TypePercent, Percent: 15}, want: 8.24},
+		{name: "fixed", promo: Promotion{Type: TypeFixed, Amount: 5}, want: 5},
+		{name: "fixed capped at the subtotal", promo: Promotion{Type: TypeFixed, Amount: 100}, want: 54.95},
+		{
+			name:   "fixed converted to the order currency",
+			promo:  Promotion{Type: TypeFixed, Amount: 5},
+			basket: Basket{Subtotal: 100, Rate: 1.333},
+			want:   6.67,
+		},
+		{
+			name:    "fixed capped at what earlier discounts left",
+			promo:   Promotion{Type: TypeFixed, Amount: 20},
+			applied: []Discount{{Type: TypePercent, Amount: 40}},
+			want:    14.95,
+		},
+		{
+			name:    "free shipping does not use up the subtotal",
+			promo:   Promotion{Type: TypeFixed, Amount: 20},
+			applied: []Discount{{Type: TypeFreeShipping, Amount: 7.5}},
+			want:    20,
+		},
+		{name: "buy 2 get 1 on every line", promo: Promotion{Type: TypeBuyXGetY, BuyQuantity: 2, GetQuantity: 1}, want: 14.99},
+		{
+			name:  "buy 2 get 1 on some products",
+			promo: Promotion{Type: TypeBuyXGetY, BuyQuantity: 2, GetQuantity: 1, ProductIDs: []string{"p2"}},
+			want:  4.99,
+		},
+		{name: "buy x get nothing", promo: Promotion{Type: TypeBuyXGetY, BuyQuantity: 2}, want: 0},
+		{name: "free shipping", promo: Promotion{Type: TypeFreeShipping}, want: 7.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := basket
+			if tt.basket.Subtotal != 0 {
+				b = tt.basket
+			}
+			tt.promo.Code = "PROMO"
+			got := tt.promo.Discount(b, tt.applied)
+			if got.Amount != tt.want || got.Code != "PROMO" || got.Type != tt.promo.Type {
+				t.Errorf("Discount = %+v, want %.2f off", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+	basket := Basket{Subtotal: 50}
+
+	tests := []struct {
+		name   string
+		promo  Promotion
+		basket Basket
+		rule   string // empty when the promotion can be used
+	}{
+		{name: "usable", promo: Promotion{Active: true}},
+		{name: "inactive", promo: Promotion{}, rule: "inactive"},
+		{name: "inside the window", promo: Promotion{Active: true, StartsAt: &before, EndsAt: &after}},
+		{name: "starts at now", promo: Promotion{Active: true, StartsAt: &now}},
+		{name: "not started", promo: Promotion{Active: true, StartsAt: &after}, rule: "not_started"},
+		{name: "ends at now", promo: Promotion{Active: true, EndsAt: &now}, rule: "expired"},
+		{name: "expired", promo: Promotion{Active: true, EndsAt: &before}, rule: "expired"},
+		{name: "meets the minimum", promo: Promotion{Active: true, MinOrder: 50}},
+		{name: "under the minimum", promo: Promotion{Active: true, MinOrder: 50.01}, rule: "min_order"},
+		{
+			name:   "minimum converted to the order currency",
+			promo:  Promotion{Active: true, MinOrder: 40},
+			basket: Basket{Subtotal: 50, Rate: 1.3},
+			rule:   "min_order",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := basket
+			if tt.basket.Subtotal != 0 {
+				b = tt.basket
+			}
+			tt.promo.Code = "PROMO"
+			err := tt.promo.Check(b, now)
+			if tt.rule == "" {
+				if err != nil {
+					t.Errorf("Check = %v, want nil", err)
+				}
+				return
+			}
+			var appErr *apperror.Error
+			if !errors.As(err, &appErr) || len(appErr.Fields) != 1 || appErr.Fields[0].Rule != tt.rule {
+				t.Errorf("Check = %v, want rule %s", err, tt.rule)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package promotion
+
+import (
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/validation"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// ActiveRequest switches a promotion on or off.
+type ActiveRequest struct {
+	Active *bool `json:"active" validate:"required"`
+}
+
+// HandleCreatePromotion creates a discount code
+func (h *Handler) HandleCreatePromotion(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "create_promotion",
+		Code:        "200",
+		Description: "",
+	}
+	var req Promotion
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("create promotion", ""), map[string]any{
+		"body": req,
+	})
+
+	p, err := h.service.CreatePromotion(ctx, req)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(201, p)
+}
+
+// HandleListPromotions lists every promotion, newest first
+func (h *Handler) HandleListPromotions(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "list_promotions",
+		Code:        "200",
+		Description: "",
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("list promotions", ""), map[string]any{})
+
+	promotions, err := h.service.ListPromotions(ctx)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, promotions)
+}
+
+// HandleGetPromotion returns one promotion with its use count
+func (h *Handler) HandleGetPromotion(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "get_promotion",
+		Code:        "200",
+		Description: "",
+	}
+	code := ctx.PathParam("code")
+	if err := validation.Var("code", code, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("get promotion", ""), map[string]any{
+		"code": code,
+	})
+
+	p, err := h.service.GetPromotion(ctx, code)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, p)
+}
+
+// HandleSetActive switches a promotion on or off
+func (h *Handler) HandleSetActive(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "set_promotion_active",
+		Code:        "200",
+		Description: "",
+	}
+	code := ctx.PathParam("code")
+	if err := validation.Var("code", code, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var req ActiveRequest
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("set promotion active", ""), map[string]any{
+		"code": code,
+		"body": req,
+	})
+
+	p, err := h.service.SetActive(ctx, code, *req.Active)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, p)
+}
@@ -0,0 +1,67 @@
+package promotion
+
+import "time"
+
+// The kinds of promotion.
+const (
+	TypePercent      = "percent"       // Percent off the subtotal
+	TypeFixed        = "fixed"         // Amount off the subtotal
+	TypeBuyXGetY     = "bxgy"          // Buy BuyQuantity, get GetQuantity more free
+	TypeFreeShipping = "free_shipping" // The shipping fee is waived
+)
+
+// Promotion is a discount code. Zero limits mean no limit; a nil window
+// bound means open on that side. Amount and MinOrder are in the base
+// currency and converted to that of each order.
+type Promotion struct {
+	Code             string     `json:"code" bson:"_id" validate:"required,alphanum,max=32"`
+	Description      string     `json:"description,omitempty" bson:"description,omitempty" validate:"max=200"`
+	Type             string     `json:"type" bson:"type" validate:"required,oneof=percent fixed bxgy free_shipping"`
+	Percent          float64    `json:"percent,omitempty" bson:"percent,omitempty" validate:"required_if=Type percent,gte=0,lte=100"`
+	Amount           float64    `json:"amount,omitempty" bson:"amount,omitempty" validate:"required_if=Type fixed,gte=0"`
+	BuyQuantity      int        `json:"buy_quantity,omitempty" bson:"buy_quantity,omitempty" validate:"required_if=Type bxgy,gte=0"`
+	GetQuantity      int        `json:"get_quantity,omitempty" bson:"get_quantity,omitempty" validate:"required_if=Type bxgy,gte=0"`
+	ProductIDs       []string   `json:"product_ids,omitempty" bson:"product_ids,omitempty"` // Products a bxgy applies to; empty is all
+	MinOrder         float64    `json:"min_order,omitempty" bson:"min_order,omitempty" validate:"gte=0"`
+	StartsAt         *time.Time `json:"starts_at,omitempty" bson:"starts_at,omitempty"`
+	EndsAt           *time.Time `json:"ends_at,omitempty" bson:"ends_at,omitempty"`
+	UsageLimit       int        `json:"usage_limit,omitempty" bson:"usage_limit" validate:"gte=0"`
+	PerCustomerLimit int        `json:"per_customer_limit,omitempty" bson:"per_customer_limit" validate:"gte=0"`
+	Used             int        `json:"used" bson:"used"`
+	Active           bool       `json:"active" bson:"active"`
+	CreatedAt        time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at" bson:"updated_at"`
+}
+
+// Discount is a promotion as applied to one order. It is stored on the
+// order so its total can be recomputed from the order alone.
+type Discount struct {
+	Code   string  `json:"code" bson:"code"`
+	Type   string  `json:"type" bson:"type"`
+	Amount float64 `json:"amount" bson:"amount"`
+}
+
+// Basket is what promotions are evaluated against, in the currency of the
+// order. The Amount and MinOrder of promotions are in the base currency;
+// Rate converts them, zero meaning the basket is in the base currency.
+type Basket struct {
+	Lines       []Line
+	Subtotal    float64
+	ShippingFee float64
+	Rate        float64
+}
+
+// convert converts an amount in the base currency to that of the basket.
+func (b Basket) convert(amount float64) float64 {
+	if b.Rate == 0 {
+		return amount
+	}
+	return roundCents(amount * b.Rate)
+}
+
+// Line is one priced item of a basket.
+type Line struct {
+	ProductID string
+	Quantity  int
+	Price     float64
+}
@@ -0,0 +1,315 @@
+package promotion
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository stores promotions in the promotions collection and counts the
+// uses of each code per customer in promotion_redemptions. Both counters
+// are raised with conditional updates, so concurrent orders cannot use a
+// code more often than its limits allow.
+type Repository interface {
+	Create(ctx *kp.Context, p Promotion) (Promotion, error)
+	FindByCode(ctx *kp.Context, code string) (Promotion, error)
+	FindAll(ctx *kp.Context) ([]Promotion, error)
+	SetActive(ctx *kp.Context, code string, active bool) (Promotion, error)
+	Redeem(ctx *kp.Context, p Promotion, customerID string) error
+	Release(ctx *kp.Context, code, customerID string) error
+}
+
+type repository struct {
+	col         *mongo.Collection
+	redemptions *mongo.Collection
+}
+
+func NewRepository(col, redemptions *mongo.Collection) Repository {
+	return &repository{
+		col:         col,
+		redemptions: redemptions,
+	}
+}
+
+var (
+	ErrPromotionNotFound = apperror.NotFound("promotion_not_found", "promotion not found")
+	ErrPromotionExists   = apperror.Conflict("promotion_exists", "code", "a promotion with this code already exists")
+)
+
+func (r *repository) Create(ctx *kp.Context, p Promotion) (Promotion, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "create_promotion",
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.INSERT, "insert promotion"), map[string]any{
+		"collection": r.col.Name(),
+		"promotion":  p,
+	})
+
+	result, err := r.col.InsertOne(ctx, p)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			summary.Code = "409"
+			summary.Description = "promotion exists"
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.INSERT, "insert promotion failed"), map[string]string{
+				"error": err.Error(),
+			})
+			return Promotion{}, ErrPromotionExists
+		}
+		summary.Code = "500"
+		summary.Description = "failed to insert promotion"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.INSERT, "insert promotion failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Promotion{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.INSERT, "insert promotion success"), map[string]any{
+		"Return": result,
+	})
+	return p, nil
+}
+
+func (r *repository) FindByCode(ctx *kp.Context, code string) (Promotion, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "find_promotion",
+		Code:        "200",
+		Description: "success",
+	}
+	filter := bson.M{"_id": code}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find promotion"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	var p Promotion
+	err := r.col.FindOne(ctx, filter).Decode(&p)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			summary.Code = "404"
+			summary.Description = "promotion not found"
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find promotion failed"), map[string]string{
+				"error": err.Error(),
+			})
+			return Promotion{}, ErrPromotionNotFound
+		}
+		summary.Code = "500"
+		summary.Description = "failed to find promotion"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find promotion failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Promotion{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find promotion success"), map[string]any{
+		"Return": p,
+	})
+	return p, nil
+}
+
+func (r *repository) FindAll(ctx *kp.Context) ([]Promotion, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "find_promotions",
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find promotions"), map[string]any{
+		"collection": r.col.Name(),
+	})
+
+	cursor, err := r.col.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to find promotions"
+		summary.ResTime = time.Since(start).Milliseconds()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find promotions failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	promotions := []Promotion{}
+	err = cursor.All(ctx, &promotions)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to decode promotions"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find promotions failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find promotions success"), map[string]any{
+		"count": len(promotions),
+	})
+	return promotions, nil
+}
+
+func (r *repository) SetActive(ctx *kp.Context, code string, active bool) (Promotion, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "set_promotion_active",
+		Code:        "200",
+		Description: "success",
+	}
+	filter := bson.M{"_id": code}
+	update := bson.M{"$set": bson.M{"active": active, "updated_at": time.Now().UTC()}}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "set promotion active"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"update":     update,
+	})
+
+	var p Promotion
+	err := r.col.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&p)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			summary.Code = "404"
+			summary.Description = "promotion not found"
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "set promotion active failed"), map[string]string{
+				"error": err.Error(),
+			})
+			return Promotion{}, ErrPromotionNotFound
+		}
+		summary.Code = "500"
+		summary.Description = "failed to update promotion"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "set promotion active failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Promotion{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "set promotion active success"), map[string]any{
+		"Return": p,
+	})
+	return p, nil
+}
+
+// Redeem counts one use of p by customerID. It fails with a validation
+// error, and changes nothing, when either limit is already reached.
+func (r *repository) Redeem(ctx *kp.Context, p Promotion, customerID string) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "redeem_promotion",
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "redeem promotion"), map[string]any{
+		"collection":  r.col.Name(),
+		"code":        p.Code,
+		"customer_id": customerID,
+	})
+
+	fail := func(code string, err error, cause string) error {
+		summary.ResTime = time.Since(start).Milliseconds()
+		summary.Code = code
+		summary.Description = cause
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "redeem promotion failed"), map[string]string{
+			"error": cause,
+		})
+		return err
+	}
+
+	result, err := r.col.UpdateOne(ctx, bson.M{
+		"_id": p.Code,
+		"$or": bson.A{
+			bson.M{"usage_limit": 0},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$used", "$usage_limit"}}},
+		},
+	}, bson.M{"$inc": bson.M{"used": 1}})
+	if err != nil {
+		return fail("500", apperror.Internal(err), err.Error())
+	}
+	if result.MatchedCount == 0 {
+		return fail("400", rejected(p.Code, "usage_limit", "has been used up"), "usage limit reached")
+	}
+
+	if p.PerCustomerLimit > 0 {
+		// At the limit the filter misses and the upsert collides with the
+		// existing counter on _id.
+		_, err := r.redemptions.UpdateOne(ctx,
+			bson.M{"_id": redemptionID(p.Code, customerID), "count": bson.M{"$lt": p.PerCustomerLimit}},
+			bson.M{
+				"$inc":         bson.M{"count": 1},
+				"$setOnInsert": bson.M{"code": p.Code, "customer_id": customerID},
+			},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			// Give back the use counted above; this order does not get it.
+			_, _ = r.col.UpdateOne(ctx, bson.M{"_id": p.Code}, bson.M{"$inc": bson.M{"used": -1}})
+			if mongo.IsDuplicateKeyError(err) {
+				return fail("400", rejected(p.Code, "per_customer_limit", "has been used the maximum number of times by this customer"), "per customer limit reached")
+			}
+			return fail("500", apperror.Internal(err), err.Error())
+		}
+	}
+
+	summary.ResTime = time.Since(start).Milliseconds()
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "redeem promotion success"), map[string]any{
+		"code":        p.Code,
+		"customer_id": customerID,
+	})
+	return nil
+}
+
+// Release gives back a use counted by Redeem, for an order that was not
+// placed after all.
+func (r *repository) Release(ctx *kp.Context, code, customerID string) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "release_promotion",
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "release promotion"), map[string]any{
+		"collection":  r.col.Name(),
+		"code":        code,
+		"customer_id": customerID,
+	})
+
+	_, err := r.col.UpdateOne(ctx, bson.M{"_id": code, "used": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"used": -1}})
+	if err == nil {
+		_, err = r.redemptions.UpdateOne(ctx,
+			bson.M{"_id": redemptionID(code, customerID), "count": bson.M{"$gt": 0}},
+			bson.M{"$inc": bson.M{"count": -1}})
+	}
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to release promotion"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "release promotion failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "release promotion success"), map[string]any{
+		"code":        code,
+		"customer_id": customerID,
+	})
+	return nil
+}
+
+func redemptionID(code, customerID string) string {
+	return code + ":" + customerID
+}
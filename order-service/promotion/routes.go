@@ -0,0 +1,23 @@
+package promotion
+
+import (
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-order-service/servicetoken"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RegisterRoutes registers the promotion admin routes and returns the
+// service orders apply their codes through. Whoever can create a code can
+// give anything away, so the admin routes take an admin token from the
+// "token" subcommand, see servicetoken.
+func RegisterRoutes(app kp.IApplication, db *mongo.Database, guard *servicetoken.Guard) Service {
+	repo := NewRepository(db.Collection("promotions"), db.Collection("promotion_redemptions"))
+	service := NewService(repo)
+	handler := NewHandler(service)
+	admin := guard.Admin()
+	app.Post("/internal/promotions", admin.Require(handler.HandleCreatePromotion))
+	app.Get("/internal/promotions", admin.Require(handler.HandleListPromotions))
+	app.Get("/internal/promotions/{code}", admin.Require(handler.HandleGetPromotion))
+	app.Put("/internal/promotions/{code}/active", admin.Require(handler.HandleSetActive))
+	return service
+}
@@ -0,0 +1,104 @@
+package promotion
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-order-service/apperror"
+)
+
+type Service interface {
+	CreatePromotion(ctx *kp.Context, p Promotion) (Promotion, error)
+	GetPromotion(ctx *kp.Context, code string) (Promotion, error)
+	ListPromotions(ctx *kp.Context) ([]Promotion, error)
+	SetActive(ctx *kp.Context, code string, active bool) (Promotion, error)
+	Apply(ctx *kp.Context, customerID string, codes []string, b Basket) ([]Discount, error)
+	Release(ctx *kp.Context, customerID string, discounts []Discount)
+}
+
+type service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) Service {
+	return &service{
+		repo: repo,
+	}
+}
+
+var errWindow = apperror.Invalid(apperror.FieldError{Field: "ends_at", Rule: "gtfield", Message: "must be after starts_at"})
+
+// CreatePromotion stores a new, active promotion. Codes are upper case.
+func (s *service) CreatePromotion(ctx *kp.Context, p Promotion) (Promotion, error) {
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return Promotion{}, errWindow
+	}
+	now := time.Now().UTC()
+	p.Code = strings.ToUpper(p.Code)
+	p.Used = 0
+	p.Active = true
+	p.CreatedAt = now
+	p.UpdatedAt = now
+	return s.repo.Create(ctx, p)
+}
+
+func (s *service) GetPromotion(ctx *kp.Context, code string) (Promotion, error) {
+	return s.repo.FindByCode(ctx, strings.ToUpper(code))
+}
+
+func (s *service) ListPromotions(ctx *kp.Context) ([]Promotion, error) {
+	return s.repo.FindAll(ctx)
+}
+
+func (s *service) SetActive(ctx *kp.Context, code string, active bool) (Promotion, error) {
+	return s.repo.SetActive(ctx, strings.ToUpper(code), active)
+}
+
+// Apply checks every code against the basket, works out its discount and
+// redeems it for customerID, all or nothing: when one code fails, the uses
+// already counted are given back. Discounts are applied in the order the
+// codes were given.
+func (s *service) Apply(ctx *kp.Context, customerID string, codes []string, b Basket) ([]Discount, error) {
+	now := time.Now()
+	seen := map[string]bool{}
+	var promotions []Promotion
+	var discounts []Discount
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if seen[code] {
+			return nil, rejected(code, "unique", "is given more than once")
+		}
+		seen[code] = true
+
+		p, err := s.repo.FindByCode(ctx, code)
+		if err == ErrPromotionNotFound {
+			return nil, rejected(code, "exists", "does not exist")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := p.Check(b, now); err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, p)
+		discounts = append(discounts, p.Discount(b, discounts))
+	}
+
+	for i, p := range promotions {
+		if err := s.repo.Redeem(ctx, p, customerID); err != nil {
+			s.Release(ctx, customerID, discounts[:i])
+			return nil, err
+		}
+	}
+	return discounts, nil
+}
+
+// Release gives back the uses of discounts returned by Apply, for an order
+// that could not be placed. Failures are logged by the repository; a use
+// that is not given back only makes a limit a little stricter.
+func (s *service) Release(ctx *kp.Context, customerID string, discounts []Discount) {
+	for _, d := range discounts {
+		_ = s.repo.Release(ctx, d.Code, customerID)
+	}
+}
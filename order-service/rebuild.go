@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-order-service/order"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const rebuildUsage = `usage: order-service rebuild <projection>
+
+projections:
+  order_view      seed from the orders and replay the order lifecycle events`
+
+// runRebuild implements the "rebuild" subcommand.
+func runRebuild(conf *config.Config, db *mongo.Database, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing projection\n%s", rebuildUsage)
+	}
+	if conf.Kafka.Broker == "" {
+		return errors.New("KAFKA_BROKER is required")
+	}
+	brokers := strings.Split(conf.Kafka.Broker, ",")
+
+	switch args[0] {
+	case "order_view":
+		if err := order.RebuildView(context.Background(), db, brokers); err != nil {
+			return err
+		}
+		fmt.Println("rebuilt order_view")
+		return nil
+	default:
+		return fmt.Errorf("unknown projection %q\n%s", args[0], rebuildUsage)
+	}
+}
@@ -0,0 +1,76 @@
+package returns
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+)
+
+// The return topics, one per step. Each message is
+// {"event_id": ..., "occurred_at": ..., "body": Return}, the return as it
+// is after the step.
+const (
+	returnRequestedTopic = "return_requested"
+	returnApprovedTopic  = "return_approved"
+	returnRejectedTopic  = "return_rejected"
+	returnReceivedTopic  = "return_received"
+	returnRefundedTopic  = "return_refunded"
+)
+
+// Topics lists the return topics.
+var Topics = []string{returnRequestedTopic, returnApprovedTopic, returnRejectedTopic, returnReceivedTopic, returnRefundedTopic}
+
+// EventMessage is a return event as published to Kafka.
+type EventMessage struct {
+	EventID    string    `json:"event_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Body       Return    `json:"body"`
+}
+
+var errPublishEvent = apperror.Upstream("event_publish_failed", "the return event could not be published", nil)
+
+func publishEvent(ctx *kp.Context, topic string, body Return) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "kafka",
+		Command:     topic,
+		Code:        "200",
+		Description: "success",
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	body.Href = ""
+	message, err := json.Marshal(EventMessage{
+		EventID:    id.String(),
+		OccurredAt: time.Now().UTC(),
+		Body:       body,
+	})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	ctx.Log().Info(logger.NewProducing(topic, ""), map[string]any{
+		"topic": topic,
+		"value": string(message),
+	})
+	if err := ctx.Publish(ctx, topic, message); err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to publish " + topic
+		summary.ResTime = time.Since(start).Milliseconds()
+		ctx.Log().SetSummary(summary).Error(logger.NewProduced(topic, ""), map[string]string{
+			"error": err.Error(),
+		})
+		return errPublishEvent.Wrap(err)
+	}
+	summary.ResTime = time.Since(start).Milliseconds()
+	ctx.Log().SetSummary(summary).Info(logger.NewProduced(topic, ""), map[string]any{
+		"topic": topic,
+	})
+	return nil
+}
@@ -0,0 +1,143 @@
+package returns
+
+import (
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/validation"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// HandleRequestReturn opens a return for items of an order
+func (h *Handler) HandleRequestReturn(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "request_return",
+		Code:        "200",
+		Description: "",
+	}
+	orderID := ctx.PathParam("id")
+	if err := validation.Var("id", orderID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var req CreateRequest
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("request return", ""), map[string]any{
+		"order_id": orderID,
+		"body":     req,
+	})
+
+	ret, err := h.service.RequestReturn(ctx, orderID, req)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(201, h.withHref(ctx, ret))
+}
+
+// HandleListReturns lists the returns of an order
+func (h *Handler) HandleListReturns(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "list_returns",
+		Code:        "200",
+		Description: "",
+	}
+	orderID := ctx.PathParam("id")
+	if err := validation.Var("id", orderID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("list returns", ""), map[string]any{
+		"order_id": orderID,
+	})
+
+	returns, err := h.service.ListReturns(ctx, orderID)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	for i := range returns {
+		returns[i] = h.withHref(ctx, returns[i])
+	}
+	return ctx.JSON(200, returns)
+}
+
+// HandleGetReturn returns one return with its audit trail
+func (h *Handler) HandleGetReturn(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "get_return",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("get return", ""), map[string]any{
+		"id": id,
+	})
+
+	ret, err := h.service.GetReturn(ctx, id)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, h.withHref(ctx, ret))
+}
+
+// HandleApprove approves a requested return
+func (h *Handler) HandleApprove(ctx *kp.Context) error {
+	return h.handleReview(ctx, "approve_return", h.service.Approve)
+}
+
+// HandleReject rejects a requested return
+func (h *Handler) HandleReject(ctx *kp.Context) error {
+	return h.handleReview(ctx, "reject_return", h.service.Reject)
+}
+
+// HandleReceive records the goods of an approved return as back, restocks
+// and refunds them
+func (h *Handler) HandleReceive(ctx *kp.Context) error {
+	return h.handleReview(ctx, "receive_return", h.service.Receive)
+}
+
+func (h *Handler) handleReview(ctx *kp.Context, cmd string, op func(*kp.Context, string, ReviewRequest) (Return, error)) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     cmd,
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var req ReviewRequest
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"id":   id,
+		"body": req,
+	})
+
+	ret, err := op(ctx, id, req)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, h.withHref(ctx, ret))
+}
+
+func (h *Handler) withHref(ctx *kp.Context, ret Return) Return {
+	ret.Href = ctx.HostName() + "/returns/" + ret.ID
+	return ret
+}
@@ -0,0 +1,99 @@
+package returns
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/order"
+	"github.com/sing3demons/go-order-service/servicetoken"
+)
+
+var errProductService = apperror.Upstream("product_service_error", "product-service could not restock the item", nil)
+
+// stockAdjustment is the body of POST /products/{id}/stock.
+type stockAdjustment struct {
+	Delta     int    `json:"delta"`
+	Reason    string `json:"reason"`
+	Reference string `json:"reference"`
+}
+
+// restock puts quantity units of a product back in stock. reference makes
+// it idempotent on product-service, so a retry never counts them twice.
+func restock(ctx *kp.Context, productID string, quantity int, reference string) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "product_service",
+		Command:     "restock_product",
+		Code:        "200",
+		Description: "success",
+	}
+
+	productServiceURL := os.Getenv("PRODUCT_SERVICE_URL")
+	if productServiceURL == "" {
+		productServiceURL = "http://localhost:8082" // Default URL if not set
+	}
+	body, err := json.Marshal(stockAdjustment{Delta: quantity, Reason: "return", Reference: reference})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	httpRequest := order.HttpRequest{
+		URL:      productServiceURL + "/internal/products/" + productID + "/stock",
+		Headers:  map[string]string{"Content-Type": "application/json"},
+		Params:   map[string]string{"product_id": productID},
+		Protocol: "http",
+		Method:   http.MethodPost,
+		Timeout:  10 * time.Second,
+	}
+
+	ctx.Log().Info(logger.NewHTTPRequest("restock product", ""), map[string]any{
+		"uri":      httpRequest.URL,
+		"headers":  httpRequest.Headers,
+		"params":   httpRequest.Params,
+		"body":     string(body),
+		"protocol": httpRequest.Protocol,
+		"method":   httpRequest.Method,
+		"timeout":  httpRequest.Timeout,
+	})
+	req, err := http.NewRequest(httpRequest.Method, httpRequest.URL, bytes.NewReader(body))
+	if err != nil {
+		return errProductService.Wrap(err)
+	}
+	req.Header.Set("Content-Type", httpRequest.Headers["Content-Type"])
+	if err := servicetoken.Authorize(req, servicetoken.ProductService); err != nil {
+		return errProductService.Wrap(err)
+	}
+
+	httpClient := &http.Client{Timeout: httpRequest.Timeout}
+	resp, err := httpClient.Do(req)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to restock product"
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("http restock product", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return errProductService.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		summary.Code = fmt.Sprintf("%d", resp.StatusCode)
+		summary.Description = resp.Status
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("restock product failed", ""), map[string]string{
+			"error": fmt.Sprintf("failed to restock product: %s", resp.Status),
+		})
+		return errProductService.Wrap(fmt.Errorf("failed to restock product: %s", resp.Status))
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewHTTPResponse("restock product success", ""), map[string]any{
+		"Status": resp.Status,
+	})
+	return nil
+}
@@ -0,0 +1,73 @@
+package returns
+
+import "time"
+
+// The statuses of a return. requested → approved → received → refunded, or
+// requested → rejected.
+const (
+	StatusRequested = "requested" // The customer asked to send items back
+	StatusApproved  = "approved"  // The customer may send the items
+	StatusRejected  = "rejected"  // The return was refused; final
+	StatusReceived  = "received"  // The items are back and being restocked
+	StatusRefunded  = "refunded"  // The items are restocked and paid back; final
+)
+
+// Return is a request to send back part of a delivered order, also known
+// as an RMA. Audit records every step it went through.
+type Return struct {
+	ID           string  `json:"id" bson:"_id"`
+	Href         string  `json:"href,omitempty" bson:"-"`
+	OrderID      string  `json:"order_id" bson:"order_id"`
+	CustomerID   string  `json:"customer_id" bson:"customer_id"`
+	Items        []Item  `json:"items" bson:"items"`
+	Reason       string  `json:"reason,omitempty" bson:"reason,omitempty"`
+	Status       string  `json:"status" bson:"status"`
+	RefundAmount float64 `json:"refund_amount" bson:"refund_amount"`
+	Currency     string  `json:"currency,omitempty" bson:"currency,omitempty"`
+	PaymentID    string  `json:"payment_id,omitempty" bson:"payment_id,omitempty"`
+	// RefundStartedAt is set before the provider is asked for the refund
+	// and RefundedAt once it has given it, see service.refund.
+	RefundStartedAt *time.Time   `json:"refund_started_at,omitempty" bson:"refund_started_at,omitempty"`
+	RefundedAt      *time.Time   `json:"refunded_at,omitempty" bson:"refunded_at,omitempty"`
+	Audit           []AuditEntry `json:"audit" bson:"audit"`
+	CreatedAt       time.Time    `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at" bson:"updated_at"`
+}
+
+// Item is a quantity of one order item sent back. Amount is what it is
+// refunded: its share of what the customer paid for the line, discount
+// and tax included.
+type Item struct {
+	ID        string  `json:"id" bson:"id"`
+	Quantity  int     `json:"quantity" bson:"quantity"`
+	Reason    string  `json:"reason,omitempty" bson:"reason,omitempty"`
+	Amount    float64 `json:"amount" bson:"amount"`
+	Restocked bool    `json:"restocked" bson:"restocked"`
+}
+
+// AuditEntry is one step of a return.
+type AuditEntry struct {
+	Action string    `json:"action" bson:"action"`
+	Status string    `json:"status" bson:"status"`
+	Note   string    `json:"note,omitempty" bson:"note,omitempty"`
+	At     time.Time `json:"at" bson:"at"`
+}
+
+// CreateRequest is the body of POST /orders/{id}/returns.
+type CreateRequest struct {
+	Items  []ItemRequest `json:"items" validate:"required,min=1,dive"`
+	Reason string        `json:"reason,omitempty" validate:"max=512"`
+}
+
+// ItemRequest is an order item and how many of it go back.
+type ItemRequest struct {
+	ID       string `json:"id" validate:"required"`
+	Quantity int    `json:"quantity" validate:"gt=0"`
+	Reason   string `json:"reason,omitempty" validate:"max=256"`
+}
+
+// ReviewRequest is the body of approve, reject and receive. Rejecting
+// requires a note, which is passed on to the customer.
+type ReviewRequest struct {
+	Note string `json:"note,omitempty" validate:"max=512"`
+}
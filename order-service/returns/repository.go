@@ -0,0 +1,272 @@
+package returns
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository stores returns in the returns collection. Every step is a
+// conditional update on the current status that appends to the audit, so
+// two people handling one return cannot both apply a step.
+type Repository interface {
+	Create(ctx *kp.Context, r Return) (Return, error)
+	FindByID(ctx *kp.Context, id string) (Return, error)
+	FindByOrder(ctx *kp.Context, orderID string) ([]Return, error)
+	Transition(ctx *kp.Context, id, from string, entry AuditEntry, set bson.M) (Return, error)
+	SetRestocked(ctx *kp.Context, id string, item int) (Return, error)
+	StartRefund(ctx *kp.Context, id, paymentID string) (Return, error)
+	SetRefunded(ctx *kp.Context, id string) (Return, error)
+	ClearRefund(ctx *kp.Context, id string) (Return, error)
+}
+
+type repository struct {
+	col *mongo.Collection
+}
+
+func NewRepository(col *mongo.Collection) Repository {
+	return &repository{
+		col: col,
+	}
+}
+
+var (
+	ErrReturnNotFound = apperror.NotFound("return_not_found", "return not found")
+	ErrStatusConflict = apperror.Conflict("return_status_conflict", "status", "the return is not in a status that allows this")
+)
+
+func (r *repository) Create(ctx *kp.Context, ret Return) (Return, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "create_return",
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.INSERT, "insert return"), map[string]any{
+		"collection": r.col.Name(),
+		"return":     ret,
+	})
+
+	result, err := r.col.InsertOne(ctx, ret)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to insert return"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.INSERT, "insert return failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Return{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.INSERT, "insert return success"), map[string]any{
+		"Return": result,
+	})
+	return ret, nil
+}
+
+func (r *repository) FindByID(ctx *kp.Context, id string) (Return, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "find_return",
+		Code:        "200",
+		Description: "success",
+	}
+	filter := bson.M{"_id": id}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find return"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	var ret Return
+	err := r.col.FindOne(ctx, filter).Decode(&ret)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			summary.Code = "404"
+			summary.Description = "return not found"
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find return failed"), map[string]string{
+				"error": err.Error(),
+			})
+			return Return{}, ErrReturnNotFound
+		}
+		summary.Code = "500"
+		summary.Description = "failed to find return"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find return failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Return{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find return success"), map[string]any{
+		"Return": ret,
+	})
+	return ret, nil
+}
+
+func (r *repository) FindByOrder(ctx *kp.Context, orderID string) ([]Return, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "find_returns_by_order",
+		Code:        "200",
+		Description: "success",
+	}
+	filter := bson.M{"order_id": orderID}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find returns by order"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	cursor, err := r.col.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to find returns"
+		summary.ResTime = time.Since(start).Milliseconds()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find returns failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	returns := []Return{}
+	err = cursor.All(ctx, &returns)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to decode returns"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find returns failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find returns success"), map[string]any{
+		"count": len(returns),
+	})
+	return returns, nil
+}
+
+// Transition moves a return in status from to the status of entry,
+// recording entry in the audit and setting the fields of set.
+func (r *repository) Transition(ctx *kp.Context, id, from string, entry AuditEntry, set bson.M) (Return, error) {
+	fields := bson.M{
+		"status":     entry.Status,
+		"updated_at": entry.At,
+	}
+	for k, v := range set {
+		fields[k] = v
+	}
+	return r.update(ctx, "transition_return",
+		bson.M{"_id": id, "status": from},
+		bson.M{"$set": fields, "$push": bson.M{"audit": entry}})
+}
+
+// SetRestocked marks the item at index item of a received return as back
+// in stock.
+func (r *repository) SetRestocked(ctx *kp.Context, id string, item int) (Return, error) {
+	return r.update(ctx, "set_return_restocked",
+		bson.M{"_id": id, "status": StatusReceived},
+		bson.M{"$set": bson.M{
+			fmt.Sprintf("items.%d.restocked", item): true,
+			"updated_at":                            time.Now().UTC(),
+		}})
+}
+
+// StartRefund marks the refund of a received return as started on the
+// payment paymentID. Only one caller can: a return whose refund was started
+// already is ErrStatusConflict.
+func (r *repository) StartRefund(ctx *kp.Context, id, paymentID string) (Return, error) {
+	return r.update(ctx, "start_return_refund",
+		bson.M{"_id": id, "status": StatusReceived, "refund_started_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"payment_id":        paymentID,
+			"refund_started_at": time.Now().UTC(),
+			"updated_at":        time.Now().UTC(),
+		}})
+}
+
+// SetRefunded records that the provider gave the refund started by
+// StartRefund.
+func (r *repository) SetRefunded(ctx *kp.Context, id string) (Return, error) {
+	return r.update(ctx, "set_return_refunded",
+		bson.M{"_id": id, "status": StatusReceived, "refund_started_at": bson.M{"$exists": true}},
+		bson.M{"$set": bson.M{
+			"refunded_at": time.Now().UTC(),
+			"updated_at":  time.Now().UTC(),
+		}})
+}
+
+// ClearRefund drops the mark of StartRefund after a refund that certainly
+// did not happen, so the next receive tries again.
+func (r *repository) ClearRefund(ctx *kp.Context, id string) (Return, error) {
+	return r.update(ctx, "clear_return_refund",
+		bson.M{"_id": id, "status": StatusReceived, "refunded_at": bson.M{"$exists": false}},
+		bson.M{
+			"$set":   bson.M{"updated_at": time.Now().UTC()},
+			"$unset": bson.M{"payment_id": "", "refund_started_at": ""},
+		})
+}
+
+// update applies update to the return matching filter and returns it
+// updated. No match is ErrReturnNotFound when the return does not exist
+// and ErrStatusConflict when it is not in the status filter accepts.
+func (r *repository) update(ctx *kp.Context, cmd string, filter bson.M, update bson.M) (Return, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, cmd), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"update":     update,
+	})
+
+	var ret Return
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.col.FindOneAndUpdate(ctx, filter, update, opts).Decode(&ret)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		var n int64
+		n, err = r.col.CountDocuments(ctx, bson.M{"_id": filter["_id"]})
+		if err == nil {
+			summary.ResTime = time.Since(start).Milliseconds()
+			fail := ErrStatusConflict
+			summary.Code = "409"
+			summary.Description = "return status conflict"
+			if n == 0 {
+				fail = ErrReturnNotFound
+				summary.Code = "404"
+				summary.Description = "return not found"
+			}
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, cmd+" failed"), map[string]string{
+				"error": fail.Message,
+			})
+			return Return{}, fail
+		}
+	}
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to update return"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, cmd+" failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Return{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, cmd+" success"), map[string]any{
+		"Return": ret,
+	})
+	return ret, nil
+}
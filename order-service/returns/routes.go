@@ -0,0 +1,29 @@
+package returns
+
+import (
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-order-service/order"
+	"github.com/sing3demons/go-order-service/payment"
+	"github.com/sing3demons/go-order-service/servicetoken"
+	"github.com/sing3demons/go-order-service/shipment"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RegisterRoutes registers the return routes. Approving, rejecting and
+// receiving a return is the shop's side of it, and receiving restocks the
+// items and refunds them, so those routes take an admin token, see
+// servicetoken.
+func RegisterRoutes(app kp.IApplication, db *mongo.Database, orders order.OrderService, shipments shipment.Service, payments payment.Service, conf *config.Config, guard *servicetoken.Guard) {
+	repo := NewRepository(db.Collection("returns"))
+	service := NewService(repo, orders, shipments, payments, Window(conf))
+	handler := NewHandler(service)
+	app.Post("/orders/{id}/returns", handler.HandleRequestReturn)
+	app.Get("/orders/{id}/returns", handler.HandleListReturns)
+	app.Get("/returns/{id}", handler.HandleGetReturn)
+
+	admin := guard.Admin()
+	app.Post("/internal/returns/{id}/approve", admin.Require(handler.HandleApprove))
+	app.Post("/internal/returns/{id}/reject", admin.Require(handler.HandleReject))
+	app.Post("/internal/returns/{id}/receive", admin.Require(handler.HandleReceive))
+}
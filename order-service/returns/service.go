@@ -0,0 +1,303 @@
+package returns
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/order"
+	"github.com/sing3demons/go-order-service/payment"
+	"github.com/sing3demons/go-order-service/pricing"
+	"github.com/sing3demons/go-order-service/shipment"
+)
+
+type Service interface {
+	RequestReturn(ctx *kp.Context, orderID string, req CreateRequest) (Return, error)
+	GetReturn(ctx *kp.Context, id string) (Return, error)
+	ListReturns(ctx *kp.Context, orderID string) ([]Return, error)
+	Approve(ctx *kp.Context, id string, req ReviewRequest) (Return, error)
+	Reject(ctx *kp.Context, id string, req ReviewRequest) (Return, error)
+	Receive(ctx *kp.Context, id string, req ReviewRequest) (Return, error)
+}
+
+type service struct {
+	repo      Repository
+	orders    order.OrderService
+	shipments shipment.Service
+	payments  payment.Service
+	window    time.Duration
+}
+
+// NewService accepts returns of orders delivered at most window ago. The
+// delivery date is that of the last shipment of the order; refunds go
+// through payments.
+func NewService(repo Repository, orders order.OrderService, shipments shipment.Service, payments payment.Service, window time.Duration) Service {
+	return &service{
+		repo:      repo,
+		orders:    orders,
+		shipments: shipments,
+		payments:  payments,
+		window:    window,
+	}
+}
+
+// Window reads RETURN_WINDOW, how long after delivery items may be
+// returned; 30 days by default.
+func Window(conf *config.Config) time.Duration {
+	window, err := time.ParseDuration(conf.GetOrDefault("RETURN_WINDOW", "720h"))
+	if err != nil || window <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return window
+}
+
+var (
+	ErrOrderNotReturnable = apperror.Conflict("order_not_returnable", "status", "only delivered orders can be returned")
+	ErrWindowClosed       = apperror.Conflict("return_window_closed", "order_id", "the return window of the order has closed")
+	ErrNoPayment          = apperror.Conflict("no_refundable_payment", "order_id", "the order has no captured payment to refund")
+	ErrRefundStarted      = apperror.Conflict("refund_started", "status", "a refund of the return was started and has not completed; check the payment before settling it")
+	errNoteRequired       = apperror.Invalid(apperror.FieldError{Field: "note", Rule: "required", Message: "is required to reject a return"})
+)
+
+// RequestReturn opens a return for items of a delivered order, within the
+// return window. Items already on a return that was not rejected cannot be
+// returned again.
+func (s *service) RequestReturn(ctx *kp.Context, orderID string, req CreateRequest) (Return, error) {
+	o, err := s.orders.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return Return{}, err
+	}
+	if o.Status != "delivered" {
+		return Return{}, ErrOrderNotReturnable
+	}
+	shipments, err := s.shipments.ListShipments(ctx, orderID)
+	if err != nil {
+		return Return{}, err
+	}
+	var deliveredAt time.Time
+	for _, sh := range shipments {
+		if sh.DeliveredAt != nil && sh.DeliveredAt.After(deliveredAt) {
+			deliveredAt = *sh.DeliveredAt
+		}
+	}
+	if deliveredAt.IsZero() || time.Since(deliveredAt) > s.window {
+		return Return{}, ErrWindowClosed
+	}
+	existing, err := s.repo.FindByOrder(ctx, orderID)
+	if err != nil {
+		return Return{}, err
+	}
+	items, err := returnItems(o.Items, existing, req.Items)
+	if err != nil {
+		return Return{}, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Return{}, apperror.Internal(err)
+	}
+	now := time.Now().UTC()
+	ret := Return{
+		ID:         id.String(),
+		OrderID:    orderID,
+		CustomerID: o.CustomerID,
+		Items:      items,
+		Reason:     req.Reason,
+		Status:     StatusRequested,
+		Currency:   o.Currency,
+		Audit:      []AuditEntry{{Action: "request", Status: StatusRequested, Note: req.Reason, At: now}},
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	for _, item := range items {
+		ret.RefundAmount += item.Amount
+	}
+	ret.RefundAmount = pricing.Round(ret.RefundAmount)
+
+	if ret, err = s.repo.Create(ctx, ret); err != nil {
+		return Return{}, err
+	}
+	announce(ctx, returnRequestedTopic, ret)
+	return ret, nil
+}
+
+// returnItems checks the requested items against what is left to return
+// of the order and prices them at what was paid per unit.
+func returnItems(ordered []order.Item, existing []Return, requested []ItemRequest) ([]Item, error) {
+	left := map[string]int{}
+	paid := map[string]float64{}
+	units := map[string]int{}
+	for _, item := range ordered {
+		left[item.ID] += item.Quantity
+		units[item.ID] += item.Quantity
+		total := item.Total
+		if total == 0 {
+			total = item.Price * float64(item.Quantity)
+		}
+		paid[item.ID] += total
+	}
+	for _, ret := range existing {
+		if ret.Status == StatusRejected {
+			continue
+		}
+		for _, item := range ret.Items {
+			left[item.ID] -= item.Quantity
+		}
+	}
+
+	items := make([]Item, 0, len(requested))
+	var fields []apperror.FieldError
+	for i, item := range requested {
+		quantity, ok := left[item.ID]
+		switch {
+		case !ok:
+			fields = append(fields, apperror.FieldError{Field: fmt.Sprintf("items[%d].id", i), Rule: "order_item", Message: "must be an item of the order"})
+		case item.Quantity > quantity:
+			fields = append(fields, apperror.FieldError{Field: fmt.Sprintf("items[%d].quantity", i), Rule: "lte", Message: fmt.Sprintf("must not exceed the %d left to return", max(quantity, 0))})
+		default:
+			left[item.ID] -= item.Quantity
+			items = append(items, Item{
+				ID:       item.ID,
+				Quantity: item.Quantity,
+				Reason:   item.Reason,
+				Amount:   pricing.Round(paid[item.ID] / float64(units[item.ID]) * float64(item.Quantity)),
+			})
+		}
+	}
+	if len(fields) > 0 {
+		return nil, apperror.Invalid(fields...)
+	}
+	return items, nil
+}
+
+func (s *service) GetReturn(ctx *kp.Context, id string) (Return, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+func (s *service) ListReturns(ctx *kp.Context, orderID string) ([]Return, error) {
+	if _, err := s.orders.GetOrderByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByOrder(ctx, orderID)
+}
+
+// Approve lets the customer send the items back.
+func (s *service) Approve(ctx *kp.Context, id string, req ReviewRequest) (Return, error) {
+	return s.step(ctx, id, StatusRequested, AuditEntry{Action: "approve", Status: StatusApproved, Note: req.Note}, returnApprovedTopic)
+}
+
+// Reject refuses the return, giving the customer the reason in the note.
+func (s *service) Reject(ctx *kp.Context, id string, req ReviewRequest) (Return, error) {
+	if req.Note == "" {
+		return Return{}, errNoteRequired
+	}
+	return s.step(ctx, id, StatusRequested, AuditEntry{Action: "reject", Status: StatusRejected, Note: req.Note}, returnRejectedTopic)
+}
+
+// Receive records the items of an approved return as back, restocks them
+// and refunds what was paid for them. When restocking or the refund fails
+// the return stays received, and receiving it again picks up where it
+// stopped: items restocked already are not restocked twice, and a refund
+// given already is not given again.
+func (s *service) Receive(ctx *kp.Context, id string, req ReviewRequest) (Return, error) {
+	ret, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Return{}, err
+	}
+	switch ret.Status {
+	case StatusApproved:
+		ret, err = s.step(ctx, id, StatusApproved, AuditEntry{Action: "receive", Status: StatusReceived, Note: req.Note}, returnReceivedTopic)
+		if err != nil {
+			return Return{}, err
+		}
+	case StatusReceived:
+	default:
+		return Return{}, ErrStatusConflict
+	}
+
+	for i, item := range ret.Items {
+		if item.Restocked {
+			continue
+		}
+		if err := restock(ctx, item.ID, item.Quantity, "return:"+ret.ID+":"+strconv.Itoa(i)); err != nil {
+			return Return{}, err
+		}
+		if ret, err = s.repo.SetRestocked(ctx, ret.ID, i); err != nil {
+			return Return{}, err
+		}
+	}
+
+	if ret, err = s.refund(ctx, ret); err != nil {
+		return Return{}, err
+	}
+	note := fmt.Sprintf("refunded %.2f %s", ret.RefundAmount, ret.Currency)
+	return s.step(ctx, id, StatusReceived, AuditEntry{Action: "refund", Status: StatusRefunded, Note: note}, returnRefundedTopic)
+}
+
+// refund pays the refund amount of ret back on the captured payment of its
+// order. The provider only checks what is left on the payment, so the
+// refund is marked started on the return first, by one caller only: two
+// receives of one return, or a receive retried after the refund was given
+// but not recorded, cannot refund twice. A refund the provider may or may
+// not have given stays marked, and the return is left for someone to
+// check the payment: receiving it again is ErrRefundStarted.
+func (s *service) refund(ctx *kp.Context, ret Return) (Return, error) {
+	if ret.RefundAmount == 0 || ret.RefundedAt != nil {
+		return ret, nil
+	}
+	if ret.RefundStartedAt != nil {
+		return Return{}, ErrRefundStarted
+	}
+	intents, err := s.payments.ListIntents(ctx, ret.OrderID)
+	if err != nil {
+		return Return{}, err
+	}
+	for _, intent := range intents {
+		if intent.Status != payment.StatusCaptured {
+			continue
+		}
+		started, err := s.repo.StartRefund(ctx, ret.ID, intent.ID)
+		if errors.Is(err, ErrStatusConflict) {
+			return Return{}, ErrRefundStarted
+		}
+		if err != nil {
+			return Return{}, err
+		}
+		if _, err := s.payments.Refund(ctx, intent.ID, payment.AmountRequest{Amount: ret.RefundAmount}); err != nil {
+			switch apperror.From(err).Kind {
+			case apperror.KindUpstream, apperror.KindInternal:
+				// The refund may have been given.
+			default:
+				_, _ = s.repo.ClearRefund(ctx, started.ID)
+			}
+			return Return{}, err
+		}
+		return s.repo.SetRefunded(ctx, started.ID)
+	}
+	return Return{}, ErrNoPayment
+}
+
+// step moves the return from status from to the status of entry and
+// publishes topic.
+func (s *service) step(ctx *kp.Context, id, from string, entry AuditEntry, topic string) (Return, error) {
+	entry.At = time.Now().UTC()
+	ret, err := s.repo.Transition(ctx, id, from, entry, nil)
+	if err != nil {
+		return Return{}, err
+	}
+	announce(ctx, topic, ret)
+	return ret, nil
+}
+
+// announce publishes a return event. The return is already stored, so a
+// failed publish is not the caller's error: a retry would only find the
+// step taken, see ErrStatusConflict. publishEvent has logged the message
+// with the failure.
+func announce(ctx *kp.Context, topic string, ret Return) {
+	_ = publishEvent(ctx, topic, ret)
+}
@@ -0,0 +1,79 @@
+package servicetoken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "shared-secret"
+	now := time.Unix(1700000000, 0)
+	trusted := []string{"user-service", "product-service"}
+	issue := func(secret, issuer, audience string, ttl time.Duration, at time.Time) string {
+		token, err := Issue(secret, issuer, audience, ttl, at)
+		if err != nil {
+			t.Fatalf("Issue: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		noSecret bool
+		want     error
+	}{
+		{name: "valid", header: issue(secret, "product-service", "order-service", time.Minute, now), want: nil},
+		{name: "expired within leeway", header: issue(secret, "product-service", "order-service", time.Minute, now.Add(-80*time.Second)), want: nil},
+		{name: "expired", header: issue(secret, "product-service", "order-service", time.Minute, now.Add(-2*time.Minute)), want: errTokenExpired},
+		{name: "issued in the future", header: issue(secret, "product-service", "order-service", time.Minute, now.Add(time.Minute)), want: errTokenExpired},
+		{name: "wrong audience", header: issue(secret, "product-service", "user-service", time.Minute, now), want: errTokenAudience},
+		{name: "untrusted issuer", header: issue(secret, "cart-service", "order-service", time.Minute, now), want: errTokenIssuer},
+		{name: "other secret", header: issue("other-secret", "product-service", "order-service", time.Minute, now), want: errTokenBad},
+		{name: "no secret configured", header: issue(secret, "product-service", "order-service", time.Minute, now), noSecret: true, want: errTokenBad},
+		{name: "empty header", header: "", want: errTokenMissing},
+		{name: "not bearer", header: "Basic dXNlcjpwYXNz", want: errTokenMissing},
+		{name: "no signature", header: "Bearer eyJpc3MiOiJ4In0", want: errTokenFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := secret
+			if tt.noSecret {
+				key = ""
+			}
+			claims, err := Verify(key, tt.header, "order-service", trusted, now)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Verify = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && (claims.Issuer != "product-service" || claims.Audience != "order-service") {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestVerifyTamperedClaims(t *testing.T) {
+	const secret = "shared-secret"
+	now := time.Unix(1700000000, 0)
+	token, err := Issue(secret, "product-service", "user-service", time.Minute, now)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	forged, err := Issue(secret, "product-service", "order-service", time.Minute, now)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	// The claims of one token under the signature of another.
+	header := forged[:strings.LastIndex(forged, ".")] + token[strings.LastIndex(token, "."):]
+	if _, err := Verify(secret, header, "order-service", []string{"product-service"}, now); !errors.Is(err, errTokenBad) {
+		t.Errorf("Verify = %v, want %v", err, errTokenBad)
+	}
+}
+
+func TestIssueWithoutSecret(t *testing.T) {
+	if _, err := Issue("", "order-service", "user-service", time.Minute, time.Now()); err == nil {
+		t.Error("Issue without a secret succeeded")
+	}
+}
@@ -0,0 +1,73 @@
+package shipment
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+)
+
+// The shipment topics. Each message is
+// {"event_id": ..., "occurred_at": ..., "body": Shipment}, the shipment as
+// it is after the change.
+const (
+	shipmentCreatedTopic = "shipment_created"
+	shipmentUpdatedTopic = "shipment_updated"
+)
+
+// Topics lists the shipment topics.
+var Topics = []string{shipmentCreatedTopic, shipmentUpdatedTopic}
+
+// EventMessage is a shipment event as published to Kafka.
+type EventMessage struct {
+	EventID    string    `json:"event_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Body       Shipment  `json:"body"`
+}
+
+var errPublishEvent = apperror.Upstream("event_publish_failed", "the shipment event could not be published", nil)
+
+func publishEvent(ctx *kp.Context, topic string, body Shipment) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "kafka",
+		Command:     topic,
+		Code:        "200",
+		Description: "success",
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	body.Href = ""
+	message, err := json.Marshal(EventMessage{
+		EventID:    id.String(),
+		OccurredAt: time.Now().UTC(),
+		Body:       body,
+	})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	ctx.Log().Info(logger.NewProducing(topic, ""), map[string]any{
+		"topic": topic,
+		"value": string(message),
+	})
+	if err := ctx.Publish(ctx, topic, message); err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to publish " + topic
+		summary.ResTime = time.Since(start).Milliseconds()
+		ctx.Log().SetSummary(summary).Error(logger.NewProduced(topic, ""), map[string]string{
+			"error": err.Error(),
+		})
+		return errPublishEvent.Wrap(err)
+	}
+	summary.ResTime = time.Since(start).Milliseconds()
+	ctx.Log().SetSummary(summary).Info(logger.NewProduced(topic, ""), map[string]any{
+		"topic": topic,
+	})
+	return nil
+}
@@ -0,0 +1,186 @@
+package shipment
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/validation"
+	"github.com/sing3demons/go-order-service/webhook"
+)
+
+type Handler struct {
+	service   Service
+	secret    string
+	tolerance time.Duration
+}
+
+// NewHandler verifies carrier webhooks with secret, accepting signatures
+// made up to tolerance away from now.
+func NewHandler(service Service, secret string, tolerance time.Duration) *Handler {
+	return &Handler{
+		service:   service,
+		secret:    secret,
+		tolerance: tolerance,
+	}
+}
+
+var errSignature = apperror.Unauthorized("invalid_signature", "the webhook signature is missing or wrong")
+
+// HandleCreateShipment records a shipment of an order
+func (h *Handler) HandleCreateShipment(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "create_shipment",
+		Code:        "200",
+		Description: "",
+	}
+	orderID := ctx.PathParam("id")
+	if err := validation.Var("id", orderID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var req CreateRequest
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("create shipment", ""), map[string]any{
+		"order_id": orderID,
+		"body":     req,
+	})
+
+	shipment, err := h.service.CreateShipment(ctx, orderID, req)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(201, h.withHref(ctx, shipment))
+}
+
+// HandleListShipments lists the shipments of an order
+func (h *Handler) HandleListShipments(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "list_shipments",
+		Code:        "200",
+		Description: "",
+	}
+	orderID := ctx.PathParam("id")
+	if err := validation.Var("id", orderID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("list shipments", ""), map[string]any{
+		"order_id": orderID,
+	})
+
+	shipments, err := h.service.ListShipments(ctx, orderID)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	for i := range shipments {
+		shipments[i] = h.withHref(ctx, shipments[i])
+	}
+	return ctx.JSON(200, shipments)
+}
+
+// HandleGetShipment returns one shipment with its tracking events
+func (h *Handler) HandleGetShipment(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "get_shipment",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("get shipment", ""), map[string]any{
+		"id": id,
+	})
+
+	shipment, err := h.service.GetShipment(ctx, id)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, h.withHref(ctx, shipment))
+}
+
+// HandleUpdateShipment corrects the tracking details or records a status
+func (h *Handler) HandleUpdateShipment(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "update_shipment",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var req UpdateRequest
+	if err := validation.Bind(ctx, &req); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("update shipment", ""), map[string]any{
+		"id":   id,
+		"body": req,
+	})
+
+	shipment, err := h.service.UpdateShipment(ctx, id, req)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, h.withHref(ctx, shipment))
+}
+
+// HandleWebhook applies a signed tracking update of a carrier
+func (h *Handler) HandleWebhook(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "carrier",
+		Command:     "shipment_webhook",
+		Code:        "200",
+		Description: "",
+	}
+	r, ok := ctx.Request.(interface {
+		Header(string) string
+		Body() (string, error)
+	})
+	if !ok {
+		return apperror.Write(ctx, errSignature)
+	}
+	body, err := r.Body()
+	if err != nil {
+		return validation.Respond(ctx, summary, apperror.Invalid(apperror.FieldError{Field: "body", Rule: "format", Message: "must be a valid request body"}))
+	}
+	if err := webhook.Verify(h.secret, r.Header(SignatureHeader), []byte(body), h.tolerance, time.Now()); err != nil {
+		summary.Code = "401"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewInbound("shipment webhook", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return apperror.Write(ctx, errSignature)
+	}
+
+	var event TrackingWebhook
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return validation.Respond(ctx, summary, apperror.Invalid(apperror.FieldError{Field: "body", Rule: "format", Message: "must be a valid request body"}))
+	}
+	if err := validation.Struct(event); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("shipment webhook", ""), map[string]any{
+		"body": event,
+	})
+
+	shipment, err := h.service.HandleWebhook(ctx, event)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(200, h.withHref(ctx, shipment))
+}
+
+func (h *Handler) withHref(ctx *kp.Context, shipment Shipment) Shipment {
+	shipment.Href = ctx.HostName() + "/shipments/" + shipment.ID
+	return shipment
+}
@@ -0,0 +1,84 @@
+package shipment
+
+import "time"
+
+// The statuses of a shipment. A shipment only moves forward, in this order;
+// delivered and failed are final.
+const (
+	StatusInTransit      = "in_transit"       // The carrier has the parcel
+	StatusOutForDelivery = "out_for_delivery" // The parcel is on the last leg
+	StatusDelivered      = "delivered"        // The customer has the parcel
+	StatusFailed         = "failed"           // The parcel was lost or sent back
+)
+
+// progress ranks the statuses so an update reported late, e.g. in_transit
+// after out_for_delivery, cannot move a shipment back.
+var progress = map[string]int{
+	StatusInTransit:      1,
+	StatusOutForDelivery: 2,
+	StatusDelivered:      3,
+	StatusFailed:         3,
+}
+
+// before lists the statuses a shipment may move to status from.
+func before(status string) []string {
+	from := []string{}
+	for s, rank := range progress {
+		if rank < progress[status] {
+			from = append(from, s)
+		}
+	}
+	return from
+}
+
+// Shipment is one parcel of an order. An order may ship in several, each
+// with part of the items; the items of a failed shipment may ship again.
+type Shipment struct {
+	ID             string          `json:"id" bson:"_id"`
+	Href           string          `json:"href,omitempty" bson:"-"`
+	OrderID        string          `json:"order_id" bson:"order_id"`
+	Carrier        string          `json:"carrier" bson:"carrier"`
+	TrackingNumber string          `json:"tracking_number" bson:"tracking_number"`
+	Items          []Item          `json:"items" bson:"items"`
+	Status         string          `json:"status" bson:"status"`
+	Events         []TrackingEvent `json:"events" bson:"events"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" bson:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" bson:"updated_at"`
+}
+
+// Item is a quantity of one order item in a shipment.
+type Item struct {
+	ID       string `json:"id" bson:"id" validate:"required"`
+	Quantity int    `json:"quantity" bson:"quantity" validate:"gt=0"`
+}
+
+// TrackingEvent is one scan or status report of the carrier. The id is the
+// carrier's, so a report sent twice is recorded once.
+type TrackingEvent struct {
+	ID          string    `json:"id" bson:"id"`
+	Status      string    `json:"status" bson:"status"`
+	Location    string    `json:"location,omitempty" bson:"location,omitempty"`
+	Description string    `json:"description,omitempty" bson:"description,omitempty"`
+	OccurredAt  time.Time `json:"occurred_at" bson:"occurred_at"`
+}
+
+// CreateRequest is the body of POST /internal/orders/{id}/shipments.
+// Without items the shipment holds everything not shipped yet.
+type CreateRequest struct {
+	Carrier        string `json:"carrier" validate:"required,max=64"`
+	TrackingNumber string `json:"tracking_number" validate:"required,max=64"`
+	Items          []Item `json:"items,omitempty" validate:"omitempty,dive"`
+}
+
+// UpdateRequest is the body of PUT /internal/shipments/{id}. Carrier and
+// tracking number correct a mistyped label; a status is recorded as a
+// tracking event, at occurred_at or now.
+type UpdateRequest struct {
+	Carrier        string    `json:"carrier,omitempty" validate:"omitempty,max=64"`
+	TrackingNumber string    `json:"tracking_number,omitempty" validate:"omitempty,max=64"`
+	Status         string    `json:"status,omitempty" validate:"omitempty,oneof=in_transit out_for_delivery delivered failed"`
+	Location       string    `json:"location,omitempty" validate:"max=128"`
+	Description    string    `json:"description,omitempty" validate:"max=256"`
+	OccurredAt     time.Time `json:"occurred_at,omitempty"`
+}
@@ -0,0 +1,279 @@
+package shipment
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository stores shipments in the shipments collection. Status changes
+// are conditional updates on the current status, so tracking updates
+// arriving together or out of order never move a shipment back.
+type Repository interface {
+	Create(ctx *kp.Context, shipment Shipment) (Shipment, error)
+	FindByID(ctx *kp.Context, id string) (Shipment, error)
+	FindByTracking(ctx *kp.Context, carrier, trackingNumber string) (Shipment, error)
+	FindByOrder(ctx *kp.Context, orderID string) ([]Shipment, error)
+	SetTracking(ctx *kp.Context, id, carrier, trackingNumber string) (Shipment, error)
+	AddEvent(ctx *kp.Context, id string, event TrackingEvent) (Shipment, error)
+	Advance(ctx *kp.Context, id, status string, at time.Time) (Shipment, error)
+}
+
+type repository struct {
+	col *mongo.Collection
+}
+
+func NewRepository(col *mongo.Collection) Repository {
+	return &repository{
+		col: col,
+	}
+}
+
+var (
+	ErrShipmentNotFound = apperror.NotFound("shipment_not_found", "shipment not found")
+	ErrStatusConflict   = apperror.Conflict("shipment_status_conflict", "status", "the shipment is not in a status that allows this")
+	ErrTrackingConflict = apperror.Conflict("tracking_number_conflict", "tracking_number", "the carrier already has a shipment with this tracking number")
+	ErrDuplicateEvent   = apperror.Conflict("tracking_event_duplicate", "id", "the tracking event was already recorded")
+)
+
+func (r *repository) Create(ctx *kp.Context, shipment Shipment) (Shipment, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "create_shipment",
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.INSERT, "insert shipment"), map[string]any{
+		"collection": r.col.Name(),
+		"shipment":   shipment,
+	})
+
+	result, err := r.col.InsertOne(ctx, shipment)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			summary.Code = "409"
+			summary.Description = "tracking number already used"
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.INSERT, "insert shipment failed"), map[string]string{
+				"error": err.Error(),
+			})
+			return Shipment{}, ErrTrackingConflict
+		}
+		summary.Code = "500"
+		summary.Description = "failed to insert shipment"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.INSERT, "insert shipment failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Shipment{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.INSERT, "insert shipment success"), map[string]any{
+		"Return": result,
+	})
+	return shipment, nil
+}
+
+func (r *repository) FindByID(ctx *kp.Context, id string) (Shipment, error) {
+	return r.findOne(ctx, "find_shipment", bson.M{"_id": id})
+}
+
+func (r *repository) FindByTracking(ctx *kp.Context, carrier, trackingNumber string) (Shipment, error) {
+	return r.findOne(ctx, "find_shipment_by_tracking", bson.M{"carrier": carrier, "tracking_number": trackingNumber})
+}
+
+func (r *repository) findOne(ctx *kp.Context, cmd string, filter bson.M) (Shipment, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, cmd), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	var shipment Shipment
+	err := r.col.FindOne(ctx, filter).Decode(&shipment)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			summary.Code = "404"
+			summary.Description = "shipment not found"
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, cmd+" failed"), map[string]string{
+				"error": err.Error(),
+			})
+			return Shipment{}, ErrShipmentNotFound
+		}
+		summary.Code = "500"
+		summary.Description = "failed to find shipment"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, cmd+" failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Shipment{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, cmd+" success"), map[string]any{
+		"Return": shipment,
+	})
+	return shipment, nil
+}
+
+func (r *repository) FindByOrder(ctx *kp.Context, orderID string) ([]Shipment, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     "find_shipments_by_order",
+		Code:        "200",
+		Description: "success",
+	}
+	filter := bson.M{"order_id": orderID}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find shipments by order"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	cursor, err := r.col.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to find shipments"
+		summary.ResTime = time.Since(start).Milliseconds()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find shipments failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	shipments := []Shipment{}
+	err = cursor.All(ctx, &shipments)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to decode shipments"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find shipments failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find shipments success"), map[string]any{
+		"count": len(shipments),
+	})
+	return shipments, nil
+}
+
+// SetTracking corrects the carrier and tracking number of a shipment that
+// has not arrived or failed yet.
+func (r *repository) SetTracking(ctx *kp.Context, id, carrier, trackingNumber string) (Shipment, error) {
+	return r.transition(ctx, "set_shipment_tracking",
+		bson.M{"_id": id, "status": bson.M{"$in": bson.A{StatusInTransit, StatusOutForDelivery}}},
+		bson.M{"$set": bson.M{
+			"carrier":         carrier,
+			"tracking_number": trackingNumber,
+			"updated_at":      time.Now().UTC(),
+		}})
+}
+
+// AddEvent records a tracking event unless one with the same id already
+// was, which is ErrDuplicateEvent. It does not change the status.
+func (r *repository) AddEvent(ctx *kp.Context, id string, event TrackingEvent) (Shipment, error) {
+	shipment, err := r.transition(ctx, "add_tracking_event",
+		bson.M{"_id": id, "events.id": bson.M{"$ne": event.ID}},
+		bson.M{
+			"$push": bson.M{"events": bson.M{
+				"$each": bson.A{event},
+				"$sort": bson.M{"occurred_at": 1},
+			}},
+			"$set": bson.M{"updated_at": time.Now().UTC()},
+		})
+	if errors.Is(err, ErrStatusConflict) {
+		return Shipment{}, ErrDuplicateEvent
+	}
+	return shipment, err
+}
+
+// Advance moves the shipment to status, at the time it was reported, if it
+// is in a status before it.
+func (r *repository) Advance(ctx *kp.Context, id, status string, at time.Time) (Shipment, error) {
+	set := bson.M{
+		"status":     status,
+		"updated_at": time.Now().UTC(),
+	}
+	if status == StatusDelivered {
+		set["delivered_at"] = at
+	}
+	return r.transition(ctx, "advance_shipment",
+		bson.M{"_id": id, "status": bson.M{"$in": before(status)}},
+		bson.M{"$set": set})
+}
+
+// transition applies update to the shipment matching filter and returns it
+// updated. No match is ErrShipmentNotFound when the shipment does not exist
+// and ErrStatusConflict when filter does not accept it.
+func (r *repository) transition(ctx *kp.Context, cmd string, filter bson.M, update any) (Shipment, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, cmd), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"update":     update,
+	})
+
+	var shipment Shipment
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.col.FindOneAndUpdate(ctx, filter, update, opts).Decode(&shipment)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		var n int64
+		n, err = r.col.CountDocuments(ctx, bson.M{"_id": filter["_id"]})
+		if err == nil {
+			summary.ResTime = time.Since(start).Milliseconds()
+			fail := ErrStatusConflict
+			summary.Code = "409"
+			summary.Description = "shipment status conflict"
+			if n == 0 {
+				fail = ErrShipmentNotFound
+				summary.Code = "404"
+				summary.Description = "shipment not found"
+			}
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, cmd+" failed"), map[string]string{
+				"error": fail.Message,
+			})
+			return Shipment{}, fail
+		}
+	}
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			summary.Code = "409"
+			summary.Description = "tracking number already used"
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, cmd+" failed"), map[string]string{
+				"error": err.Error(),
+			})
+			return Shipment{}, ErrTrackingConflict
+		}
+		summary.Code = "500"
+		summary.Description = "failed to update shipment"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, cmd+" failed"), map[string]string{
+			"error": err.Error(),
+		})
+		return Shipment{}, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, cmd+" success"), map[string]any{
+		"Return": shipment,
+	})
+	return shipment, nil
+}
@@ -0,0 +1,35 @@
+package shipment
+
+import (
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-order-service/order"
+	"github.com/sing3demons/go-order-service/servicetoken"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RegisterRoutes registers the shipment routes and returns the service,
+// which knows when an order was delivered. Creating and updating a
+// shipment moves its order to shipped and delivered, so outside the
+// signed carrier webhook those routes take an admin token, see
+// servicetoken.
+func RegisterRoutes(app kp.IApplication, db *mongo.Database, orders order.OrderService, conf *config.Config, guard *servicetoken.Guard) Service {
+	tolerance, err := time.ParseDuration(conf.GetOrDefault("SHIPMENT_WEBHOOK_TOLERANCE", "5m"))
+	if err != nil || tolerance <= 0 {
+		tolerance = 5 * time.Minute
+	}
+
+	repo := NewRepository(db.Collection("shipments"))
+	service := NewService(repo, orders)
+	handler := NewHandler(service, conf.Get("SHIPMENT_WEBHOOK_SECRET"), tolerance)
+	app.Get("/orders/{id}/shipments", handler.HandleListShipments)
+	app.Post("/shipments/webhook", handler.HandleWebhook)
+	app.Get("/shipments/{id}", handler.HandleGetShipment)
+
+	admin := guard.Admin()
+	app.Post("/internal/orders/{id}/shipments", admin.Require(handler.HandleCreateShipment))
+	app.Put("/internal/shipments/{id}", admin.Require(handler.HandleUpdateShipment))
+	return service
+}
@@ -0,0 +1,290 @@
+package shipment
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-order-service/apperror"
+	"github.com/sing3demons/go-order-service/order"
+)
+
+type Service interface {
+	CreateShipment(ctx *kp.Context, orderID string, req CreateRequest) (Shipment, error)
+	GetShipment(ctx *kp.Context, id string) (Shipment, error)
+	ListShipments(ctx *kp.Context, orderID string) ([]Shipment, error)
+	UpdateShipment(ctx *kp.Context, id string, req UpdateRequest) (Shipment, error)
+	HandleWebhook(ctx *kp.Context, event TrackingWebhook) (Shipment, error)
+}
+
+type service struct {
+	repo   Repository
+	orders order.OrderService
+}
+
+// NewService records shipments and moves their orders to shipped and
+// delivered through orders.
+func NewService(repo Repository, orders order.OrderService) Service {
+	return &service{
+		repo:   repo,
+		orders: orders,
+	}
+}
+
+var (
+	ErrOrderNotShippable = apperror.Conflict("order_not_shippable", "status", "only paid orders can be shipped")
+	ErrNothingToShip     = apperror.Conflict("nothing_to_ship", "items", "every item of the order has shipped already")
+)
+
+// CreateShipment records a parcel handed to the carrier. The first one
+// moves the order to shipped.
+func (s *service) CreateShipment(ctx *kp.Context, orderID string, req CreateRequest) (Shipment, error) {
+	o, err := s.orders.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return Shipment{}, err
+	}
+	if o.Status != "paid" && o.Status != "shipped" {
+		return Shipment{}, ErrOrderNotShippable
+	}
+	shipments, err := s.repo.FindByOrder(ctx, orderID)
+	if err != nil {
+		return Shipment{}, err
+	}
+	// A shipment stored by an attempt that failed to mark the order shipped
+	// is finished first, so retrying does not leave the order paid.
+	if o.Status == "paid" {
+		for _, shipment := range shipments {
+			if shipment.Status == StatusFailed {
+				continue
+			}
+			if _, err := s.orders.MarkShipped(ctx, orderID, shipment.ID); err != nil {
+				return Shipment{}, err
+			}
+			break
+		}
+	}
+	items, err := shipmentItems(o.Items, shipments, req.Items)
+	if err != nil {
+		return Shipment{}, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Shipment{}, apperror.Internal(err)
+	}
+	eventID, err := uuid.NewV7()
+	if err != nil {
+		return Shipment{}, apperror.Internal(err)
+	}
+	now := time.Now().UTC()
+	shipment, err := s.repo.Create(ctx, Shipment{
+		ID:             id.String(),
+		OrderID:        orderID,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		Items:          items,
+		Status:         StatusInTransit,
+		Events:         []TrackingEvent{{ID: eventID.String(), Status: StatusInTransit, OccurredAt: now}},
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	})
+	if err != nil {
+		return Shipment{}, err
+	}
+	if _, err := s.orders.MarkShipped(ctx, orderID, shipment.ID); err != nil {
+		return Shipment{}, err
+	}
+	announce(ctx, shipmentCreatedTopic, shipment)
+	return shipment, nil
+}
+
+// shipmentItems checks the requested items against what is left to ship of
+// the order, the items of failed shipments included. No requested items
+// means all of it.
+func shipmentItems(ordered []order.Item, shipments []Shipment, requested []Item) ([]Item, error) {
+	left := map[string]int{}
+	for _, item := range ordered {
+		left[item.ID] += item.Quantity
+	}
+	for _, shipment := range shipments {
+		if shipment.Status == StatusFailed {
+			continue
+		}
+		for _, item := range shipment.Items {
+			left[item.ID] -= item.Quantity
+		}
+	}
+
+	if len(requested) == 0 {
+		for _, item := range ordered {
+			if left[item.ID] > 0 {
+				requested = append(requested, Item{ID: item.ID, Quantity: left[item.ID]})
+				left[item.ID] = 0
+			}
+		}
+		if len(requested) == 0 {
+			return nil, ErrNothingToShip
+		}
+		return requested, nil
+	}
+
+	var fields []apperror.FieldError
+	for i, item := range requested {
+		quantity, ok := left[item.ID]
+		switch {
+		case !ok:
+			fields = append(fields, apperror.FieldError{Field: fmt.Sprintf("items[%d].id", i), Rule: "order_item", Message: "must be an item of the order"})
+		case item.Quantity > quantity:
+			fields = append(fields, apperror.FieldError{Field: fmt.Sprintf("items[%d].quantity", i), Rule: "lte", Message: fmt.Sprintf("must not exceed the %d left to ship", max(quantity, 0))})
+		default:
+			left[item.ID] -= item.Quantity
+		}
+	}
+	if len(fields) > 0 {
+		return nil, apperror.Invalid(fields...)
+	}
+	return requested, nil
+}
+
+func (s *service) GetShipment(ctx *kp.Context, id string) (Shipment, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+func (s *service) ListShipments(ctx *kp.Context, orderID string) ([]Shipment, error) {
+	if _, err := s.orders.GetOrderByID(ctx, orderID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByOrder(ctx, orderID)
+}
+
+// UpdateShipment corrects the tracking details and records a status
+// reported outside the carrier webhook.
+func (s *service) UpdateShipment(ctx *kp.Context, id string, req UpdateRequest) (Shipment, error) {
+	shipment, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Shipment{}, err
+	}
+	if req.Carrier != "" || req.TrackingNumber != "" {
+		carrier, trackingNumber := shipment.Carrier, shipment.TrackingNumber
+		if req.Carrier != "" {
+			carrier = req.Carrier
+		}
+		if req.TrackingNumber != "" {
+			trackingNumber = req.TrackingNumber
+		}
+		if shipment, err = s.repo.SetTracking(ctx, id, carrier, trackingNumber); err != nil {
+			return Shipment{}, err
+		}
+		announce(ctx, shipmentUpdatedTopic, shipment)
+	}
+	if req.Status == "" {
+		return shipment, nil
+	}
+
+	eventID, err := uuid.NewV7()
+	if err != nil {
+		return Shipment{}, apperror.Internal(err)
+	}
+	return s.track(ctx, shipment, TrackingEvent{
+		ID:          eventID.String(),
+		Status:      req.Status,
+		Location:    req.Location,
+		Description: req.Description,
+		OccurredAt:  req.OccurredAt,
+	})
+}
+
+// HandleWebhook applies a tracking update of the carrier. Carriers retry,
+// so an update that was already applied returns the shipment unchanged.
+func (s *service) HandleWebhook(ctx *kp.Context, event TrackingWebhook) (Shipment, error) {
+	shipment, err := s.repo.FindByTracking(ctx, event.Carrier, event.TrackingNumber)
+	if err != nil {
+		return Shipment{}, err
+	}
+	return s.track(ctx, shipment, TrackingEvent{
+		ID:          event.ID,
+		Status:      event.Status,
+		Location:    event.Location,
+		Description: event.Description,
+		OccurredAt:  event.OccurredAt,
+	})
+}
+
+// track records event and moves the shipment forward to its status. A
+// repeated event is applied again past the recording, so a retry finishes
+// what a failed attempt left undone.
+func (s *service) track(ctx *kp.Context, shipment Shipment, event TrackingEvent) (Shipment, error) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now().UTC()
+	}
+	event.OccurredAt = event.OccurredAt.UTC()
+
+	recorded, err := s.repo.AddEvent(ctx, shipment.ID, event)
+	if err != nil && !errors.Is(err, ErrDuplicateEvent) {
+		return Shipment{}, err
+	}
+	if err == nil {
+		shipment = recorded
+	}
+
+	advanced, err := s.repo.Advance(ctx, shipment.ID, event.Status, event.OccurredAt)
+	switch {
+	case err == nil:
+		shipment = advanced
+		announce(ctx, shipmentUpdatedTopic, shipment)
+	case !errors.Is(err, ErrStatusConflict):
+		return Shipment{}, err
+	}
+
+	if shipment.Status == StatusDelivered {
+		if err := s.deliverOrder(ctx, shipment); err != nil {
+			return Shipment{}, err
+		}
+	}
+	return shipment, nil
+}
+
+// announce publishes a shipment event. The shipment is already stored, so
+// a failed publish is not the caller's error: a retry would only find the
+// change made. publishEvent has logged the message with the failure.
+func announce(ctx *kp.Context, topic string, shipment Shipment) {
+	_ = publishEvent(ctx, topic, shipment)
+}
+
+// deliverOrder moves the order of a delivered shipment to delivered once
+// every item of it has been delivered.
+func (s *service) deliverOrder(ctx *kp.Context, shipment Shipment) error {
+	o, err := s.orders.GetOrderByID(ctx, shipment.OrderID)
+	if err != nil {
+		return err
+	}
+	if o.Status != "shipped" {
+		return nil
+	}
+	shipments, err := s.repo.FindByOrder(ctx, shipment.OrderID)
+	if err != nil {
+		return err
+	}
+
+	left := map[string]int{}
+	for _, item := range o.Items {
+		left[item.ID] += item.Quantity
+	}
+	for _, shipped := range shipments {
+		if shipped.Status != StatusDelivered {
+			continue
+		}
+		for _, item := range shipped.Items {
+			left[item.ID] -= item.Quantity
+		}
+	}
+	for _, quantity := range left {
+		if quantity > 0 {
+			return nil
+		}
+	}
+	_, err = s.orders.MarkDelivered(ctx, shipment.OrderID, shipment.ID)
+	return err
+}
@@ -0,0 +1,18 @@
+package shipment
+
+import "time"
+
+// SignatureHeader carries the signature of a carrier webhook, in the format
+// of package webhook.
+const SignatureHeader = "X-Carrier-Signature"
+
+// TrackingWebhook is a tracking update pushed by a carrier.
+type TrackingWebhook struct {
+	ID             string    `json:"id" validate:"required"`
+	Carrier        string    `json:"carrier" validate:"required"`
+	TrackingNumber string    `json:"tracking_number" validate:"required"`
+	Status         string    `json:"status" validate:"required,oneof=in_transit out_for_delivery delivered failed"`
+	Location       string    `json:"location,omitempty"`
+	Description    string    `json:"description,omitempty"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
@@ -0,0 +1,164 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-order-service/apperror"
+)
+
+type FieldError = apperror.FieldError
+
+var (
+	validate = newValidator()
+	messages = map[string]string{}
+)
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+	return v
+}
+
+// Register adds a custom rule for string fields. It must be called before
+// the rule is used, typically from an init function.
+func Register(tag, message string, fn func(value string) bool) {
+	err := validate.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+		return fn(fl.Field().String())
+	})
+	if err != nil {
+		panic(err)
+	}
+	messages[tag] = message
+}
+
+// Struct checks v against its `validate` tags. A failure is returned as an
+// apperror validation error listing every failing field.
+func Struct(v any) error {
+	return translate(validate.Struct(v), "")
+}
+
+// Var checks a single value, such as a path parameter, against tag.
+func Var(field string, value any, tag string) error {
+	return translate(validate.Var(value, tag), field)
+}
+
+// Bind decodes the request body into v and validates it.
+func Bind(ctx *kp.Context, v any) error {
+	if err := ctx.Bind(v); err != nil {
+		return apperror.Invalid(FieldError{Field: "body", Rule: "format", Message: "must be a valid request body"})
+	}
+	return Struct(v)
+}
+
+// Respond logs the failing fields in the summary and writes the problem
+// response listing them.
+func Respond(ctx *kp.Context, summary logger.LogEventTag, err error) error {
+	e := apperror.From(err)
+
+	parts := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		parts = append(parts, fmt.Sprintf("%s (%s): %s", f.Field, f.Rule, f.Message))
+	}
+	summary.Code = strconv.Itoa(e.Status())
+	summary.Description = e.Code
+	if len(parts) > 0 {
+		summary.Description += ": " + strings.Join(parts, "; ")
+	}
+	ctx.Log().SetSummary(summary).Error(logger.NewInbound(summary.Command, "validation failed"), map[string]any{
+		"errors": e.Fields,
+	})
+	return apperror.Write(ctx, e)
+}
+
+func translate(err error, field string) error {
+	if err == nil {
+		return nil
+	}
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+
+	errs := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		name := field
+		if name == "" {
+			// drop the struct name, keep the JSON path
+			name = fe.Namespace()
+			if i := strings.Index(name, "."); i >= 0 {
+				name = name[i+1:]
+			}
+		}
+		errs = append(errs, FieldError{
+			Field:   name,
+			Rule:    fe.Tag(),
+			Message: message(fe),
+		})
+	}
+	return apperror.Invalid(errs...)
+}
+
+func message(fe validator.FieldError) string {
+	if msg, ok := messages[fe.Tag()]; ok {
+		return msg
+	}
+
+	isText := fe.Kind() == reflect.String
+	isList := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map || fe.Kind() == reflect.Array
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "uuid", "uuid4", "uuid7":
+		return "must be a valid UUID"
+	case "numeric":
+		return "must be a number"
+	case "datetime":
+		return "must be an RFC 3339 timestamp"
+	case "fqdn":
+		return "must be a domain name"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min", "gte":
+		switch {
+		case isText:
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		case isList:
+			return fmt.Sprintf("must contain at least %s items", fe.Param())
+		}
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		switch {
+		case isText:
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		case isList:
+			return fmt.Sprintf("must contain at most %s items", fe.Param())
+		}
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "len":
+		return "must have length " + fe.Param()
+	}
+	return "is invalid"
+}
@@ -0,0 +1,64 @@
+// Package webhook signs and verifies the bodies of inbound webhooks. A
+// signature header reads "t=<unix seconds>,v1=<hex HMAC-SHA256 of
+// "<t>.<body>">", the timestamp keeping a captured request from being
+// replayed later.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errSignatureFormat = errors.New("malformed signature header")
+	errSignatureStale  = errors.New("signature timestamp outside the tolerance")
+	errSignatureBad    = errors.New("signature does not match")
+)
+
+// Sign returns the signature header value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+// Verify checks header against body. The timestamp must be within
+// tolerance of now.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	if ts == "" || sig == "" {
+		return errSignatureFormat
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errSignatureFormat
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return errSignatureStale
+	}
+	if secret == "" || !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return errSignatureBad
+	}
+	return nil
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"type":"payment.captured"}`)
+	now := time.Unix(1700000000, 0)
+	tolerance := 5 * time.Minute
+
+	tests := []struct {
+		name     string
+		header   string
+		noSecret bool
+		body     []byte
+		want     error
+	}{
+		{name: "valid", header: Sign(secret, now, body), want: nil},
+		{name: "valid within tolerance", header: Sign(secret, now.Add(-4*time.Minute), body), want: nil},
+		{name: "stale", header: Sign(secret, now.Add(-6*time.Minute), body), want: errSignatureStale},
+		{name: "from the future", header: Sign(secret, now.Add(6*time.Minute), body), want: errSignatureStale},
+		{name: "other secret", header: Sign("whsec_other", now, body), want: errSignatureBad},
+		{name: "tampered body", header: Sign(secret, now, body), body: []byte(`{"type":"payment.refunded"}`), want: errSignatureBad},
+		{name: "no secret configured", header: Sign(secret, now, body), noSecret: true, want: errSignatureBad},
+		{name: "empty header", header: "", want: errSignatureFormat},
+		{name: "no signature", header: "t=1700000000", want: errSignatureFormat},
+		{name: "bad timestamp", header: "t=soon,v1=abcd", want: errSignatureFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := secret
+			if tt.noSecret {
+				key = ""
+			}
+			b := body
+			if tt.body != nil {
+				b = tt.body
+			}
+			if err := Verify(key, tt.header, b, tolerance, now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -9,7 +10,12 @@ import (
 	_ "github.com/lib/pq" // PostgreSQL driver
 	config "github.com/sing3demons/go-common-kp/kp/configs"
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-product-service/media"
+	"github.com/sing3demons/go-product-service/migration"
+	"github.com/sing3demons/go-product-service/outbox"
 	"github.com/sing3demons/go-product-service/product"
+	"github.com/sing3demons/go-product-service/review"
+	"github.com/sing3demons/go-product-service/servicetoken"
 )
 
 func NewDB(conf *config.Config) (*sql.DB, error) {
@@ -35,14 +41,41 @@ func main() {
 	}
 	conf.LoadEnv(path)
 
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if err := runToken(conf, os.Args[2:]); err != nil {
+			log.Fatalf("token: %v", err)
+		}
+		return
+	}
+
 	db, err := NewDB(conf)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to connect to database: %v", err))
 	}
 	defer db.Close()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	migrator, err := migration.New(db)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to load migrations: %v", err))
+	}
+	if err := migrator.Check(context.Background()); err != nil {
+		panic(fmt.Sprintf("Database schema is not up to date: %v", err))
+	}
+
 	app := kp.NewApplication(conf)
-	// app.StartKafka()
+	app.StartKafka()
+	app.CreateTopic("product_created")
+	app.CreateTopic("product_updated")
+	app.CreateTopic("product_deleted")
+	app.CreateTopic("price_changed")
+	app.CreateTopic("stock_changed")
 
 	app.Get("/healthz", func(ctx *kp.Context) error {
 		if err := db.Ping(); err != nil {
@@ -53,8 +86,33 @@ func main() {
 		return ctx.JSON(200, "UP")
 	})
 
+	storage, err := media.NewStorage(conf)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to initialize media storage: %v", err))
+	}
+	mediaSvc := media.NewService(storage, conf)
+	media.RegisterRoutes(app, mediaSvc)
+
 	// Register product routes
-	product.RegisterRoutes(app, db)
+	guard := servicetoken.NewGuard(conf)
+	product.RegisterRoutes(app, db, mediaSvc, guard)
+	review.RegisterRoutes(app, db, conf, guard)
+
+	if conf.GetOrDefault("OUTBOX_ENABLED", "true") == "true" {
+		publisher, err := outbox.NewKafkaPublisher(conf)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to initialize outbox publisher: %v", err))
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		outbox.NewRelay(db, publisher, conf).Start(ctx)
+	}
+
+	if conf.GetOrDefault("PURGE_ENABLED", "true") == "true" {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		product.NewPurger(db, storage, conf).Start(ctx)
+	}
 
 	app.Start()
 }
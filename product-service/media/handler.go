@@ -0,0 +1,61 @@
+package media
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-product-service/apperror"
+	"github.com/sing3demons/go-product-service/validation"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler {
+	return &Handler{
+		svc: svc,
+	}
+}
+
+// GetMedia streams a stored object back to the client.
+func (h *Handler) GetMedia(ctx *kp.Context) error {
+	key := ctx.PathParam("key")
+	node := "client"
+	cmd := "get_media"
+	summary := logger.EventTag(node, cmd, "200", "")
+
+	if err := validation.Var("key", key, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"Param": map[string]string{
+			"key":   "key",
+			"value": key,
+		},
+	})
+
+	body, info, err := h.svc.Open(ctx, key)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	defer body.Close()
+
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	ctx.Header().Set("Content-Type", info.ContentType)
+	ctx.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	ctx.Header().Set("Cache-Control", "public, max-age=86400")
+	ctx.Header().Set("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	ctx.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(ctx.ResponseWriter, body); err != nil {
+		ctx.Log().Error(logger.NewOutbound("client", ""), map[string]any{
+			"error": err.Error(),
+		})
+	}
+	ctx.Log().End(http.StatusOK, "")
+	return nil
+}
@@ -0,0 +1,119 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"github.com/sing3demons/go-product-service/apperror"
+	"golang.org/x/image/draw"
+)
+
+var (
+	ErrEmptyFile       = apperror.Validation("empty_file", "the uploaded file is empty")
+	ErrFileTooLarge    = apperror.TooLarge("file_too_large", "the uploaded file exceeds the size limit")
+	ErrUnsupportedType = apperror.Validation("unsupported_media_type", "only JPEG, PNG and GIF images are accepted")
+	ErrInvalidImage    = apperror.Validation("invalid_image", "the uploaded file is not a valid image")
+	ErrTooManyPixels   = apperror.TooLarge("image_too_large", "the image has more pixels than allowed")
+)
+
+// allowedContentTypes maps accepted upload types to the extension used for
+// the stored key. GIFs keep their original but get a PNG thumbnail.
+var allowedContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+var thumbnailContentTypes = map[string]string{
+	"image/jpeg": "image/jpeg",
+	"image/png":  "image/png",
+	"image/gif":  "image/png",
+}
+
+// detectContentType sniffs the payload instead of trusting the multipart
+// header so a renamed file cannot slip through.
+func detectContentType(data []byte, maxSize int64) (string, error) {
+	if len(data) == 0 {
+		return "", ErrEmptyFile
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return "", ErrFileTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := allowedContentTypes[contentType]; !ok {
+		return "", ErrUnsupportedType
+	}
+	return contentType, nil
+}
+
+// thumbnail scales img down to fit in a size x size box, keeping the aspect
+// ratio. Images that already fit are re-encoded as is.
+func thumbnail(img image.Image, contentType string, size int) ([]byte, string, error) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > size || h > size {
+		if w >= h {
+			h = h * size / w
+			w = size
+		} else {
+			w = w * size / h
+			h = size
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	thumbType := thumbnailContentTypes[contentType]
+	var buf bytes.Buffer
+	var err error
+	if thumbType == "image/jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), thumbType, nil
+}
+
+// decodeImage decodes the image once its header shows it has at most
+// maxPixels pixels. The file size limit alone does not bound the memory:
+// a small compressed file can declare a huge image.
+func decodeImage(data []byte, contentType string, maxPixels int64) (image.Image, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	var img image.Image
+	switch contentType {
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "image/gif":
+		img, err = gif.Decode(bytes.NewReader(data))
+	default:
+		return nil, ErrUnsupportedType
+	}
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	return img, nil
+}
@@ -0,0 +1,22 @@
+package media
+
+import "time"
+
+type Media struct {
+	Key          string    `json:"key"`
+	URL          string    `json:"url"`
+	ThumbnailKey string    `json:"thumbnail_key"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
@@ -0,0 +1,9 @@
+package media
+
+import "github.com/sing3demons/go-common-kp/kp/pkg/kp"
+
+func RegisterRoutes(app kp.IApplication, svc Service) {
+	handler := NewHandler(svc)
+
+	app.Get("/media/{key:.+}", handler.GetMedia)
+}
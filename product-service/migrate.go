@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/sing3demons/go-product-service/migration"
+)
+
+const migrateUsage = `usage: product-service migrate <command>
+
+commands:
+  up              apply all pending migrations
+  down [n]        roll back the last n migrations (default 1)
+  status          list migrations and whether they are applied
+  to <version>    migrate up or down to the given version (0 rolls back everything)`
+
+// runMigrate implements the "migrate" subcommand.
+func runMigrate(db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", migrateUsage)
+	}
+
+	migrator, err := migration.New(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		done, err := migrator.Up(ctx)
+		printMigrations("applied", done)
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid step count: %s", args[1])
+			}
+		}
+		done, err := migrator.Down(ctx, steps)
+		printMigrations("reverted", done)
+		return err
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("missing version\n%s", migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		done, err := migrator.To(ctx, version)
+		printMigrations("migrated", done)
+		return err
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range status {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], migrateUsage)
+	}
+}
+
+func printMigrations(action string, migrations []migration.Migration) {
+	if len(migrations) == 0 {
+		fmt.Println("no migrations", action)
+		return
+	}
+	for _, m := range migrations {
+		fmt.Printf("%s %04d_%s\n", action, m.Version, m.Name)
+	}
+}
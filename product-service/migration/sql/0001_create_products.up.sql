@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS products (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name        TEXT NOT NULL,
+    price       NUMERIC NOT NULL,
+    description TEXT,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    deleted_at  TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_name_if_not_deleted
+    ON products(name)
+    WHERE deleted_at IS NULL;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS product_images (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id    UUID NOT NULL REFERENCES products(id),
+    object_key    TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    content_type  TEXT NOT NULL,
+    size          BIGINT NOT NULL,
+    width         INT NOT NULL,
+    height        INT NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_images_product_id ON product_images(product_id);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id          UUID PRIMARY KEY,
+    topic       TEXT NOT NULL,
+    message     TEXT NOT NULL,
+    occurred_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_occurred_at ON outbox(occurred_at, id);
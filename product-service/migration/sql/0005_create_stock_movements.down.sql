@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS stock_movements;
+ALTER TABLE products DROP CONSTRAINT IF EXISTS stock_not_negative;
+ALTER TABLE products DROP COLUMN IF EXISTS stock;
@@ -0,0 +1,17 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS stock INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE products ADD CONSTRAINT stock_not_negative CHECK (stock >= 0);
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id  UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    delta       INTEGER NOT NULL,
+    reason      TEXT NOT NULL,
+    reference   TEXT,
+    stock       INTEGER NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product ON stock_movements(product_id, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS unique_stock_movement_reference
+    ON stock_movements(reference)
+    WHERE reference IS NOT NULL;
@@ -0,0 +1,21 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS rating_average NUMERIC(3, 2) NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS rating_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_products_rating ON products(rating_average) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id    UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    customer_id   TEXT NOT NULL,
+    order_id      TEXT NOT NULL,
+    rating        SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    title         TEXT NOT NULL DEFAULT '',
+    body          TEXT NOT NULL DEFAULT '',
+    hidden_at     TIMESTAMP,
+    hidden_reason TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS unique_review_per_customer ON reviews(product_id, customer_id);
+CREATE INDEX IF NOT EXISTS idx_reviews_product ON reviews(product_id, created_at DESC) WHERE hidden_at IS NULL;
@@ -0,0 +1,84 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+)
+
+// Event is a domain event waiting to be published. Message is the exact
+// Kafka value.
+type Event struct {
+	ID         string    `json:"event_id"`
+	Topic      string    `json:"topic"`
+	Message    string    `json:"message"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// NewEvent wraps body the way the services' consumers bind it:
+// {"event_id": ..., "occurred_at": ..., "body": body}.
+func NewEvent(topic string, body any) (Event, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Event{}, err
+	}
+	now := time.Now().UTC()
+	message, err := json.Marshal(map[string]any{
+		"event_id":    id.String(),
+		"occurred_at": now.Format(time.RFC3339),
+		"body":        body,
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:         id.String(),
+		Topic:      topic,
+		Message:    string(message),
+		OccurredAt: now,
+	}, nil
+}
+
+// Add records events in tx, the transaction that makes the change they
+// describe, so the change and its events commit or roll back together.
+func Add(ctx context.Context, tx *sql.Tx, events ...Event) error {
+	const query = `INSERT INTO outbox (id, topic, message, occurred_at) VALUES ($1, $2, $3, $4)`
+	for _, e := range events {
+		if _, err := tx.ExecContext(ctx, query, e.ID, e.Topic, e.Message, e.OccurredAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Publisher is the part of the Kafka client the relay needs.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, message []byte) error
+}
+
+// NewKafkaPublisher connects a Kafka producer for the relay from the same
+// settings app.StartKafka uses. It keeps retrying the connection in the
+// background; Publish fails until it is up.
+func NewKafkaPublisher(conf *config.Config) (Publisher, error) {
+	if conf.Kafka.Broker == "" {
+		return nil, errors.New("KAFKA_BROKER is required")
+	}
+	client := kafka.New(&kafka.Config{
+		Brokers:         strings.Split(conf.Kafka.Broker, ","),
+		BatchSize:       conf.Kafka.BatchSize,
+		BatchBytes:      conf.Kafka.BatchBytes,
+		BatchTimeout:    conf.Kafka.BatchTimeout,
+		ConsumerGroupID: conf.Kafka.ConsumerGroupID,
+	})
+	if client == nil {
+		return nil, errors.New("invalid kafka configuration")
+	}
+	return client, nil
+}
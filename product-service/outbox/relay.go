@@ -0,0 +1,114 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+)
+
+// Relay publishes the rows of the outbox table in the order they were
+// recorded and deletes them once Kafka has accepted them. The rows stay
+// locked while a batch is out, so several instances do not send the same
+// event; a crash before the delete commits still sends it again, so
+// consumers should tolerate a repeated event_id.
+type Relay struct {
+	db        *sql.DB
+	publisher Publisher
+	interval  time.Duration
+	batch     int
+}
+
+func NewRelay(db *sql.DB, publisher Publisher, conf *config.Config) *Relay {
+	interval, err := time.ParseDuration(conf.GetOrDefault("OUTBOX_INTERVAL", "1s"))
+	if err != nil || interval <= 0 {
+		interval = time.Second
+	}
+	batch, err := strconv.Atoi(conf.GetOrDefault("OUTBOX_BATCH", "100"))
+	if err != nil || batch <= 0 {
+		batch = 100
+	}
+
+	return &Relay{
+		db:        db,
+		publisher: publisher,
+		interval:  interval,
+		batch:     batch,
+	}
+}
+
+// Start runs Flush on every interval until ctx is cancelled.
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := r.Flush(ctx); err != nil {
+				log.Printf("outbox: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Flush publishes up to one batch of events and reports how many went out.
+// It stops at the first event Kafka refuses so the order is kept.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT id, topic, message
+	FROM outbox
+	ORDER BY occurred_at, id
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED`, r.batch)
+	if err != nil {
+		return 0, err
+	}
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.Topic, &e.Message); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var published []string
+	var publishErr error
+	for _, e := range events {
+		if publishErr = r.publisher.Publish(ctx, e.Topic, []byte(e.Message)); publishErr != nil {
+			break
+		}
+		published = append(published, e.ID)
+	}
+
+	if len(published) > 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE id = ANY($1)`, pq.Array(published)); err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, err
+		}
+		log.Printf("outbox: published %d events", len(published))
+	}
+	return len(published), publishErr
+}
@@ -0,0 +1,132 @@
+package product
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-product-service/apperror"
+)
+
+// productColumns maps the public field names accepted by fields= to the
+// products columns, in select order.
+var productColumns = []struct {
+	field  string
+	column string
+	dest   func(*ProductModel) any
+}{
+	{"id", "id", func(p *ProductModel) any { return &p.ID }},
+	{"name", "name", func(p *ProductModel) any { return &p.Name }},
+	{"price", "price", func(p *ProductModel) any { return &p.Price }},
+	{"description", "description", func(p *ProductModel) any { return &p.Description }},
+	{"category", "category", func(p *ProductModel) any { return &p.Category }},
+	{"stock", "stock", func(p *ProductModel) any { return &p.Stock }},
+	{"ratingAverage", "rating_average", func(p *ProductModel) any { return &p.RatingAverage }},
+	{"ratingCount", "rating_count", func(p *ProductModel) any { return &p.RatingCount }},
+	{"createdAt", "created_at", func(p *ProductModel) any { return &p.CreatedAt }},
+	{"updatedAt", "updated_at", func(p *ProductModel) any { return &p.UpdatedAt }},
+	{"deletedAt", "deleted_at", func(p *ProductModel) any { return &p.DeletedAt }},
+}
+
+// Fields is the sparse fieldset asked for with fields=, e.g.
+// fields=id,name,price. An empty set means every field.
+type Fields []string
+
+// splitIDs reads the comma separated ids= query parameter.
+func splitIDs(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ParseFields reads the fields query parameter.
+func ParseFields(ctx *kp.Context) (Fields, error) {
+	value := ctx.Param("fields")
+	if value == "" {
+		return nil, nil
+	}
+
+	var fields Fields
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || fields.Has(name) {
+			continue
+		}
+		if !isProductField(name) {
+			names := make([]string, 0, len(productColumns))
+			for _, c := range productColumns {
+				names = append(names, c.field)
+			}
+			return nil, apperror.Invalid(apperror.FieldError{
+				Field:   "fields",
+				Rule:    "oneof",
+				Message: "unknown field " + name + "; must be any of: " + strings.Join(names, ", "),
+			})
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+func isProductField(name string) bool {
+	for _, c := range productColumns {
+		if c.field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Has reports whether name was asked for. Every field is in an empty set.
+func (f Fields) Has(name string) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, n := range f {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// columns returns the select list for the set and the scan destinations
+// in p that go with it. The id and updated_at are always selected, the
+// ETag is derived from them.
+func (f Fields) columns(p *ProductModel) (string, []any) {
+	var names []string
+	var dest []any
+	for _, c := range productColumns {
+		if c.field == "id" || c.field == "updatedAt" || f.Has(c.field) {
+			names = append(names, c.column)
+			dest = append(dest, c.dest(p))
+		}
+	}
+	return strings.Join(names, ", "), dest
+}
+
+// MarshalJSON leaves out the fields that were not asked for, so a sparse
+// product is not padded with empty values. The id and href are always kept.
+func (p ProductModel) MarshalJSON() ([]byte, error) {
+	type plain ProductModel
+	data, err := json.Marshal(plain(p))
+	if err != nil || len(p.fields) == 0 {
+		return data, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	sparse := make(map[string]json.RawMessage, len(p.fields)+2)
+	for name, value := range all {
+		if name == "id" || name == "href" || p.fields.Has(name) {
+			sparse[name] = value
+		}
+	}
+	return json.Marshal(sparse)
+}
@@ -1,10 +1,33 @@
 package product
 
 import (
+	"mime/multipart"
+	"regexp"
+	"strconv"
+
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
 	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-product-service/apperror"
+	"github.com/sing3demons/go-product-service/httpcache"
+	"github.com/sing3demons/go-product-service/validation"
 )
 
+func init() {
+	validation.Register("price", "must be a positive amount", func(value string) bool {
+		price, err := strconv.ParseFloat(value, 64)
+		return err == nil && price > 0
+	})
+	validation.Register("category", "must be lower case letters, digits, - or _", func(value string) bool {
+		return categoryPattern.MatchString(value)
+	})
+	validation.Register("rating", "must be a number from 1 to 5", func(value string) bool {
+		rating, err := strconv.ParseFloat(value, 64)
+		return err == nil && rating >= 1 && rating <= 5
+	})
+}
+
+var categoryPattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
 type Handler struct {
 	service Service
 }
@@ -23,31 +46,11 @@ func (h *Handler) CreateProduct(ctx *kp.Context) error {
 		Code:        "200",
 		Description: "",
 	}
-	if err := ctx.Bind(&product); err != nil {
-		summary.Code = "400"
-		summary.Description = err.Error()
-		ctx.Log().SetSummary(summary).Error(logger.NewInbound("create product error", ""), map[string]any{
-			"error": err.Error(),
-		})
-		return ctx.JSON(400, map[string]string{
-			"error": "invalid_request",
-		})
-	}
-
-	if product.Name == "" {
-		summary.Code = "400"
-		summary.Description = "invalid_request"
-		ctx.Log().SetSummary(summary).Error(logger.NewInbound("create product error", ""), map[string]any{
-			"error": "name and price are required",
-		})
-		return ctx.JSON(400, map[string]string{
-			"error": "name and price are required",
-		})
+	if err := validation.Bind(ctx, &product); err != nil {
+		return validation.Respond(ctx, summary, err)
 	}
 	if err := h.service.CreateProduct(ctx, &product); err != nil {
-		return ctx.JSON(500, map[string]string{
-			"error": "internal_server_error",
-		})
+		return apperror.Write(ctx, err)
 	}
 
 	return ctx.JSON(201, map[string]any{
@@ -56,29 +59,69 @@ func (h *Handler) CreateProduct(ctx *kp.Context) error {
 	})
 }
 
+// UpdateProduct handles replacing the details of a product
+func (h *Handler) UpdateProduct(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "update_product",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var body ProductModel
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	body.ID = id
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("update product", ""), map[string]any{
+		"param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+		"body": body,
+	})
+
+	product, err := h.service.UpdateProduct(ctx, &body)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	return ctx.JSON(200, product)
+}
+
 // GetProductByID handles fetching a product by its ID
 func (h *Handler) GetProductByID(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "get_product_by_id",
+		Code:        "200",
+		Description: "",
+	}
 	id := ctx.PathParam("id")
-	if id == "" {
-		return ctx.JSON(400, map[string]string{
-			"error": "invalid_request",
-		})
+	if err := validation.Var("id", id, "required,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
 	}
-
-	product, err := h.service.GetProductByID(ctx, id)
+	fields, err := ParseFields(ctx)
 	if err != nil {
-		ctx.Log().Error(logger.NewInbound("get product error", ""), map[string]any{
-			"error": err.Error(),
-		})
-		return ctx.JSON(500, map[string]string{
-			"error": "internal_server_error",
-		})
+		return validation.Respond(ctx, summary, err)
 	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("get product", ""), map[string]any{
+		"param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+		"fields": fields,
+	})
 
-	if product == nil {
-		return ctx.JSON(404, map[string]string{
-			"error": "product_not_found",
-		})
+	product, err := h.service.GetProductByID(ctx, id, fields)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	if httpcache.NotModified(ctx, product.etag(), product.UpdatedAt) {
+		return nil
 	}
 
 	return ctx.JSON(200, product)
@@ -86,6 +129,16 @@ func (h *Handler) GetProductByID(ctx *kp.Context) error {
 
 // FindProducts handles fetching all products with optional filtering
 func (h *Handler) FindProducts(ctx *kp.Context) error {
+	return h.findProducts(ctx, false)
+}
+
+// FindInternalProducts is FindProducts for the other services, which may
+// also ask for soft-deleted products with include_deleted=true
+func (h *Handler) FindInternalProducts(ctx *kp.Context) error {
+	return h.findProducts(ctx, true)
+}
+
+func (h *Handler) findProducts(ctx *kp.Context, internal bool) error {
 	summary := logger.LogEventTag{
 		Node:        "client",
 		Command:     "find_products",
@@ -93,16 +146,40 @@ func (h *Handler) FindProducts(ctx *kp.Context) error {
 		Description: "",
 	}
 
+	fields, err := ParseFields(ctx)
+	if err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("min_rating", ctx.Param("min_rating"), "omitempty,rating"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	// ids= looks products up in bulk, include_deleted=true keeps the
+	// soft-deleted ones so callers can tell them from unknown ids.
+	ids := splitIDs(ctx.Param("ids"))
+	if err := validation.Var("ids", ids, "max=100,dive,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	includeDeleted := false
+	if internal {
+		if err := validation.Var("include_deleted", ctx.Param("include_deleted"), "omitempty,oneof=true false"); err != nil {
+			return validation.Respond(ctx, summary, err)
+		}
+		includeDeleted = ctx.Param("include_deleted") == "true"
+	}
+
 	ctx.Log().SetSummary(summary).Info(logger.NewInbound("find products", ""), map[string]any{
-		"name":  ctx.Param("name"),
-		"limit": ctx.Param("limit"),
+		"name":            ctx.Param("name"),
+		"category":        ctx.Param("category"),
+		"min_rating":      ctx.Param("min_rating"),
+		"ids":             ids,
+		"include_deleted": includeDeleted,
+		"limit":           ctx.Param("limit"),
+		"fields":          fields,
 	})
 
-	products, err := h.service.FindProducts(ctx)
+	products, err := h.service.FindProducts(ctx, fields, includeDeleted)
 	if err != nil {
-		return ctx.JSON(500, map[string]string{
-			"error": "internal_server_error",
-		})
+		return apperror.Write(ctx, err)
 	}
 
 	return ctx.JSON(200, map[string]any{
@@ -119,15 +196,8 @@ func (h *Handler) DeleteProduct(ctx *kp.Context) error {
 		Description: "",
 	}
 	id := ctx.PathParam("id")
-	if id == "" {
-		summary.Code = "400"
-		summary.Description = "invalid_request"
-		ctx.Log().SetSummary(summary).Error(logger.NewInbound("delete product error", ""), map[string]any{
-			"error": "product ID is required",
-		})
-		return ctx.JSON(400, map[string]string{
-			"error": "invalid_request",
-		})
+	if err := validation.Var("id", id, "required,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
 	}
 	ctx.Log().SetSummary(summary).Info(logger.NewInbound("delete product", ""), map[string]any{
 		"param": map[string]string{
@@ -137,10 +207,146 @@ func (h *Handler) DeleteProduct(ctx *kp.Context) error {
 	})
 
 	if err := h.service.DeleteProduct(ctx, id); err != nil {
-		return ctx.JSON(500, map[string]string{
-			"error": "internal_server_error",
-		})
+		return apperror.Write(ctx, err)
 	}
 
 	return ctx.JSON(204, nil)
 }
+
+// RestoreProduct handles bringing back a soft-deleted product
+func (h *Handler) RestoreProduct(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "restore_product",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("restore product", ""), map[string]any{
+		"param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+	})
+
+	product, err := h.service.RestoreProduct(ctx, id)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	return ctx.JSON(200, product)
+}
+
+// AdjustStock handles adding units to or taking units from the stock of a
+// product
+func (h *Handler) AdjustStock(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "adjust_stock",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var body StockAdjustment
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("adjust stock", ""), map[string]any{
+		"param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+		"body": body,
+	})
+
+	movement, err := h.service.AdjustStock(ctx, id, body)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	return ctx.JSON(200, movement)
+}
+
+type imageUpload struct {
+	File multipart.FileHeader `file:"file"`
+}
+
+// UploadImage handles attaching an image to a product
+func (h *Handler) UploadImage(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "upload_product_image",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+
+	var body imageUpload
+	if err := ctx.Bind(&body); err != nil || body.File.Filename == "" {
+		return validation.Respond(ctx, summary, apperror.Invalid(apperror.FieldError{
+			Field:   "file",
+			Rule:    "required",
+			Message: "is required",
+		}))
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("upload product image", ""), map[string]any{
+		"param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+		"file": map[string]any{
+			"filename":     body.File.Filename,
+			"size":         body.File.Size,
+			"content_type": body.File.Header.Get("Content-Type"),
+		},
+	})
+
+	image, err := h.service.UploadImage(ctx, id, &body.File)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	return ctx.JSON(201, map[string]any{
+		"message": "success",
+		"image":   image,
+	})
+}
+
+// FindImages handles listing the images of a product
+func (h *Handler) FindImages(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "find_product_images",
+		Code:        "200",
+		Description: "",
+	}
+	id := ctx.PathParam("id")
+	if err := validation.Var("id", id, "required,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("find product images", ""), map[string]any{
+		"param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+	})
+
+	images, err := h.service.FindImages(ctx, id)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	return ctx.JSON(200, map[string]any{
+		"images": images,
+	})
+}
@@ -1,14 +1,95 @@
 package product
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-product-service/httpcache"
+)
 
 type ProductModel struct {
-	ID          string     `json:"id,omitempty"`
-	Name        string     `json:"name"`
-	Href        string     `json:"href,omitempty"`
-	Price       string     `json:"price"`
-	Description string     `json:"description,omitempty"`
-	CreatedAt   time.Time  `json:"createdAt,omitzero"`
-	UpdatedAt   time.Time  `json:"updatedAt,omitzero"`
-	DeletedAt   *time.Time `json:"deletedAt,omitzero"`
+	ID            string     `json:"id,omitempty"`
+	Name          string     `json:"name" validate:"required,max=255"`
+	Href          string     `json:"href,omitempty"`
+	Price         string     `json:"price" validate:"required,price"`
+	Description   string     `json:"description,omitempty" validate:"max=2000"`
+	Category      string     `json:"category,omitempty" validate:"omitempty,max=64,category"` // Tax category, e.g. books or food
+	Stock         int        `json:"stock"`                                                   // Units on hand, changed only through stock movements
+	RatingAverage float64    `json:"ratingAverage"`                                           // Of the visible reviews
+	RatingCount   int        `json:"ratingCount"`
+	CreatedAt     time.Time  `json:"createdAt,omitzero"`
+	UpdatedAt     time.Time  `json:"updatedAt,omitzero"`
+	DeletedAt     *time.Time `json:"deletedAt,omitzero"`
+
+	// fields is the sparse fieldset the product was loaded with, see Fields.
+	fields Fields
+}
+
+// etag identifies this representation of the product. The fieldset is part
+// of it since a sparse product is a different body.
+func (p *ProductModel) etag() string {
+	return httpcache.ETag(p.ID, p.UpdatedAt.UTC().Format(time.RFC3339Nano), strings.Join(p.fields, ","))
+}
+
+type ProductImage struct {
+	ID           string    `json:"id,omitempty"`
+	ProductID    string    `json:"productId"`
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnailUrl"`
+	ContentType  string    `json:"contentType"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	CreatedAt    time.Time `json:"createdAt,omitzero"`
+}
+
+// ProductEvent is the body of the product_created, product_updated and
+// product_deleted events. Changes holds the new values of the fields that
+// were written.
+type ProductEvent struct {
+	ID      string         `json:"id"`
+	Changes map[string]any `json:"changes,omitempty"`
+	At      string         `json:"at"`
+}
+
+// PriceChangedEvent is the body of the price_changed event.
+type PriceChangedEvent struct {
+	ID       string `json:"id"`
+	OldPrice string `json:"old_price"`
+	NewPrice string `json:"new_price"`
+	At       string `json:"at"`
+}
+
+// StockAdjustment is the body of POST /internal/products/{id}/stock. A
+// reference, e.g. the return it comes from, makes the adjustment
+// idempotent: it is applied once however often it is sent. A reference
+// names one movement, so it cannot be reused for another product.
+type StockAdjustment struct {
+	Delta     int    `json:"delta" validate:"required"`
+	Reason    string `json:"reason" validate:"required,oneof=restock return sale correction"`
+	Reference string `json:"reference,omitempty" validate:"max=128"`
+}
+
+// StockMovement is one change of the stock of a product. Stock is the
+// level it left the product at.
+type StockMovement struct {
+	ID        string    `json:"id"`
+	ProductID string    `json:"productId"`
+	Delta     int       `json:"delta"`
+	Reason    string    `json:"reason"`
+	Reference string    `json:"reference,omitempty"`
+	Stock     int       `json:"stock"` // Units on hand, changed only through stock movements
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// StockChangedEvent is the body of the stock_changed event.
+type StockChangedEvent struct {
+	ID        string `json:"id"`
+	Delta     int    `json:"delta"`
+	Stock     int    `json:"stock"` // Units on hand, changed only through stock movements
+	Reason    string `json:"reason"`
+	Reference string `json:"reference,omitempty"`
+	At        string `json:"at"`
 }
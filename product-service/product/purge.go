@@ -0,0 +1,111 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-product-service/media"
+)
+
+// Purger permanently removes products that have been soft-deleted for
+// longer than the retention period, together with their images.
+type Purger struct {
+	db        *sql.DB
+	storage   media.Storage
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurger(db *sql.DB, storage media.Storage, conf *config.Config) *Purger {
+	retention, err := time.ParseDuration(conf.GetOrDefault("PURGE_RETENTION", "720h"))
+	if err != nil || retention <= 0 {
+		retention = 30 * 24 * time.Hour
+	}
+	interval, err := time.ParseDuration(conf.GetOrDefault("PURGE_INTERVAL", "1h"))
+	if err != nil || interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &Purger{
+		db:        db,
+		storage:   storage,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Start runs Purge on every interval until ctx is cancelled.
+func (p *Purger) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := p.Purge(ctx); err != nil {
+				log.Printf("purge products: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *Purger) Purge(ctx context.Context) (int64, error) {
+	cutoff := time.Now().UTC().Add(-p.retention)
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM products WHERE deleted_at < $1 FOR UPDATE`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM product_images WHERE product_id = $1`, id); err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM products WHERE id = $1`, id); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// Blobs go after the commit so a failed transaction never leaves rows
+	// pointing at missing images.
+	for _, id := range ids {
+		if err := p.storage.DeletePrefix(ctx, "products/"+id); err != nil {
+			log.Printf("purge images of product %s: %v", id, err)
+		}
+	}
+
+	log.Printf("purged %d products soft-deleted before %s", len(ids), cutoff.Format(time.RFC3339))
+	return int64(len(ids)), nil
+}
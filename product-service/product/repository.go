@@ -1,20 +1,32 @@
 package product
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
 	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-product-service/apperror"
+	"github.com/sing3demons/go-product-service/outbox"
 )
 
 type Repository interface {
-	FindByID(ctx *kp.Context, id string) (*ProductModel, error)
+	FindByID(ctx *kp.Context, id string, fields Fields) (*ProductModel, error)
 	CreateProduct(ctx *kp.Context, product *ProductModel) error
-	FindProducts(ctx *kp.Context) ([]*ProductModel, error)
+	UpdateProduct(ctx *kp.Context, product *ProductModel) error
+	FindProducts(ctx *kp.Context, fields Fields, includeDeleted bool) ([]*ProductModel, error)
 	DeleteProduct(ctx *kp.Context, id string) error
+	RestoreProduct(ctx *kp.Context, id string) error
+	CreateImage(ctx *kp.Context, image *ProductImage) error
+	FindImages(ctx *kp.Context, productID string) ([]*ProductImage, error)
+	AdjustStock(ctx *kp.Context, productID string, adjustment StockAdjustment) (*StockMovement, error)
 }
 type repository struct {
 	db *sql.DB
@@ -24,17 +36,18 @@ func NewRepository(db *sql.DB) Repository {
 	return &repository{db: db}
 }
 
-func (r *repository) FindByID(ctx *kp.Context, id string) (*ProductModel, error) {
-	query := `SELECT id, name, price, description, created_at, updated_at FROM products WHERE id = $1 AND deleted_at IS NULL`
+func (r *repository) FindByID(ctx *kp.Context, id string, fields Fields) (*ProductModel, error) {
+	product := ProductModel{fields: fields}
+	columns, dest := fields.columns(&product)
+	query := `SELECT ` + columns + ` FROM products WHERE id = $1 AND deleted_at IS NULL`
 	row := r.db.QueryRow(query, id)
 
-	var product ProductModel
-	err := row.Scan(&product.ID, &product.Name, &product.Price, &product.Description, &product.CreatedAt, &product.UpdatedAt)
+	err := row.Scan(dest...)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, nil // No product found
+			return nil, ErrProductNotFound
 		}
-		return nil, err // Other error
+		return nil, apperror.Internal(err)
 	}
 	product.Href = "/products/" + product.ID
 
@@ -45,25 +58,53 @@ func (r *repository) CreateProduct(ctx *kp.Context, product *ProductModel) error
 	start := time.Now()
 	summary := logger.EventTag("progress", "insert_product", "200", "success")
 
-	query := `INSERT INTO products (name, price, description, created_at, updated_at) VALUES ($1, $2, $3, NOW(), NOW()) RETURNING id`
+	query := `INSERT INTO products (name, price, description, category, created_at, updated_at) VALUES ($1, $2, $3, $4, NOW(), NOW()) RETURNING id`
 
 	ctx.Log().Info(logger.NewDBRequest(logger.INSERT, "create product"), map[string]any{
 		"query":  query,
-		"params": []any{product.Name, product.Price, product.Description},
+		"params": []any{product.Name, product.Price, product.Description, product.Category},
+	})
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var id string
+		if err := tx.QueryRowContext(ctx, query, product.Name, product.Price, product.Description, product.Category).Scan(&id); err != nil {
+			return err
+		}
+		event, err := outbox.NewEvent(productCreatedTopic, ProductEvent{
+			ID: id,
+			Changes: map[string]any{
+				"name":        product.Name,
+				"price":       product.Price,
+				"description": product.Description,
+				"category":    product.Category,
+			},
+			At: start.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+		if err := outbox.Add(ctx, tx, event); err != nil {
+			return err
+		}
+		product.ID = id
+		return nil
 	})
-	var id string
-	err := r.db.QueryRow(query, product.Name, product.Price, product.Description).Scan(&id)
 
 	summary.ResTime = time.Since(start).Milliseconds()
 	if err != nil {
 		summary.Code = "500"
 		summary.Description = err.Error()
+		if nameTaken(err) {
+			summary.Code = "409"
+			summary.Description = ErrProductNameTaken.Code
+		}
 		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.INSERT, "create product error"), map[string]any{
 			"error": err.Error(),
 		})
-		return err
+		if nameTaken(err) {
+			return ErrProductNameTaken.Wrap(err)
+		}
+		return apperror.Internal(err)
 	}
-	product.ID = id
 
 	summary.Code = "201"
 	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.INSERT, "create product success"), map[string]any{
@@ -72,18 +113,130 @@ func (r *repository) CreateProduct(ctx *kp.Context, product *ProductModel) error
 	return nil
 }
 
-func (r *repository) FindProducts(ctx *kp.Context) ([]*ProductModel, error) {
+// UpdateProduct replaces the name, price, description and category of a live
+// product. A new price also records a price_changed event.
+func (r *repository) UpdateProduct(ctx *kp.Context, product *ProductModel) error {
+	start := time.Now()
+	summary := logger.EventTag("progress", "update_product", "200", "success")
+
+	lock := `SELECT price FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	query := `UPDATE products SET name = $2, price = $3, description = $4, category = $5, updated_at = NOW() WHERE id = $1`
+
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "update product"), map[string]any{
+		"query":  query,
+		"params": []any{product.ID, product.Name, product.Price, product.Description, product.Category},
+	})
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var oldPrice string
+		if err := tx.QueryRowContext(ctx, lock, product.ID).Scan(&oldPrice); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, query, product.ID, product.Name, product.Price, product.Description, product.Category); err != nil {
+			return err
+		}
+
+		at := start.UTC().Format(time.RFC3339)
+		event, err := outbox.NewEvent(productUpdatedTopic, ProductEvent{
+			ID: product.ID,
+			Changes: map[string]any{
+				"name":        product.Name,
+				"price":       product.Price,
+				"description": product.Description,
+				"category":    product.Category,
+			},
+			At: at,
+		})
+		if err != nil {
+			return err
+		}
+		events := []outbox.Event{event}
+		if !samePrice(oldPrice, product.Price) {
+			event, err := outbox.NewEvent(priceChangedTopic, PriceChangedEvent{
+				ID:       product.ID,
+				OldPrice: oldPrice,
+				NewPrice: product.Price,
+				At:       at,
+			})
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return outbox.Add(ctx, tx, events...)
+	})
+
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		switch {
+		case err == sql.ErrNoRows:
+			summary.Code = "404"
+			summary.Description = ErrProductNotFound.Code
+			err = ErrProductNotFound
+		case nameTaken(err):
+			summary.Code = "409"
+			summary.Description = ErrProductNameTaken.Code
+			err = ErrProductNameTaken.Wrap(err)
+		}
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "update product error"), map[string]any{
+			"error": summary.Description,
+		})
+		return apperror.From(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "update product success"), map[string]any{
+		"Return": product,
+	})
+	return nil
+}
+
+// samePrice compares two NUMERIC prices by value, so "20" and "20.00" match.
+func samePrice(a, b string) bool {
+	x, okX := new(big.Rat).SetString(a)
+	y, okY := new(big.Rat).SetString(b)
+	if !okX || !okY {
+		return a == b
+	}
+	return x.Cmp(y) == 0
+}
+
+// withTx runs fn in a transaction, committing when it returns nil.
+func (r *repository) withTx(ctx *kp.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) FindProducts(ctx *kp.Context, fields Fields, includeDeleted bool) ([]*ProductModel, error) {
 	start := time.Now()
 	summary := logger.EventTag("progress", "find_products", "200", "success")
+	columns, _ := fields.columns(&ProductModel{})
 	baseQuery := `
-	SELECT id, name, price, description, created_at, updated_at
+	SELECT ` + columns + `
 	FROM products
-	WHERE deleted_at IS NULL`
+	WHERE TRUE`
+	if !includeDeleted {
+		baseQuery += " AND deleted_at IS NULL"
+	}
 
 	name := ctx.Param("name")
 	var args []any
 	argIndex := 1
 
+	ids := splitIDs(ctx.Param("ids"))
+	if len(ids) > 0 {
+		baseQuery += fmt.Sprintf(" AND id = ANY($%d)", argIndex)
+		args = append(args, pq.Array(ids))
+		argIndex++
+	}
+
 	if name != "" {
 		if name != "" {
 			baseQuery += fmt.Sprintf(" AND name ILIKE '%%' || $%d || '%%'", argIndex)
@@ -92,6 +245,16 @@ func (r *repository) FindProducts(ctx *kp.Context) ([]*ProductModel, error) {
 		}
 
 	}
+	if category := ctx.Param("category"); category != "" {
+		baseQuery += fmt.Sprintf(" AND category = $%d", argIndex)
+		args = append(args, category)
+		argIndex++
+	}
+	if minRating := ctx.Param("min_rating"); minRating != "" {
+		baseQuery += fmt.Sprintf(" AND rating_average >= $%d", argIndex)
+		args = append(args, minRating)
+		argIndex++
+	}
 
 	limit := 0
 	l := ctx.Param("limit")
@@ -101,6 +264,9 @@ func (r *repository) FindProducts(ctx *kp.Context) ([]*ProductModel, error) {
 	if limit == 0 {
 		limit = 20 // Default limit if not specified
 	}
+	if len(ids) > 0 {
+		limit = len(ids)
+	}
 	baseQuery += " ORDER BY created_at DESC LIMIT $" + fmt.Sprintf("%d", argIndex)
 	args = append(args, limit)
 
@@ -117,21 +283,22 @@ func (r *repository) FindProducts(ctx *kp.Context) ([]*ProductModel, error) {
 		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find products error"), map[string]any{
 			"error": err.Error(),
 		})
-		return nil, err
+		return nil, apperror.Internal(err)
 	}
 	defer rows.Close()
 
 	var products []*ProductModel
 	for rows.Next() {
-		var product ProductModel
-		err := rows.Scan(&product.ID, &product.Name, &product.Price, &product.Description, &product.CreatedAt, &product.UpdatedAt)
+		product := ProductModel{fields: fields}
+		_, dest := fields.columns(&product)
+		err := rows.Scan(dest...)
 		if err != nil {
 			summary.Code = "500"
 			summary.Description = err.Error()
 			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "scan product error"), map[string]any{
 				"error": err.Error(),
 			})
-			return nil, err
+			return nil, apperror.Internal(err)
 		}
 		product.Href = "/products/" + product.ID
 		products = append(products, &product)
@@ -148,29 +315,42 @@ func (r *repository) FindProducts(ctx *kp.Context) ([]*ProductModel, error) {
 func (r *repository) DeleteProduct(ctx *kp.Context, id string) error {
 	start := time.Now()
 	summary := logger.EventTag("progress", "delete_product", "200", "success")
-	query := `UPDATE products SET deleted_at = NOW() WHERE id = $1`
+	query := `UPDATE products SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
 	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "delete product"), map[string]any{
 		"query":  query,
 		"params": []any{id},
 	})
 
-	result, err := r.db.Exec(query, id)
+	var rowsAffected int64
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, _ = result.RowsAffected(); rowsAffected == 0 {
+			return nil
+		}
+		event, err := outbox.NewEvent(productDeletedTopic, ProductEvent{ID: id, At: start.UTC().Format(time.RFC3339)})
+		if err != nil {
+			return err
+		}
+		return outbox.Add(ctx, tx, event)
+	})
 	summary.ResTime = time.Since(start).Milliseconds()
 	if err != nil {
 		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "delete product error"), map[string]any{
 			"error": err.Error(),
 		})
-		return err
+		return apperror.Internal(err)
 	}
 
-	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
 		summary.Code = "404"
 		summary.Description = "product not found"
 		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "delete product not found"), map[string]any{
 			"error": fmt.Sprintf("product with id %s not found", id),
 		})
-		return fmt.Errorf("product with id %s not found", id)
+		return ErrProductNotFound
 	}
 
 	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "delete product success"), map[string]any{
@@ -178,3 +358,284 @@ func (r *repository) DeleteProduct(ctx *kp.Context, id string) error {
 	})
 	return nil
 }
+
+// nameTaken reports whether err is a violation of the unique product name
+// index.
+func nameTaken(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "unique_name_if_not_deleted"
+}
+
+// AdjustStock adds adjustment.Delta to the stock of a product and records
+// the movement. A reference already recorded for the product returns that
+// movement instead of applying it again; one recorded for another product
+// is a conflict. Deleted products can still be counted, so goods
+// coming back are never lost track of.
+func (r *repository) AdjustStock(ctx *kp.Context, productID string, adjustment StockAdjustment) (*StockMovement, error) {
+	start := time.Now()
+	summary := logger.EventTag("progress", "adjust_stock", "200", "success")
+
+	update := `UPDATE products SET stock = stock + $2, updated_at = NOW() WHERE id = $1 RETURNING stock`
+	insert := `INSERT INTO stock_movements (product_id, delta, reason, reference, stock) VALUES ($1, $2, $3, NULLIF($4, ''), $5) RETURNING id, created_at`
+
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "adjust stock"), map[string]any{
+		"query":  update,
+		"params": []any{productID, adjustment.Delta, adjustment.Reason, adjustment.Reference},
+	})
+
+	var movement *StockMovement
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		if adjustment.Reference != "" {
+			found, err := findMovement(ctx, tx, adjustment.Reference)
+			if err == nil {
+				movement = found
+				return sameProduct(found, productID)
+			}
+			if err != sql.ErrNoRows {
+				return err
+			}
+		}
+
+		m := StockMovement{
+			ProductID: productID,
+			Delta:     adjustment.Delta,
+			Reason:    adjustment.Reason,
+			Reference: adjustment.Reference,
+		}
+		if err := tx.QueryRowContext(ctx, update, productID, adjustment.Delta).Scan(&m.Stock); err != nil {
+			return err
+		}
+		if err := tx.QueryRowContext(ctx, insert, productID, m.Delta, m.Reason, m.Reference, m.Stock).Scan(&m.ID, &m.CreatedAt); err != nil {
+			return err
+		}
+		event, err := outbox.NewEvent(stockChangedTopic, StockChangedEvent{
+			ID:        productID,
+			Delta:     m.Delta,
+			Stock:     m.Stock,
+			Reason:    m.Reason,
+			Reference: m.Reference,
+			At:        start.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+		if err := outbox.Add(ctx, tx, event); err != nil {
+			return err
+		}
+		movement = &m
+		return nil
+	})
+	if err != nil && referenceTaken(err) {
+		// Sent twice at once: the other one won, answer with its movement.
+		movement, err = findMovement(ctx, r.db, adjustment.Reference)
+		if err == nil {
+			err = sameProduct(movement, productID)
+		}
+	}
+
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		switch {
+		case err == sql.ErrNoRows:
+			summary.Code = "404"
+			summary.Description = ErrProductNotFound.Code
+			err = ErrProductNotFound
+		case outOfStock(err):
+			summary.Code = "409"
+			summary.Description = ErrOutOfStock.Code
+			err = ErrOutOfStock.Wrap(err)
+		case errors.Is(err, ErrReferenceTaken):
+			summary.Code = "409"
+			summary.Description = ErrReferenceTaken.Code
+		}
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "adjust stock error"), map[string]any{
+			"error": summary.Description,
+		})
+		return nil, apperror.From(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "adjust stock success"), map[string]any{
+		"Return": movement,
+	})
+	return movement, nil
+}
+
+// rowQuerier is a *sql.DB or a *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// findMovement returns the stock movement recorded under reference.
+func findMovement(ctx *kp.Context, q rowQuerier, reference string) (*StockMovement, error) {
+	query := `SELECT id, product_id, delta, reason, reference, stock, created_at FROM stock_movements WHERE reference = $1`
+	var m StockMovement
+	err := q.QueryRowContext(ctx, query, reference).Scan(&m.ID, &m.ProductID, &m.Delta, &m.Reason, &m.Reference, &m.Stock, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// sameProduct fails with ErrReferenceTaken when the movement found under a
+// reference belongs to another product than productID.
+func sameProduct(m *StockMovement, productID string) error {
+	if !strings.EqualFold(m.ProductID, productID) {
+		return ErrReferenceTaken
+	}
+	return nil
+}
+
+// outOfStock reports whether err is a violation of the non-negative stock
+// check.
+func outOfStock(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23514" && pqErr.Constraint == "stock_not_negative"
+}
+
+// referenceTaken reports whether err is a violation of the unique stock
+// movement reference index.
+func referenceTaken(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "unique_stock_movement_reference"
+}
+
+// RestoreProduct clears deleted_at on a soft-deleted product. It fails with
+// ErrProductNameTaken when a live product already uses the same name.
+func (r *repository) RestoreProduct(ctx *kp.Context, id string) error {
+	start := time.Now()
+	summary := logger.EventTag("progress", "restore_product", "200", "success")
+	query := `UPDATE products SET deleted_at = NULL, updated_at = NOW() WHERE id = $1 AND deleted_at IS NOT NULL`
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "restore product"), map[string]any{
+		"query":  query,
+		"params": []any{id},
+	})
+
+	var rowsAffected int64
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, _ = result.RowsAffected(); rowsAffected == 0 {
+			return nil
+		}
+		event, err := outbox.NewEvent(productUpdatedTopic, ProductEvent{
+			ID:      id,
+			Changes: map[string]any{"deleted": false},
+			At:      start.UTC().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+		return outbox.Add(ctx, tx, event)
+	})
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		if nameTaken(err) {
+			summary.Code = "409"
+			summary.Description = ErrProductNameTaken.Code
+			err = ErrProductNameTaken.Wrap(err)
+		}
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "restore product error"), map[string]any{
+			"error": summary.Description,
+		})
+		return apperror.From(err)
+	}
+
+	if rowsAffected == 0 {
+		summary.Code = "404"
+		summary.Description = "product not found"
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "restore product not found"), map[string]any{
+			"error": fmt.Sprintf("deleted product with id %s not found", id),
+		})
+		return ErrProductNotFound
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "restore product success"), map[string]any{
+		"rows_affected": rowsAffected,
+	})
+	return nil
+}
+
+func (r *repository) CreateImage(ctx *kp.Context, image *ProductImage) error {
+	start := time.Now()
+	summary := logger.EventTag("progress", "insert_product_image", "200", "success")
+
+	query := `INSERT INTO product_images (product_id, object_key, thumbnail_key, content_type, size, width, height, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, NOW()) RETURNING id, created_at`
+	params := []any{image.ProductID, image.Key, image.ThumbnailKey, image.ContentType, image.Size, image.Width, image.Height}
+
+	ctx.Log().Info(logger.NewDBRequest(logger.INSERT, "create product image"), map[string]any{
+		"query":  query,
+		"params": params,
+	})
+
+	err := r.db.QueryRow(query, params...).Scan(&image.ID, &image.CreatedAt)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.INSERT, "create product image error"), map[string]any{
+			"error": err.Error(),
+		})
+		return apperror.Internal(err)
+	}
+
+	summary.Code = "201"
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.INSERT, "create product image success"), map[string]any{
+		"Return": image,
+	})
+	return nil
+}
+
+func (r *repository) FindImages(ctx *kp.Context, productID string) ([]*ProductImage, error) {
+	start := time.Now()
+	summary := logger.EventTag("progress", "find_product_images", "200", "success")
+	query := `
+	SELECT id, product_id, object_key, thumbnail_key, content_type, size, width, height, created_at
+	FROM product_images
+	WHERE product_id = $1
+	ORDER BY created_at ASC`
+
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find product images"), map[string]any{
+		"query":  query,
+		"params": []any{productID},
+	})
+
+	rows, err := r.db.Query(query, productID)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find product images error"), map[string]any{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+	defer rows.Close()
+
+	images := []*ProductImage{}
+	for rows.Next() {
+		var image ProductImage
+		err := rows.Scan(&image.ID, &image.ProductID, &image.Key, &image.ThumbnailKey, &image.ContentType, &image.Size, &image.Width, &image.Height, &image.CreatedAt)
+		if err != nil {
+			summary.Code = "500"
+			summary.Description = err.Error()
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "scan product image error"), map[string]any{
+				"error": err.Error(),
+			})
+			return nil, apperror.Internal(err)
+		}
+		images = append(images, &image)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find product images success"), map[string]any{
+		"Return": images,
+	})
+
+	return images, nil
+}
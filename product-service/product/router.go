@@ -2,49 +2,31 @@ package product
 
 import (
 	"database/sql"
-	"fmt"
 
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-product-service/media"
+	"github.com/sing3demons/go-product-service/servicetoken"
 )
 
-const createTable = `
-CREATE TABLE IF NOT EXISTS products (
-   id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    name        TEXT NOT NULL,
-    price       NUMERIC NOT NULL,
-    description TEXT,
-    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
-    updated_at  TIMESTAMP NOT NULL DEFAULT NOW(),
-    deleted_at  TIMESTAMP
-);
-
-    DO $$
-    BEGIN
-        IF NOT EXISTS (
-            SELECT 1
-            FROM pg_indexes
-            WHERE indexname = 'unique_name_if_not_deleted'
-        ) THEN
-            CREATE UNIQUE INDEX unique_name_if_not_deleted
-            ON products(name)
-            WHERE deleted_at IS NULL;
-        END IF;
-    END
-    $$;`
-
-func RegisterRoutes(app kp.IApplication, db *sql.DB) {
-	_, err := db.Exec(createTable)
-	if err != nil {
-		fmt.Println("Error creating products table:", err)
-		return
-	}
-
+func RegisterRoutes(app kp.IApplication, db *sql.DB, mediaSvc media.Service, guard *servicetoken.Guard) {
 	repo := NewRepository(db)
-	service := NewService(repo)
+	service := NewService(repo, mediaSvc)
 	handler := NewHandler(service)
 
 	app.Post("/products", handler.CreateProduct)
 	app.Get("/products/{id}", handler.GetProductByID)
 	app.Get("/products", handler.FindProducts)
+	app.Put("/products/{id}", handler.UpdateProduct)
 	app.Delete("/products/{id}", handler.DeleteProduct)
+	app.Post("/products/{id}/restore", handler.RestoreProduct)
+	app.Post("/products/{id}/images", handler.UploadImage)
+	app.Get("/products/{id}/images", handler.FindImages)
+
+	// Internal routes for the other services, see servicetoken. Stock is
+	// only adjusted here, e.g. by order-service restocking a return, and
+	// only here are soft-deleted products listed, e.g. for user-service to
+	// flag them on wishlists.
+	app.Get("/internal/products", guard.Require(handler.FindInternalProducts))
+	app.Get("/internal/products/{id}", guard.Require(handler.GetProductByID))
+	app.Post("/internal/products/{id}/stock", guard.Require(handler.AdjustStock))
 }
@@ -1,33 +1,123 @@
 package product
 
-import "github.com/sing3demons/go-common-kp/kp/pkg/kp"
+import (
+	"mime/multipart"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-product-service/apperror"
+	"github.com/sing3demons/go-product-service/media"
+)
+
+const (
+	productCreatedTopic = "product_created"
+	productUpdatedTopic = "product_updated"
+	productDeletedTopic = "product_deleted"
+	priceChangedTopic   = "price_changed"
+	stockChangedTopic   = "stock_changed"
+)
+
+var (
+	ErrProductNotFound  = apperror.NotFound("product_not_found", "product not found")
+	ErrProductNameTaken = apperror.Conflict("duplicate_key", "name", "name is already used by another product")
+	ErrOutOfStock       = apperror.Conflict("insufficient_stock", "delta", "the product does not have that many units in stock")
+	ErrReferenceTaken   = apperror.Conflict("stock_reference_taken", "reference", "the reference is already recorded for another product")
+)
 
 type Service interface {
-	GetProductByID(ctx *kp.Context, id string) (*ProductModel, error)
+	GetProductByID(ctx *kp.Context, id string, fields Fields) (*ProductModel, error)
 	CreateProduct(ctx *kp.Context, product *ProductModel) error
-	FindProducts(ctx *kp.Context) ([]*ProductModel, error)
+	UpdateProduct(ctx *kp.Context, product *ProductModel) (*ProductModel, error)
+	FindProducts(ctx *kp.Context, fields Fields, includeDeleted bool) ([]*ProductModel, error)
 	DeleteProduct(ctx *kp.Context, id string) error
+	RestoreProduct(ctx *kp.Context, id string) (*ProductModel, error)
+	UploadImage(ctx *kp.Context, productID string, file *multipart.FileHeader) (*ProductImage, error)
+	FindImages(ctx *kp.Context, productID string) ([]*ProductImage, error)
+	AdjustStock(ctx *kp.Context, productID string, adjustment StockAdjustment) (*StockMovement, error)
 }
 
 type service struct {
-	repo Repository
+	repo  Repository
+	media media.Service
 }
 
-func NewService(repo Repository) Service {
-	return &service{repo: repo}
+func NewService(repo Repository, mediaSvc media.Service) Service {
+	return &service{repo: repo, media: mediaSvc}
 }
 func (s *service) CreateProduct(ctx *kp.Context, product *ProductModel) error {
 	return s.repo.CreateProduct(ctx, product)
 }
 
-func (s *service) GetProductByID(ctx *kp.Context, id string) (*ProductModel, error) {
-	return s.repo.FindByID(ctx, id)
+func (s *service) UpdateProduct(ctx *kp.Context, product *ProductModel) (*ProductModel, error) {
+	if err := s.repo.UpdateProduct(ctx, product); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, product.ID, nil)
+}
+
+func (s *service) GetProductByID(ctx *kp.Context, id string, fields Fields) (*ProductModel, error) {
+	return s.repo.FindByID(ctx, id, fields)
 }
 
-func (s *service) FindProducts(ctx *kp.Context) ([]*ProductModel, error) {
-	return s.repo.FindProducts(ctx)
+func (s *service) FindProducts(ctx *kp.Context, fields Fields, includeDeleted bool) ([]*ProductModel, error) {
+	return s.repo.FindProducts(ctx, fields, includeDeleted)
 }
 
 func (s *service) DeleteProduct(ctx *kp.Context, id string) error {
 	return s.repo.DeleteProduct(ctx, id)
 }
+
+func (s *service) RestoreProduct(ctx *kp.Context, id string) (*ProductModel, error) {
+	if err := s.repo.RestoreProduct(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, id, nil)
+}
+
+func (s *service) UploadImage(ctx *kp.Context, productID string, file *multipart.FileHeader) (*ProductImage, error) {
+	if _, err := s.repo.FindByID(ctx, productID, nil); err != nil {
+		return nil, err
+	}
+
+	m, err := s.media.Upload(ctx, "products/"+productID, file)
+	if err != nil {
+		return nil, err
+	}
+
+	image := &ProductImage{
+		ProductID:    productID,
+		Key:          m.Key,
+		ThumbnailKey: m.ThumbnailKey,
+		URL:          m.URL,
+		ThumbnailURL: m.ThumbnailURL,
+		ContentType:  m.ContentType,
+		Size:         m.Size,
+		Width:        m.Width,
+		Height:       m.Height,
+	}
+	if err := s.repo.CreateImage(ctx, image); err != nil {
+		s.media.Delete(ctx, m.Key)
+		s.media.Delete(ctx, m.ThumbnailKey)
+		return nil, err
+	}
+	return image, nil
+}
+
+func (s *service) FindImages(ctx *kp.Context, productID string) ([]*ProductImage, error) {
+	if _, err := s.repo.FindByID(ctx, productID, nil); err != nil {
+		return nil, err
+	}
+
+	images, err := s.repo.FindImages(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range images {
+		image.URL = s.media.URL(ctx, image.Key)
+		image.ThumbnailURL = s.media.URL(ctx, image.ThumbnailKey)
+	}
+	return images, nil
+}
+
+func (s *service) AdjustStock(ctx *kp.Context, productID string, adjustment StockAdjustment) (*StockMovement, error) {
+	return s.repo.AdjustStock(ctx, productID, adjustment)
+}
@@ -0,0 +1,138 @@
+package review
+
+import (
+	"strconv"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-product-service/apperror"
+	"github.com/sing3demons/go-product-service/validation"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// CreateReview handles a verified buyer rating a product
+func (h *Handler) CreateReview(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "create_review",
+		Code:        "200",
+		Description: "",
+	}
+	productID := ctx.PathParam("id")
+	if err := validation.Var("id", productID, "required,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var body CreateRequest
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("create review", ""), map[string]any{
+		"param": map[string]string{
+			"key":   "id",
+			"value": productID,
+		},
+		"body": body,
+	})
+
+	review, err := h.service.CreateReview(ctx, productID, body)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	return ctx.JSON(201, review)
+}
+
+// FindReviews handles listing the reviews of a product that are not hidden
+func (h *Handler) FindReviews(ctx *kp.Context) error {
+	return h.findReviews(ctx, false)
+}
+
+// FindInternalReviews handles listing the reviews of a product for
+// moderation. include_hidden=true also lists the hidden ones.
+func (h *Handler) FindInternalReviews(ctx *kp.Context) error {
+	return h.findReviews(ctx, true)
+}
+
+func (h *Handler) findReviews(ctx *kp.Context, internal bool) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "find_reviews",
+		Code:        "200",
+		Description: "",
+	}
+	productID := ctx.PathParam("id")
+	if err := validation.Var("id", productID, "required,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("limit", ctx.Param("limit"), "omitempty,number"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var includeHidden bool
+	if internal {
+		if err := validation.Var("include_hidden", ctx.Param("include_hidden"), "omitempty,boolean"); err != nil {
+			return validation.Respond(ctx, summary, err)
+		}
+		includeHidden, _ = strconv.ParseBool(ctx.Param("include_hidden"))
+	}
+	limit, _ := strconv.Atoi(ctx.Param("limit"))
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("find reviews", ""), map[string]any{
+		"param": map[string]string{
+			"key":   "id",
+			"value": productID,
+		},
+		"limit":          limit,
+		"include_hidden": includeHidden,
+	})
+
+	reviews, err := h.service.FindReviews(ctx, productID, includeHidden, limit)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	return ctx.JSON(200, map[string]any{
+		"reviews": reviews,
+	})
+}
+
+// SetHidden handles a moderator hiding a review or showing it again
+func (h *Handler) SetHidden(ctx *kp.Context) error {
+	summary := logger.LogEventTag{
+		Node:        "client",
+		Command:     "set_review_hidden",
+		Code:        "200",
+		Description: "",
+	}
+	productID := ctx.PathParam("id")
+	if err := validation.Var("id", productID, "required,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	id := ctx.PathParam("review_id")
+	if err := validation.Var("review_id", id, "required,uuid"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var body HiddenRequest
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound("set review hidden", ""), map[string]any{
+		"param": map[string]string{
+			"id":        productID,
+			"review_id": id,
+		},
+		"body": body,
+	})
+
+	review, err := h.service.SetHidden(ctx, productID, id, body)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	return ctx.JSON(200, review)
+}
@@ -0,0 +1,37 @@
+package review
+
+import "time"
+
+// Review is a customer's rating of a product they received. OrderID is the
+// delivered order that made them a verified buyer. Hidden reviews stay
+// stored but are left out of the product's rating and of public lists.
+type Review struct {
+	ID           string     `json:"id"`
+	ProductID    string     `json:"productId"`
+	CustomerID   string     `json:"customerId"`
+	OrderID      string     `json:"orderId"`
+	Rating       int        `json:"rating"`
+	Title        string     `json:"title,omitempty"`
+	Body         string     `json:"body,omitempty"`
+	HiddenAt     *time.Time `json:"hiddenAt,omitempty"`
+	HiddenReason string     `json:"hiddenReason,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// CreateRequest is the body of POST /products/{id}/reviews. The reviewer
+// is not sent: they are the customer of OrderID, the delivered order of the
+// product whose id only its buyer was given.
+type CreateRequest struct {
+	OrderID string `json:"orderId" validate:"required,uuid"`
+	Rating  int    `json:"rating" validate:"required,min=1,max=5"`
+	Title   string `json:"title,omitempty" validate:"max=200"`
+	Body    string `json:"body,omitempty" validate:"max=5000"`
+}
+
+// HiddenRequest is the body of
+// PUT /internal/products/{id}/reviews/{review_id}/hidden.
+type HiddenRequest struct {
+	Hidden bool   `json:"hidden"`
+	Reason string `json:"reason,omitempty" validate:"max=500"`
+}
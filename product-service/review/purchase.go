@@ -0,0 +1,106 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-product-service/apperror"
+	"github.com/sing3demons/go-product-service/servicetoken"
+)
+
+var errOrderService = apperror.Upstream("order_service_error", "order-service could not confirm the purchase", nil)
+
+// order is the part of GET /internal/orders/{id} on order-service that
+// tells who bought what and whether it arrived.
+type order struct {
+	ID         string `json:"id"`
+	CustomerID string `json:"customer_id"`
+	Status     string `json:"status"`
+	Items      []struct {
+		ID string `json:"id"`
+	} `json:"items"`
+}
+
+// delivered reports whether the order was delivered with the product in it.
+func (o *order) delivered(productID string) bool {
+	if o.Status != "delivered" {
+		return false
+	}
+	for _, item := range o.Items {
+		if item.ID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// findOrder returns the order with id, or nil when there is none.
+func findOrder(ctx *kp.Context, baseURL, id string) (*order, error) {
+	start := time.Now()
+	summary := logger.EventTag("order_service", "find_order", "200", "success")
+
+	uri := baseURL + "/internal/orders/" + url.PathEscape(id)
+	ctx.Log().Info(logger.NewHTTPRequest("find order", ""), map[string]any{
+		"uri":     uri,
+		"method":  http.MethodGet,
+		"timeout": 10 * time.Second,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, errOrderService.Wrap(err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := servicetoken.Authorize(req, servicetoken.OrderService); err != nil {
+		return nil, errOrderService.Wrap(err)
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpClient.Do(req)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("find order error", ""), map[string]any{
+			"error": err.Error(),
+		})
+		return nil, errOrderService.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		summary.Code = "404"
+		summary.Description = "order not found"
+		ctx.Log().SetSummary(summary).Info(logger.NewHTTPResponse("find order success", ""), map[string]any{
+			"Status": resp.Status,
+		})
+		return nil, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var o order
+	if err == nil {
+		err = json.Unmarshal(body, &o)
+	}
+	if err != nil {
+		summary.Code = fmt.Sprintf("%d", resp.StatusCode)
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("find order error", ""), map[string]any{
+			"error": err.Error(),
+		})
+		return nil, errOrderService.Wrap(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewHTTPResponse("find order success", ""), map[string]any{
+		"Status": resp.Status,
+		"status": o.Status,
+	})
+	return &o, nil
+}
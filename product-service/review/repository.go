@@ -0,0 +1,281 @@
+package review
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-product-service/apperror"
+)
+
+// Repository stores reviews in Postgres. Every change to a review also
+// recomputes rating_average and rating_count on the product in the same
+// transaction, with the product row locked so concurrent reviews cannot
+// leave a stale aggregate.
+type Repository interface {
+	Create(ctx *kp.Context, review *Review) error
+	FindByID(ctx *kp.Context, productID, id string) (*Review, error)
+	FindByProduct(ctx *kp.Context, productID string, includeHidden bool, limit int) ([]*Review, error)
+	SetHidden(ctx *kp.Context, productID, id string, hidden bool, reason string) error
+}
+
+type repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) Repository {
+	return &repository{db: db}
+}
+
+var (
+	ErrProductNotFound = apperror.NotFound("product_not_found", "product not found")
+	ErrReviewNotFound  = apperror.NotFound("review_not_found", "review not found")
+	ErrReviewExists    = apperror.Conflict("review_exists", "customerId", "the customer has already reviewed this product")
+)
+
+const reviewColumns = `id, product_id, customer_id, order_id, rating, title, body, hidden_at, hidden_reason, created_at, updated_at`
+
+func scanReview(row interface{ Scan(...any) error }) (*Review, error) {
+	var r Review
+	err := row.Scan(&r.ID, &r.ProductID, &r.CustomerID, &r.OrderID, &r.Rating, &r.Title, &r.Body, &r.HiddenAt, &r.HiddenReason, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (r *repository) Create(ctx *kp.Context, review *Review) error {
+	start := time.Now()
+	summary := logger.EventTag("progress", "insert_review", "200", "success")
+
+	lock := `SELECT id FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	query := `INSERT INTO reviews (product_id, customer_id, order_id, rating, title, body) VALUES ($1, $2, $3, $4, $5, $6) RETURNING ` + reviewColumns
+
+	ctx.Log().Info(logger.NewDBRequest(logger.INSERT, "create review"), map[string]any{
+		"query":  query,
+		"params": []any{review.ProductID, review.CustomerID, review.OrderID, review.Rating, review.Title, review.Body},
+	})
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var id string
+		if err := tx.QueryRowContext(ctx, lock, review.ProductID).Scan(&id); err != nil {
+			return err
+		}
+		created, err := scanReview(tx.QueryRowContext(ctx, query, review.ProductID, review.CustomerID, review.OrderID, review.Rating, review.Title, review.Body))
+		if err != nil {
+			return err
+		}
+		*review = *created
+		return refreshRating(ctx, tx, review.ProductID)
+	})
+
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		switch {
+		case err == sql.ErrNoRows:
+			summary.Code = "404"
+			summary.Description = ErrProductNotFound.Code
+			err = ErrProductNotFound
+		case reviewTaken(err):
+			summary.Code = "409"
+			summary.Description = ErrReviewExists.Code
+			err = ErrReviewExists.Wrap(err)
+		}
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.INSERT, "create review error"), map[string]any{
+			"error": summary.Description,
+		})
+		return apperror.From(err)
+	}
+
+	summary.Code = "201"
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.INSERT, "create review success"), map[string]any{
+		"Return": review,
+	})
+	return nil
+}
+
+func (r *repository) FindByID(ctx *kp.Context, productID, id string) (*Review, error) {
+	start := time.Now()
+	summary := logger.EventTag("progress", "find_review", "200", "success")
+
+	query := `SELECT ` + reviewColumns + ` FROM reviews WHERE id = $1 AND product_id = $2`
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find review"), map[string]any{
+		"query":  query,
+		"params": []any{id, productID},
+	})
+
+	review, err := scanReview(r.db.QueryRowContext(ctx, query, id, productID))
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		if err == sql.ErrNoRows {
+			summary.Code = "404"
+			summary.Description = ErrReviewNotFound.Code
+		}
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find review error"), map[string]any{
+			"error": summary.Description,
+		})
+		if err == sql.ErrNoRows {
+			return nil, ErrReviewNotFound
+		}
+		return nil, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find review success"), map[string]any{
+		"Return": review,
+	})
+	return review, nil
+}
+
+// FindByProduct lists the reviews of a product, newest first. Hidden
+// reviews are only included for moderators.
+func (r *repository) FindByProduct(ctx *kp.Context, productID string, includeHidden bool, limit int) ([]*Review, error) {
+	start := time.Now()
+	summary := logger.EventTag("progress", "find_reviews", "200", "success")
+
+	query := `SELECT ` + reviewColumns + ` FROM reviews WHERE product_id = $1`
+	if !includeHidden {
+		query += ` AND hidden_at IS NULL`
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT $2`
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find reviews"), map[string]any{
+		"query":  query,
+		"params": []any{productID, limit},
+	})
+
+	rows, err := r.db.QueryContext(ctx, query, productID, limit)
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		summary.ResTime = time.Since(start).Milliseconds()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "find reviews error"), map[string]any{
+			"error": err.Error(),
+		})
+		return nil, apperror.Internal(err)
+	}
+	defer rows.Close()
+
+	reviews := []*Review{}
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			summary.Code = "500"
+			summary.Description = err.Error()
+			summary.ResTime = time.Since(start).Milliseconds()
+			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "scan review error"), map[string]any{
+				"error": err.Error(),
+			})
+			return nil, apperror.Internal(err)
+		}
+		reviews = append(reviews, review)
+	}
+
+	summary.ResTime = time.Since(start).Milliseconds()
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find reviews success"), map[string]any{
+		"count": len(reviews),
+	})
+	return reviews, nil
+}
+
+// SetHidden hides a review, with the reason, or shows it again, and
+// updates the rating of its product.
+func (r *repository) SetHidden(ctx *kp.Context, productID, id string, hidden bool, reason string) error {
+	start := time.Now()
+	summary := logger.EventTag("progress", "set_review_hidden", "200", "success")
+
+	lock := `SELECT id FROM products WHERE id = $1 FOR UPDATE`
+	query := `UPDATE reviews SET hidden_at = NULL, hidden_reason = '', updated_at = NOW() WHERE id = $1 AND product_id = $2`
+	params := []any{id, productID}
+	if hidden {
+		query = `UPDATE reviews SET hidden_at = COALESCE(hidden_at, NOW()), hidden_reason = $3, updated_at = NOW() WHERE id = $1 AND product_id = $2`
+		params = append(params, reason)
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "set review hidden"), map[string]any{
+		"query":  query,
+		"params": params,
+	})
+
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var locked string
+		if err := tx.QueryRowContext(ctx, lock, productID).Scan(&locked); err != nil {
+			return err
+		}
+		result, err := tx.ExecContext(ctx, query, params...)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return ErrReviewNotFound
+		}
+		return refreshRating(ctx, tx, productID)
+	})
+
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		switch {
+		case err == sql.ErrNoRows:
+			summary.Code = "404"
+			summary.Description = ErrProductNotFound.Code
+			err = ErrProductNotFound
+		case errors.Is(err, ErrReviewNotFound):
+			summary.Code = "404"
+			summary.Description = ErrReviewNotFound.Code
+		}
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, "set review hidden error"), map[string]any{
+			"error": summary.Description,
+		})
+		return apperror.From(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "set review hidden success"), map[string]any{
+		"id":     id,
+		"hidden": hidden,
+	})
+	return nil
+}
+
+// refreshRating recomputes the rating of a product from its visible
+// reviews. updated_at moves with it, the rating being part of the
+// product's ETag.
+func refreshRating(ctx *kp.Context, tx *sql.Tx, productID string) error {
+	query := `
+	UPDATE products SET
+		rating_count = r.count,
+		rating_average = r.average,
+		updated_at = NOW()
+	FROM (
+		SELECT COUNT(*) AS count, COALESCE(ROUND(AVG(rating), 2), 0) AS average
+		FROM reviews
+		WHERE product_id = $1 AND hidden_at IS NULL
+	) AS r
+	WHERE products.id = $1`
+	_, err := tx.ExecContext(ctx, query, productID)
+	return err
+}
+
+// reviewTaken reports whether err is a violation of the one review per
+// customer index.
+func reviewTaken(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "unique_review_per_customer"
+}
+
+// withTx runs fn in a transaction, committing when it returns nil.
+func (r *repository) withTx(ctx *kp.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,24 @@
+package review
+
+import (
+	"database/sql"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-product-service/servicetoken"
+)
+
+// RegisterRoutes registers the review routes. Moderation, hiding reviews
+// and listing the hidden ones, takes an admin token from the "token"
+// subcommand, see servicetoken.
+func RegisterRoutes(app kp.IApplication, db *sql.DB, conf *config.Config, guard *servicetoken.Guard) {
+	repo := NewRepository(db)
+	service := NewService(repo, conf)
+	handler := NewHandler(service)
+
+	app.Post("/products/{id}/reviews", handler.CreateReview)
+	app.Get("/products/{id}/reviews", handler.FindReviews)
+	admin := guard.Admin()
+	app.Get("/internal/products/{id}/reviews", admin.Require(handler.FindInternalReviews))
+	app.Put("/internal/products/{id}/reviews/{review_id}/hidden", admin.Require(handler.SetHidden))
+}
@@ -0,0 +1,78 @@
+package review
+
+import (
+	"strings"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-product-service/apperror"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+var ErrNotVerified = apperror.Forbidden("purchase_not_verified", "only customers with a delivered order of the product can review it")
+
+type Service interface {
+	CreateReview(ctx *kp.Context, productID string, req CreateRequest) (*Review, error)
+	FindReviews(ctx *kp.Context, productID string, includeHidden bool, limit int) ([]*Review, error)
+	SetHidden(ctx *kp.Context, productID, id string, req HiddenRequest) (*Review, error)
+}
+
+type service struct {
+	repo            Repository
+	orderServiceURL string
+}
+
+// NewService checks purchases against the order-service at
+// ORDER_SERVICE_URL.
+func NewService(repo Repository, conf *config.Config) Service {
+	return &service{
+		repo:            repo,
+		orderServiceURL: strings.TrimSuffix(conf.GetOrDefault("ORDER_SERVICE_URL", "http://localhost:8083"), "/"),
+	}
+}
+
+// CreateReview records the review of the customer of a delivered order of
+// the product.
+func (s *service) CreateReview(ctx *kp.Context, productID string, req CreateRequest) (*Review, error) {
+	order, err := findOrder(ctx, s.orderServiceURL, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil || !order.delivered(productID) {
+		return nil, ErrNotVerified
+	}
+
+	review := &Review{
+		ProductID:  productID,
+		CustomerID: order.CustomerID,
+		OrderID:    order.ID,
+		Rating:     req.Rating,
+		Title:      req.Title,
+		Body:       req.Body,
+	}
+	if err := s.repo.Create(ctx, review); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func (s *service) FindReviews(ctx *kp.Context, productID string, includeHidden bool, limit int) ([]*Review, error) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return s.repo.FindByProduct(ctx, productID, includeHidden, limit)
+}
+
+func (s *service) SetHidden(ctx *kp.Context, productID, id string, req HiddenRequest) (*Review, error) {
+	if err := s.repo.SetHidden(ctx, productID, id, req.Hidden, req.Reason); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, productID, id)
+}
@@ -0,0 +1,67 @@
+package servicetoken
+
+import (
+	"strings"
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-product-service/apperror"
+)
+
+var ErrUnauthenticated = apperror.Unauthorized("service_unauthenticated", "this route is internal and needs a valid service token")
+
+// Guard protects the internal routes, which only other services may call.
+type Guard struct {
+	secret   string
+	audience string
+	trusted  []string
+}
+
+// NewGuard accepts tokens issued for this service's APP_NAME by the
+// services listed in SERVICE_TOKEN_TRUSTED. Without SERVICE_TOKEN_SECRET
+// every call is refused.
+func NewGuard(conf *config.Config) *Guard {
+	var trusted []string
+	for _, name := range strings.Split(conf.GetOrDefault("SERVICE_TOKEN_TRUSTED", "order-service,user-service"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			trusted = append(trusted, name)
+		}
+	}
+	return &Guard{
+		secret:   conf.Get("SERVICE_TOKEN_SECRET"),
+		audience: conf.GetOrDefault("APP_NAME", "product-service"),
+		trusted:  trusted,
+	}
+}
+
+// Admin returns a guard for the routes only operators call. It accepts
+// tokens issued by Admin and by no service.
+func (g *Guard) Admin() *Guard {
+	return &Guard{secret: g.secret, audience: g.audience, trusted: []string{Admin}}
+}
+
+// Require runs next only for a caller with a valid service token.
+func (g *Guard) Require(next kp.Handler) kp.Handler {
+	return func(ctx *kp.Context) error {
+		var header string
+		if r, ok := ctx.Request.(interface{ Header(string) string }); ok {
+			header = r.Header(Header)
+		}
+		claims, err := Verify(g.secret, header, g.audience, g.trusted, time.Now())
+		if err != nil {
+			summary := logger.EventTag("client", "service_auth", "401", err.Error())
+			ctx.Log().SetSummary(summary).Error(logger.NewInbound("service auth", ""), map[string]string{
+				"url":   ctx.URL(),
+				"error": err.Error(),
+			})
+			ctx.Header().Set("x-rid", ctx.RequestId())
+			return apperror.Write(ctx, ErrUnauthenticated)
+		}
+		ctx.Log().Info(logger.NewInbound("service auth", ""), map[string]string{
+			"issuer": claims.Issuer,
+		})
+		return next(ctx)
+	}
+}
@@ -0,0 +1,152 @@
+package address
+
+import (
+	"net/http"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-user-service/apperror"
+	"github.com/sing3demons/go-user-service/validation"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler {
+	return &Handler{
+		svc: svc,
+	}
+}
+
+func (h *Handler) ListAddresses(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	summary := logger.EventTag("client", "list_addresses", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID},
+	})
+
+	addresses, err := h.svc.ListAddresses(ctx, userID)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	for _, address := range addresses {
+		h.setHref(ctx, address)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"addresses": addresses,
+		"count":     len(addresses),
+	})
+}
+
+func (h *Handler) GetAddress(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	id := ctx.PathParam("address_id")
+	summary := logger.EventTag("client", "get_address", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("address_id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID, "address_id": id},
+	})
+
+	address, err := h.svc.GetAddress(ctx, userID, id)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	h.setHref(ctx, address)
+	return ctx.JSON(http.StatusOK, address)
+}
+
+func (h *Handler) CreateAddress(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	summary := logger.EventTag("client", "create_address", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var body AddressRequest
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID},
+		"Body":  body,
+	}, maskingOptions("Body.")...)
+
+	address, err := h.svc.CreateAddress(ctx, userID, body)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	h.setHref(ctx, address)
+	return ctx.JSON(http.StatusCreated, address)
+}
+
+func (h *Handler) UpdateAddress(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	id := ctx.PathParam("address_id")
+	summary := logger.EventTag("client", "update_address", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("address_id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var body AddressRequest
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID, "address_id": id},
+		"Body":  body,
+	}, maskingOptions("Body.")...)
+
+	address, err := h.svc.UpdateAddress(ctx, userID, id, body)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	h.setHref(ctx, address)
+	return ctx.JSON(http.StatusOK, address)
+}
+
+func (h *Handler) DeleteAddress(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	id := ctx.PathParam("address_id")
+	summary := logger.EventTag("client", "delete_address", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("address_id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID, "address_id": id},
+	})
+
+	if err := h.svc.DeleteAddress(ctx, userID, id); err != nil {
+		return apperror.Write(ctx, err)
+	}
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	return ctx.JSON(http.StatusOK, map[string]string{
+		"message": "delete_success",
+		"id":      id,
+	})
+}
+
+func (h *Handler) setHref(ctx *kp.Context, address *Address) {
+	address.Href = ctx.HostName() + "/users/" + address.UserID + "/addresses/" + address.ID
+}
@@ -0,0 +1,55 @@
+package address
+
+// Address is one entry of a user's address book. At most one address of a
+// user is the default for shipping and one for billing.
+type Address struct {
+	ID              string `json:"id" bson:"_id"`
+	Href            string `json:"href,omitempty" bson:"-"`
+	UserID          string `json:"user_id" bson:"user_id"`
+	Label           string `json:"label,omitempty" bson:"label,omitempty"` // e.g. home or office
+	Name            string `json:"name" bson:"name"`                       // Who receives the parcel
+	Phone           string `json:"phone,omitempty" bson:"phone,omitempty"`
+	Line1           string `json:"line1" bson:"line1"`
+	Line2           string `json:"line2,omitempty" bson:"line2,omitempty"`
+	City            string `json:"city" bson:"city"`
+	State           string `json:"state,omitempty" bson:"state,omitempty"`
+	PostalCode      string `json:"postal_code,omitempty" bson:"postal_code,omitempty"`
+	Country         string `json:"country" bson:"country"` // ISO 3166-1 alpha-2
+	DefaultShipping bool   `json:"default_shipping" bson:"default_shipping"`
+	DefaultBilling  bool   `json:"default_billing" bson:"default_billing"`
+	CreatedAt       string `json:"created_at" bson:"created_at"`
+	UpdatedAt       string `json:"updated_at" bson:"updated_at"`
+}
+
+// AddressRequest is the body of POST /users/{id}/addresses and of
+// PUT /users/{id}/addresses/{address_id}, which replaces the address.
+// Setting a default moves it from the address that had it.
+type AddressRequest struct {
+	Label           string `json:"label,omitempty" validate:"max=50"`
+	Name            string `json:"name" validate:"required,max=200"`
+	Phone           string `json:"phone,omitempty" validate:"omitempty,e164"`
+	Line1           string `json:"line1" validate:"required,max=200"`
+	Line2           string `json:"line2,omitempty" validate:"max=200"`
+	City            string `json:"city" validate:"required,max=100"`
+	State           string `json:"state,omitempty" validate:"max=100"`
+	PostalCode      string `json:"postal_code,omitempty" validate:"omitempty,max=20,postcode_iso3166_alpha2_field=Country"`
+	Country         string `json:"country" validate:"required,iso3166_1_alpha2"`
+	DefaultShipping bool   `json:"default_shipping"`
+	DefaultBilling  bool   `json:"default_billing"`
+}
+
+func (req AddressRequest) address() Address {
+	return Address{
+		Label:           req.Label,
+		Name:            req.Name,
+		Phone:           req.Phone,
+		Line1:           req.Line1,
+		Line2:           req.Line2,
+		City:            req.City,
+		State:           req.State,
+		PostalCode:      req.PostalCode,
+		Country:         req.Country,
+		DefaultShipping: req.DefaultShipping,
+		DefaultBilling:  req.DefaultBilling,
+	}
+}
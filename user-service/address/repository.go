@@ -0,0 +1,253 @@
+package address
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-user-service/apperror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository stores addresses in the addresses collection. Partial unique
+// indexes keep a single default shipping and billing address per user.
+type Repository interface {
+	Create(ctx *kp.Context, address *Address) error
+	FindByUser(ctx *kp.Context, userID string) ([]*Address, error)
+	FindByID(ctx *kp.Context, userID, id string) (*Address, error)
+	Replace(ctx *kp.Context, address *Address) error
+	Delete(ctx *kp.Context, userID, id string) error
+	Count(ctx *kp.Context, userID string) (int64, error)
+	ClearDefault(ctx *kp.Context, userID, field, exceptID string) error
+	DeleteByUser(ctx *kp.Context, userID string) (int64, error)
+}
+
+type repository struct {
+	col *mongo.Collection
+}
+
+func NewRepository(col *mongo.Collection) Repository {
+	return &repository{
+		col: col,
+	}
+}
+
+var (
+	ErrAddressNotFound = apperror.NotFound("address_not_found", "address not found")
+	ErrDefaultConflict = apperror.Conflict("address_default_conflict", "default", "another request changed the default address at the same time; try again")
+)
+
+// maskingOptions hides the personal parts of the addresses logged under
+// prefix.
+func maskingOptions(prefix string) []logger.MaskingOptionDto {
+	return []logger.MaskingOptionDto{
+		{MaskingField: prefix + "name", MaskingType: logger.Full},
+		{MaskingField: prefix + "phone", MaskingType: logger.Msisdn},
+		{MaskingField: prefix + "line1", MaskingType: logger.Full},
+		{MaskingField: prefix + "line2", MaskingType: logger.Full},
+	}
+}
+
+// fail logs a failed operation and returns the error to give the caller:
+// ErrAddressNotFound for no document, ErrDefaultConflict for a second
+// default and an internal error otherwise.
+func fail(ctx *kp.Context, summary logger.LogEventTag, op logger.DBActionEnum, err error) error {
+	result := apperror.Internal(err)
+	summary.Code = "500"
+	summary.Description = err.Error()
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		result = ErrAddressNotFound
+		summary.Code = "404"
+		summary.Description = ErrAddressNotFound.Code
+	case mongo.IsDuplicateKeyError(err):
+		result = ErrDefaultConflict.Wrap(err)
+		summary.Code = "409"
+		summary.Description = ErrDefaultConflict.Code
+	}
+	ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(op, summary.Command+" failed"), map[string]any{
+		"Error": err.Error(),
+	})
+	return result
+}
+
+func (r *repository) Create(ctx *kp.Context, address *Address) error {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "insert_address", "200", "success")
+	ctx.Log().Info(logger.NewDBRequest(logger.INSERT, "insert address"), map[string]any{
+		"collection": r.col.Name(),
+		"document":   address,
+	}, maskingOptions("document.")...)
+
+	_, err := r.col.InsertOne(ctx, address)
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return fail(ctx, summary, logger.INSERT, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.INSERT, "insert address success"), map[string]any{
+		"id": address.ID,
+	})
+	return nil
+}
+
+func (r *repository) FindByUser(ctx *kp.Context, userID string) ([]*Address, error) {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "find_addresses", "200", "success")
+	filter := bson.M{"user_id": userID}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find addresses"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	cursor, err := r.col.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		summary.ResTime = time.Since(start).Microseconds()
+		return nil, fail(ctx, summary, logger.QUERY, err)
+	}
+	addresses := []*Address{}
+	err = cursor.All(ctx, &addresses)
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return nil, fail(ctx, summary, logger.QUERY, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find addresses success"), map[string]any{
+		"count": len(addresses),
+	})
+	return addresses, nil
+}
+
+func (r *repository) FindByID(ctx *kp.Context, userID, id string) (*Address, error) {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "find_address", "200", "success")
+	filter := bson.M{"_id": id, "user_id": userID}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find address"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	var address Address
+	err := r.col.FindOne(ctx, filter).Decode(&address)
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return nil, fail(ctx, summary, logger.QUERY, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find address success"), map[string]any{
+		"Return": address,
+	}, maskingOptions("Return.")...)
+	return &address, nil
+}
+
+func (r *repository) Replace(ctx *kp.Context, address *Address) error {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "replace_address", "200", "success")
+	filter := bson.M{"_id": address.ID, "user_id": address.UserID}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "replace address"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"document":   address,
+	}, maskingOptions("document.")...)
+
+	result, err := r.col.ReplaceOne(ctx, filter, address)
+	if err == nil && result.MatchedCount == 0 {
+		err = mongo.ErrNoDocuments
+	}
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return fail(ctx, summary, logger.UPDATE, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "replace address success"), map[string]any{
+		"id": address.ID,
+	})
+	return nil
+}
+
+func (r *repository) Delete(ctx *kp.Context, userID, id string) error {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "delete_address", "200", "success")
+	filter := bson.M{"_id": id, "user_id": userID}
+	ctx.Log().Info(logger.NewDBRequest(logger.DELETE, "delete address"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	result, err := r.col.DeleteOne(ctx, filter)
+	if err == nil && result.DeletedCount == 0 {
+		err = mongo.ErrNoDocuments
+	}
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return fail(ctx, summary, logger.DELETE, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.DELETE, "delete address success"), map[string]any{
+		"id": id,
+	})
+	return nil
+}
+
+func (r *repository) Count(ctx *kp.Context, userID string) (int64, error) {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "count_addresses", "200", "success")
+	filter := bson.M{"user_id": userID}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "count addresses"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	n, err := r.col.CountDocuments(ctx, filter)
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return 0, fail(ctx, summary, logger.QUERY, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "count addresses success"), map[string]any{
+		"count": n,
+	})
+	return n, nil
+}
+
+// ClearDefault unsets field, default_shipping or default_billing, on every
+// address of the user but exceptID, before exceptID takes it over.
+func (r *repository) ClearDefault(ctx *kp.Context, userID, field, exceptID string) error {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "clear_default_address", "200", "success")
+	filter := bson.M{"user_id": userID, field: true, "_id": bson.M{"$ne": exceptID}}
+	update := bson.M{"$set": bson.M{field: false, "updated_at": start.UTC().Format(time.RFC3339)}}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, "clear default address"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"update":     update,
+	})
+
+	result, err := r.col.UpdateMany(ctx, filter, update)
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return fail(ctx, summary, logger.UPDATE, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, "clear default address success"), map[string]any{
+		"modified": result.ModifiedCount,
+	})
+	return nil
+}
+
+// DeleteByUser removes the whole address book of a user.
+func (r *repository) DeleteByUser(ctx *kp.Context, userID string) (int64, error) {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "delete_addresses", "200", "success")
+	filter := bson.M{"user_id": userID}
+	ctx.Log().Info(logger.NewDBRequest(logger.DELETE, "delete addresses"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	result, err := r.col.DeleteMany(ctx, filter)
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return 0, fail(ctx, summary, logger.DELETE, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.DELETE, "delete addresses success"), map[string]any{
+		"deleted": result.DeletedCount,
+	})
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,21 @@
+package address
+
+import (
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-user-service/servicetoken"
+)
+
+// RegisterRoutes registers the address book routes. They must come before
+// the /users/{key}/{value} lookup, which would match them otherwise. The
+// internal lookup is what order-service snapshots onto orders.
+func RegisterRoutes(app kp.IApplication, repo Repository, users UserLookup, guard *servicetoken.Guard) {
+	svc := NewService(repo, users)
+	handler := NewHandler(svc)
+
+	app.Get("/users/{id}/addresses", handler.ListAddresses)
+	app.Post("/users/{id}/addresses", handler.CreateAddress)
+	app.Get("/users/{id}/addresses/{address_id}", handler.GetAddress)
+	app.Put("/users/{id}/addresses/{address_id}", handler.UpdateAddress)
+	app.Delete("/users/{id}/addresses/{address_id}", handler.DeleteAddress)
+	app.Get("/internal/users/{id}/addresses/{address_id}", guard.Require(handler.GetAddress))
+}
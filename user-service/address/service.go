@@ -0,0 +1,136 @@
+package address
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-user-service/apperror"
+)
+
+// MaxAddresses is how many addresses one user may keep.
+const MaxAddresses = 20
+
+type Service interface {
+	ListAddresses(ctx *kp.Context, userID string) ([]*Address, error)
+	GetAddress(ctx *kp.Context, userID, id string) (*Address, error)
+	CreateAddress(ctx *kp.Context, userID string, req AddressRequest) (*Address, error)
+	UpdateAddress(ctx *kp.Context, userID, id string, req AddressRequest) (*Address, error)
+	DeleteAddress(ctx *kp.Context, userID, id string) error
+}
+
+// UserLookup fails when userID is not a live user.
+type UserLookup func(ctx *kp.Context, userID string) error
+
+type service struct {
+	repo  Repository
+	users UserLookup
+}
+
+func NewService(repo Repository, users UserLookup) Service {
+	return &service{
+		repo:  repo,
+		users: users,
+	}
+}
+
+var ErrAddressBookFull = apperror.Conflict("address_book_full", "addresses", "a user can keep at most 20 addresses")
+
+func (s *service) ListAddresses(ctx *kp.Context, userID string) ([]*Address, error) {
+	if err := s.users(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByUser(ctx, userID)
+}
+
+func (s *service) GetAddress(ctx *kp.Context, userID, id string) (*Address, error) {
+	if err := s.users(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, userID, id)
+}
+
+// CreateAddress adds an address to the book. The first address of a user
+// is the default for both shipping and billing.
+func (s *service) CreateAddress(ctx *kp.Context, userID string, req AddressRequest) (*Address, error) {
+	if err := s.users(ctx, userID); err != nil {
+		return nil, err
+	}
+	n, err := s.repo.Count(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n >= MaxAddresses {
+		return nil, ErrAddressBookFull
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	address := req.address()
+	address.ID = id.String()
+	address.UserID = userID
+	address.CreatedAt = now
+	address.UpdatedAt = now
+	if n == 0 {
+		address.DefaultShipping = true
+		address.DefaultBilling = true
+	}
+
+	if err := s.takeDefaults(ctx, &address); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// UpdateAddress replaces an address, defaults included.
+func (s *service) UpdateAddress(ctx *kp.Context, userID, id string, req AddressRequest) (*Address, error) {
+	current, err := s.GetAddress(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	address := req.address()
+	address.ID = current.ID
+	address.UserID = current.UserID
+	address.CreatedAt = current.CreatedAt
+	address.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	if err := s.takeDefaults(ctx, &address); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Replace(ctx, &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// DeleteAddress removes an address. A default that is removed is not
+// handed to another address; the user picks the next one.
+func (s *service) DeleteAddress(ctx *kp.Context, userID, id string) error {
+	if err := s.users(ctx, userID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, userID, id)
+}
+
+// takeDefaults moves the defaults address claims away from the other
+// addresses of the user.
+func (s *service) takeDefaults(ctx *kp.Context, address *Address) error {
+	if address.DefaultShipping {
+		if err := s.repo.ClearDefault(ctx, address.UserID, "default_shipping", address.ID); err != nil {
+			return err
+		}
+	}
+	if address.DefaultBilling {
+		if err := s.repo.ClearDefault(ctx, address.UserID, "default_billing", address.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package apperror
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Kind classifies an Error and decides its HTTP status.
+type Kind string
+
+const (
+	KindValidation   Kind = "validation"
+	KindNotFound     Kind = "not_found"
+	KindConflict     Kind = "conflict"
+	KindUnauthorized Kind = "unauthorized"
+	KindTooLarge     Kind = "too_large"
+	KindUpstream     Kind = "upstream"
+	KindInternal     Kind = "internal"
+)
+
+// FieldError describes one rule a request field failed. Field uses the
+// JSON path of the field, e.g. "items[0].price".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is the domain error returned by repositories and services. Code is
+// a stable, machine readable identifier such as "user_not_found"; two
+// errors with the same code match under errors.Is.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Field   string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Err.Error()
+	}
+	return e.Code
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Status returns the HTTP status code for the error kind.
+func (e *Error) Status() int {
+	switch e.Kind {
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case KindUpstream:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Wrap returns a copy of e carrying err as its cause.
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.Err = err
+	return &c
+}
+
+func NotFound(code, message string) *Error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+// Conflict reports that field clashes with an existing record.
+func Conflict(code, field, message string) *Error {
+	return &Error{Kind: KindConflict, Code: code, Field: field, Message: message}
+}
+
+func Validation(code, message string) *Error {
+	return &Error{Kind: KindValidation, Code: code, Message: message}
+}
+
+// Invalid is the validation error for a request whose fields broke rules.
+func Invalid(fields ...FieldError) *Error {
+	return &Error{
+		Kind:    KindValidation,
+		Code:    "invalid_request",
+		Message: "request validation failed",
+		Fields:  fields,
+	}
+}
+
+// Unauthorized reports a request whose credentials were missing or wrong.
+func Unauthorized(code, message string) *Error {
+	return &Error{Kind: KindUnauthorized, Code: code, Message: message}
+}
+
+func TooLarge(code, message string) *Error {
+	return &Error{Kind: KindTooLarge, Code: code, Message: message}
+}
+
+// Upstream reports a failed call to another service.
+func Upstream(code, message string, err error) *Error {
+	return &Error{Kind: KindUpstream, Code: code, Message: message, Err: err}
+}
+
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Code: "internal_error", Message: "unexpected error", Err: err}
+}
+
+// From returns err as an *Error, treating anything unknown as internal.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err)
+}
@@ -0,0 +1,82 @@
+package httpcache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+)
+
+// ETag returns a weak entity tag for the representation described by
+// parts, typically the id, the updated_at and the fieldset.
+func ETag(parts ...string) string {
+	sum := sha1.Sum([]byte(strings.Join(parts, "\x00")))
+	return `W/"` + hex.EncodeToString(sum[:10]) + `"`
+}
+
+// NotModified sets ETag and Last-Modified on the response and reports
+// whether the request's If-None-Match, or failing that If-Modified-Since,
+// already matches them. When it does the 304 has been written and the
+// handler must return without a body. A zero modified time leaves
+// Last-Modified out.
+func NotModified(ctx *kp.Context, etag string, modified time.Time) bool {
+	if ctx.ResponseWriter == nil {
+		return false
+	}
+
+	header := ctx.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "no-cache")
+	if !modified.IsZero() {
+		header.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if !matches(requestHeader(ctx), etag, modified) {
+		return false
+	}
+
+	header.Set("x-rid", ctx.RequestId())
+	ctx.WriteHeader(http.StatusNotModified)
+	ctx.Log().Info(logger.NewOutbound("client", ""), map[string]any{
+		"Status": http.StatusNotModified,
+		"ETag":   etag,
+	})
+	ctx.Log().End(http.StatusNotModified, "")
+	return true
+}
+
+func matches(header func(string) string, etag string, modified time.Time) bool {
+	if inm := header("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || weak(tag) == weak(etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := header("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		since, err := http.ParseTime(ims)
+		return err == nil && !modified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// requestHeader reads the request headers. kp.Request does not list a
+// header accessor, but its HTTP implementation has one.
+func requestHeader(ctx *kp.Context) func(string) string {
+	if r, ok := ctx.Request.(interface{ Header(string) string }); ok {
+		return r.Header
+	}
+	return func(string) string { return "" }
+}
+
+// weak strips the weak marker; If-None-Match uses the weak comparison.
+func weak(tag string) string {
+	return strings.TrimPrefix(tag, "W/")
+}
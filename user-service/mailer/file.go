@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer writes every email to dir as an .eml file instead of
+// sending it, for local development.
+func NewFileMailer(dir, from string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileMailer{dir: dir, from: from}, nil
+}
+
+func (m *fileMailer) Name() string {
+	return "file"
+}
+
+func (m *fileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := format(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.dir, id.String()+".eml"), data, 0o600)
+}
+
+type logMailer struct {
+	from string
+}
+
+// NewLogMailer prints every email to the process log instead of sending
+// it. It is the default, so a fresh checkout needs no mail server.
+func NewLogMailer(from string) Mailer {
+	return &logMailer{from: from}
+}
+
+func (m *logMailer) Name() string {
+	return "log"
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	data, err := format(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	log.Printf("mail to %s:\n%s", msg.To, data)
+	return nil
+}
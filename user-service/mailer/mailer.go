@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+)
+
+// Message is a plain text email to a single recipient.
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Mailer delivers the emails user-service sends, such as the address
+// verification and password reset links.
+type Mailer interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewMailer picks the mailer from MAIL_DRIVER: "smtp" for a real server,
+// "file" to write each email to MAIL_DIR or "log" to print it.
+func NewMailer(conf *config.Config) (Mailer, error) {
+	from := conf.GetOrDefault("MAIL_FROM", "no-reply@localhost")
+	switch conf.GetOrDefault("MAIL_DRIVER", "log") {
+	case "smtp":
+		return NewSMTPMailer(SMTPConfig{
+			Host:     conf.Get("SMTP_HOST"),
+			Port:     conf.GetOrDefault("SMTP_PORT", "587"),
+			Username: conf.Get("SMTP_USERNAME"),
+			Password: conf.Get("SMTP_PASSWORD"),
+			From:     from,
+		})
+	case "file":
+		return NewFileMailer(conf.GetOrDefault("MAIL_DIR", "./data/mail"), from)
+	case "log":
+		return NewLogMailer(from), nil
+	default:
+		return nil, errors.New("unsupported MAIL_DRIVER: " + conf.Get("MAIL_DRIVER"))
+	}
+}
+
+// format renders msg as an RFC 5322 message. Line breaks in the recipient
+// or subject are refused; they would let the caller add headers.
+func format(from string, msg Message, at time.Time) ([]byte, error) {
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errors.New("mailer: line break in recipient or subject")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", at.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,55 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string // Leave empty for a server that takes mail without auth
+	Password string
+	From     string
+}
+
+type smtpMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+func NewSMTPMailer(cfg SMTPConfig) (Mailer, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("SMTP_HOST is required for MAIL_DRIVER=smtp")
+	}
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return &smtpMailer{
+		addr: net.JoinHostPort(cfg.Host, cfg.Port),
+		auth: auth,
+		from: cfg.From,
+	}, nil
+}
+
+func (m *smtpMailer) Name() string {
+	return "smtp"
+}
+
+// Send hands msg to the server, upgrading to TLS when it offers STARTTLS.
+// net/smtp takes no context, so ctx is only checked before dialing.
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := format(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, data)
+}
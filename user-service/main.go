@@ -3,12 +3,20 @@ package main
 import (
 	"context"
 	"fmt"
+	"log"
 	"os"
 
 	config "github.com/sing3demons/go-common-kp/kp/configs"
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
 
+	"github.com/sing3demons/go-user-service/address"
+	"github.com/sing3demons/go-user-service/mailer"
+	"github.com/sing3demons/go-user-service/media"
+	"github.com/sing3demons/go-user-service/migration"
+	"github.com/sing3demons/go-user-service/outbox"
+	"github.com/sing3demons/go-user-service/servicetoken"
 	"github.com/sing3demons/go-user-service/user"
+	"github.com/sing3demons/go-user-service/wishlist"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -40,14 +48,72 @@ func main() {
 	}
 	conf.LoadEnv(path)
 
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		if err := runToken(conf, os.Args[2:]); err != nil {
+			log.Fatalf("token: %v", err)
+		}
+		return
+	}
+
 	mongoDB := ConnectMongo(conf)
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(mongoDB, os.Args[2:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	if err := migration.New(mongoDB).Check(context.Background()); err != nil {
+		panic(fmt.Sprintf("Database schema is not up to date: %v", err))
+	}
+
 	app := kp.NewApplication(conf)
-	// app.StartKafka()
+	app.StartKafka()
+	app.CreateTopic("user_created")
+	app.CreateTopic("user_updated")
+	app.CreateTopic("user_deleted")
+	app.CreateTopic("user_erased")
 
 	app.Get("/healthz", func(ctx *kp.Context) error {
 		return ctx.JSON(200, "OK")
 	})
 
-	user.RegisterRoutes(app, mongoDB.Collection("users"))
+	storage, err := media.NewStorage(conf)
+	if err != nil {
+		panic(err)
+	}
+	mediaSvc := media.NewService(storage, conf)
+
+	media.RegisterRoutes(app, mediaSvc)
+	mail, err := mailer.NewMailer(conf)
+	if err != nil {
+		panic(err)
+	}
+	accounts, err := user.NewAccountOptions(conf, mail)
+	if err != nil {
+		panic(err)
+	}
+
+	user.RegisterRoutes(app, mongoDB.Collection("users"), mediaSvc,
+		address.NewRepository(mongoDB.Collection("addresses")), wishlist.NewRepository(mongoDB.Collection("wishlists")), accounts,
+		servicetoken.NewGuard(conf))
+
+	if conf.GetOrDefault("OUTBOX_ENABLED", "true") == "true" {
+		publisher, err := outbox.NewKafkaPublisher(conf)
+		if err != nil {
+			panic(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		outbox.NewRelay(mongoDB.Collection("users"), publisher, conf).Start(ctx)
+	}
+
+	if conf.GetOrDefault("PURGE_ENABLED", "true") == "true" {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		user.NewPurger(mongoDB.Collection("users"), mongoDB.Collection("addresses"), mongoDB.Collection("wishlists"), storage, conf).Start(ctx)
+	}
+
 	app.Start()
 }
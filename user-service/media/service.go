@@ -0,0 +1,267 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+)
+
+type Service interface {
+	Upload(ctx *kp.Context, prefix string, file *multipart.FileHeader) (*Media, error)
+	Open(ctx *kp.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Delete(ctx *kp.Context, key string) error
+	DeletePrefix(ctx *kp.Context, prefix string) error
+	URL(ctx *kp.Context, key string) string
+	KeyOf(url string) string
+}
+
+type service struct {
+	storage       Storage
+	maxSize       int64
+	maxPixels     int64
+	thumbnailSize int
+	baseURL       string
+}
+
+func NewService(storage Storage, conf *config.Config) Service {
+	maxSize, err := strconv.ParseInt(conf.GetOrDefault("MEDIA_MAX_SIZE", "5242880"), 10, 64)
+	if err != nil || maxSize <= 0 {
+		maxSize = 5 << 20
+	}
+	maxPixels, err := strconv.ParseInt(conf.GetOrDefault("MEDIA_MAX_PIXELS", "40000000"), 10, 64)
+	if err != nil || maxPixels <= 0 {
+		maxPixels = 40_000_000
+	}
+	thumbnailSize, err := strconv.Atoi(conf.GetOrDefault("MEDIA_THUMBNAIL_SIZE", "200"))
+	if err != nil || thumbnailSize <= 0 {
+		thumbnailSize = 200
+	}
+
+	return &service{
+		storage:       storage,
+		maxSize:       maxSize,
+		maxPixels:     maxPixels,
+		thumbnailSize: thumbnailSize,
+		baseURL:       strings.TrimSuffix(conf.Get("MEDIA_BASE_URL"), "/"),
+	}
+}
+
+// URL returns the public address of key. Without MEDIA_BASE_URL the object is
+// served back through GET /media/{key} on this service.
+func (s *service) URL(ctx *kp.Context, key string) string {
+	if s.baseURL != "" {
+		return s.baseURL + "/" + key
+	}
+	return ctx.HostName() + "/media/" + key
+}
+
+// KeyOf returns the key of an object from the URL that URL gave for it, or
+// "" when url does not point into this storage.
+func (s *service) KeyOf(url string) string {
+	if s.baseURL != "" {
+		if key, ok := strings.CutPrefix(url, s.baseURL+"/"); ok {
+			return key
+		}
+		return ""
+	}
+	if _, key, ok := strings.Cut(url, "/media/"); ok {
+		return key
+	}
+	return ""
+}
+
+func (s *service) Upload(ctx *kp.Context, prefix string, file *multipart.FileHeader) (*Media, error) {
+	if file == nil || file.Size == 0 {
+		return nil, ErrEmptyFile
+	}
+	if file.Size > s.maxSize {
+		return nil, ErrFileTooLarge
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, s.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, err := detectContentType(data, s.maxSize)
+	if err != nil {
+		return nil, err
+	}
+	img, err := decodeImage(data, contentType, s.maxPixels)
+	if err != nil {
+		return nil, err
+	}
+	thumb, thumbType, err := thumbnail(img, contentType, s.thumbnailSize)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.Trim(prefix, "/")
+	key := prefix + "/" + id.String() + allowedContentTypes[contentType]
+	thumbKey := prefix + "/" + id.String() + "_thumb" + allowedContentTypes[thumbType]
+
+	if err := s.put(ctx, key, data, contentType); err != nil {
+		return nil, err
+	}
+	if err := s.put(ctx, thumbKey, thumb, thumbType); err != nil {
+		s.storage.Delete(ctx, key)
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	return &Media{
+		Key:          key,
+		URL:          s.URL(ctx, key),
+		ThumbnailKey: thumbKey,
+		ThumbnailURL: s.URL(ctx, thumbKey),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Width:        bounds.Dx(),
+		Height:       bounds.Dy(),
+		CreatedAt:    time.Now(),
+	}, nil
+}
+
+func (s *service) put(ctx *kp.Context, key string, data []byte, contentType string) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        s.storage.Name(),
+		Command:     "put_object",
+		Code:        "200",
+		Description: "success",
+	}
+
+	ctx.Log().Info(logger.NewDBRequest(logger.INSERT, "put object"), map[string]any{
+		"key":          key,
+		"content_type": contentType,
+		"size":         len(data),
+	})
+
+	err := s.storage.Put(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.INSERT, "put object error"), map[string]any{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.INSERT, "put object success"), map[string]any{
+		"key": key,
+	})
+	return nil
+}
+
+func (s *service) Open(ctx *kp.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        s.storage.Name(),
+		Command:     "get_object",
+		Code:        "200",
+		Description: "success",
+	}
+
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "get object"), map[string]any{
+		"key": key,
+	})
+
+	body, info, err := s.storage.Get(ctx, key)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		if errors.Is(err, ErrObjectNotFound) {
+			summary.Code = "404"
+		}
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, "get object error"), map[string]any{
+			"error": err.Error(),
+		})
+		return nil, nil, err
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "get object success"), map[string]any{
+		"Return": info,
+	})
+	return body, info, nil
+}
+
+func (s *service) Delete(ctx *kp.Context, key string) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        s.storage.Name(),
+		Command:     "delete_object",
+		Code:        "200",
+		Description: "success",
+	}
+
+	ctx.Log().Info(logger.NewDBRequest(logger.DELETE, "delete object"), map[string]any{
+		"key": key,
+	})
+
+	err := s.storage.Delete(ctx, key)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.DELETE, "delete object error"), map[string]any{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.DELETE, "delete object success"), map[string]any{
+		"key": key,
+	})
+	return nil
+}
+
+func (s *service) DeletePrefix(ctx *kp.Context, prefix string) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        s.storage.Name(),
+		Command:     "delete_objects",
+		Code:        "200",
+		Description: "success",
+	}
+
+	ctx.Log().Info(logger.NewDBRequest(logger.DELETE, "delete objects"), map[string]any{
+		"prefix": prefix,
+	})
+
+	err := s.storage.DeletePrefix(ctx, prefix)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.DELETE, "delete objects error"), map[string]any{
+			"error": err.Error(),
+		})
+		return err
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.DELETE, "delete objects success"), map[string]any{
+		"prefix": prefix,
+	})
+	return nil
+}
@@ -0,0 +1,43 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-user-service/apperror"
+)
+
+var ErrObjectNotFound = apperror.NotFound("object_not_found", "media object not found")
+
+// Storage is the blob store behind the media subsystem. Keys are slash
+// separated paths such as "avatars/<user_id>/<id>.jpg".
+type Storage interface {
+	Name() string
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// NewStorage picks the storage backend from MEDIA_STORAGE ("local" or "s3").
+func NewStorage(conf *config.Config) (Storage, error) {
+	switch conf.GetOrDefault("MEDIA_STORAGE", "local") {
+	case "local":
+		return NewLocalStorage(conf.GetOrDefault("MEDIA_LOCAL_DIR", "./data/media"))
+	case "s3":
+		useSSL, _ := strconv.ParseBool(conf.GetOrDefault("S3_USE_SSL", "false"))
+		return NewS3Storage(S3Config{
+			Endpoint:  conf.Get("S3_ENDPOINT"),
+			AccessKey: conf.Get("S3_ACCESS_KEY"),
+			SecretKey: conf.Get("S3_SECRET_KEY"),
+			Bucket:    conf.GetOrDefault("S3_BUCKET", "media"),
+			Region:    conf.Get("S3_REGION"),
+			UseSSL:    useSSL,
+		})
+	default:
+		return nil, errors.New("unsupported MEDIA_STORAGE: " + conf.Get("MEDIA_STORAGE"))
+	}
+}
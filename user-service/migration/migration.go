@@ -0,0 +1,314 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	migrationsCollection = "schema_migrations"
+	lockCollection       = "schema_migrations_lock"
+)
+
+var (
+	ErrSchemaBehind   = errors.New("schema_behind")
+	ErrUnknownVersion = errors.New("unknown_version")
+	ErrLocked         = errors.New("migration_in_progress")
+)
+
+// Migration is a declarative schema step. Up creates the listed indexes and
+// installs the validators; Down drops those indexes and puts back the
+// validator from the previous version of each collection.
+type Migration struct {
+	Version     int64
+	Description string
+	Collections []Collection
+}
+
+type Collection struct {
+	Name      string
+	Validator bson.M
+	Indexes   []mongo.IndexModel
+}
+
+type Status struct {
+	Version     int64      `json:"version"`
+	Description string     `json:"description"`
+	Applied     bool       `json:"applied"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+}
+
+type record struct {
+	Version     int64     `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+type Migrator struct {
+	db         *mongo.Database
+	migrations []Migration
+}
+
+func New(db *mongo.Database) *Migrator {
+	migrations := append([]Migration(nil), Migrations...)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Latest is the newest version known to this binary.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Description: mig.Description}
+		if rec, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &rec.AppliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Check returns ErrSchemaBehind when indexes or validators this binary
+// relies on have not been applied yet.
+func (m *Migrator) Check(ctx context.Context) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, s := range status {
+		if !s.Applied {
+			pending = append(pending, fmt.Sprintf("%d (%s)", s.Version, s.Description))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending migrations %s, run \"migrate up\"", ErrSchemaBehind, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down rolls back the last steps applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			if _, ok := applied[m.migrations[i].Version]; !ok {
+				continue
+			}
+			if err := m.down(ctx, i); err != nil {
+				return err
+			}
+			done = append(done, m.migrations[i])
+		}
+		return nil
+	})
+	return done, err
+}
+
+// To migrates up or down until version is the newest applied migration.
+// Version 0 rolls back everything.
+func (m *Migrator) To(ctx context.Context, version int64) ([]Migration, error) {
+	if version != 0 && !m.known(version) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	var done []Migration
+	err := m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok || mig.Version > version {
+				continue
+			}
+			if err := m.up(ctx, i); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok || mig.Version <= version {
+				continue
+			}
+			if err := m.down(ctx, i); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+func (m *Migrator) up(ctx context.Context, i int) error {
+	mig := m.migrations[i]
+	for _, c := range mig.Collections {
+		if c.Validator != nil {
+			if err := m.setValidator(ctx, c.Name, c.Validator); err != nil {
+				return fmt.Errorf("migration %d: validator on %s: %w", mig.Version, c.Name, err)
+			}
+		}
+		if len(c.Indexes) > 0 {
+			if _, err := m.db.Collection(c.Name).Indexes().CreateMany(ctx, c.Indexes); err != nil {
+				return fmt.Errorf("migration %d: indexes on %s: %w", mig.Version, c.Name, err)
+			}
+		}
+	}
+
+	_, err := m.db.Collection(migrationsCollection).InsertOne(ctx, record{
+		Version:     mig.Version,
+		Description: mig.Description,
+		AppliedAt:   time.Now().UTC(),
+	})
+	return err
+}
+
+func (m *Migrator) down(ctx context.Context, i int) error {
+	mig := m.migrations[i]
+	for _, c := range mig.Collections {
+		for _, idx := range c.Indexes {
+			name := indexName(idx)
+			if name == "" {
+				return fmt.Errorf("migration %d: index on %s has no name and cannot be dropped", mig.Version, c.Name)
+			}
+			if _, err := m.db.Collection(c.Name).Indexes().DropOne(ctx, name); err != nil && !isNamespaceOrIndexNotFound(err) {
+				return fmt.Errorf("migration %d: drop index %s on %s: %w", mig.Version, name, c.Name, err)
+			}
+		}
+		if c.Validator != nil {
+			if err := m.setValidator(ctx, c.Name, m.previousValidator(i, c.Name)); err != nil {
+				return fmt.Errorf("migration %d: validator on %s: %w", mig.Version, c.Name, err)
+			}
+		}
+	}
+
+	_, err := m.db.Collection(migrationsCollection).DeleteOne(ctx, bson.M{"_id": mig.Version})
+	return err
+}
+
+// previousValidator finds the validator an earlier migration installed on
+// collection, or an empty document which removes validation altogether.
+func (m *Migrator) previousValidator(i int, collection string) bson.M {
+	for j := i - 1; j >= 0; j-- {
+		for _, c := range m.migrations[j].Collections {
+			if c.Name == collection && c.Validator != nil {
+				return c.Validator
+			}
+		}
+	}
+	return bson.M{}
+}
+
+func (m *Migrator) setValidator(ctx context.Context, collection string, validator bson.M) error {
+	names, err := m.db.ListCollectionNames(ctx, bson.M{"name": collection})
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return m.db.CreateCollection(ctx, collection, options.CreateCollection().
+			SetValidator(validator).
+			SetValidationLevel("moderate").
+			SetValidationAction("error"))
+	}
+
+	return m.db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collection},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: "moderate"},
+		{Key: "validationAction", Value: "error"},
+	}).Err()
+}
+
+func (m *Migrator) known(version int64) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]record, error) {
+	cursor, err := m.db.Collection(migrationsCollection).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	applied := map[int64]record{}
+	for cursor.Next(ctx) {
+		var rec record
+		if err := cursor.Decode(&rec); err != nil {
+			return nil, err
+		}
+		applied[rec.Version] = rec
+	}
+	return applied, cursor.Err()
+}
+
+// withLock keeps a single migrator running at a time by inserting a lock
+// document; a second runner gets ErrLocked instead of racing the first.
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	col := m.db.Collection(lockCollection)
+	_, err := col.InsertOne(ctx, bson.M{"_id": "lock", "locked_at": time.Now().UTC()})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrLocked
+		}
+		return err
+	}
+	defer col.DeleteOne(context.Background(), bson.M{"_id": "lock"})
+
+	return fn()
+}
+
+func indexName(idx mongo.IndexModel) string {
+	if idx.Options == nil || idx.Options.Name == nil {
+		return ""
+	}
+	return *idx.Options.Name
+}
+
+func isNamespaceOrIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) {
+		return cmdErr.Code == 26 || cmdErr.Code == 27
+	}
+	return false
+}
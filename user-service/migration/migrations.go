@@ -0,0 +1,185 @@
+package migration
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// notDeleted limits unique indexes to live documents so a soft-deleted
+// user does not block the email or username from being reused.
+var notDeleted = bson.D{{Key: "deleted_at", Value: bson.D{{Key: "$eq", Value: nil}}}}
+
+// Migrations lists every schema step for user_service, oldest first.
+// Never edit an applied entry; append a new version instead.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "users unique email and username",
+		Collections: []Collection{
+			{
+				Name: "users",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys: bson.D{{Key: "email", Value: 1}},
+						Options: options.Index().
+							SetName("unique_email_if_not_deleted").
+							SetUnique(true).
+							SetPartialFilterExpression(notDeleted),
+					},
+					{
+						Keys: bson.D{{Key: "username", Value: 1}},
+						Options: options.Index().
+							SetName("unique_username_if_not_deleted").
+							SetUnique(true).
+							SetPartialFilterExpression(notDeleted),
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     2,
+		Description: "users schema validator",
+		Collections: []Collection{
+			{
+				Name: "users",
+				Validator: bson.M{
+					"$jsonSchema": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"_id", "username", "email", "createdat", "updatedat"},
+						"properties": bson.M{
+							"_id":              bson.M{"bsonType": "string"},
+							"firstname":        bson.M{"bsonType": "string"},
+							"lastname":         bson.M{"bsonType": "string"},
+							"username":         bson.M{"bsonType": "string", "minLength": 1},
+							"email":            bson.M{"bsonType": "string", "minLength": 3},
+							"avatar":           bson.M{"bsonType": "string"},
+							"avatar_thumbnail": bson.M{"bsonType": "string"},
+							"createdat":        bson.M{"bsonType": "string"},
+							"updatedat":        bson.M{"bsonType": "string"},
+							"deleted_at":       bson.M{"bsonType": bson.A{"date", "null"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     3,
+		Description: "users listing indexes",
+		Collections: []Collection{
+			{
+				Name: "users",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys: bson.D{
+							{Key: "deleted_at", Value: 1},
+							{Key: "createdat", Value: 1},
+							{Key: "_id", Value: 1},
+						},
+						Options: options.Index().SetName("users_list_by_created"),
+					},
+					{
+						Keys: bson.D{
+							{Key: "deleted_at", Value: 1},
+							{Key: "username", Value: 1},
+							{Key: "_id", Value: 1},
+						},
+						Options: options.Index().SetName("users_list_by_username"),
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     4,
+		Description: "users outbox index",
+		Collections: []Collection{
+			{
+				Name: "users",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "outbox.id", Value: 1}},
+						Options: options.Index().SetName("users_outbox_pending").SetSparse(true),
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     5,
+		Description: "addresses indexes",
+		Collections: []Collection{
+			{
+				Name: "addresses",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}},
+						Options: options.Index().SetName("addresses_by_user"),
+					},
+					{
+						Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "default_shipping", Value: 1}},
+						Options: options.Index().SetName("unique_default_shipping").SetUnique(true).
+							SetPartialFilterExpression(bson.M{"default_shipping": true}),
+					},
+					{
+						Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "default_billing", Value: 1}},
+						Options: options.Index().SetName("unique_default_billing").SetUnique(true).
+							SetPartialFilterExpression(bson.M{"default_billing": true}),
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     6,
+		Description: "wishlists indexes",
+		Collections: []Collection{
+			{
+				Name: "wishlists",
+				Indexes: []mongo.IndexModel{
+					{
+						Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}},
+						Options: options.Index().SetName("wishlists_by_user"),
+					},
+					{
+						Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}},
+						Options: options.Index().SetName("unique_wishlist_name").SetUnique(true),
+					},
+				},
+			},
+		},
+	},
+	{
+		Version:     7,
+		Description: "users schema validator with account fields",
+		Collections: []Collection{
+			{
+				Name: "users",
+				Validator: bson.M{
+					"$jsonSchema": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"_id", "username", "email", "createdat", "updatedat"},
+						"properties": bson.M{
+							"_id":               bson.M{"bsonType": "string"},
+							"firstname":         bson.M{"bsonType": "string"},
+							"lastname":          bson.M{"bsonType": "string"},
+							"username":          bson.M{"bsonType": "string", "minLength": 1},
+							"email":             bson.M{"bsonType": "string", "minLength": 3},
+							"avatar":            bson.M{"bsonType": "string"},
+							"avatar_thumbnail":  bson.M{"bsonType": "string"},
+							"createdat":         bson.M{"bsonType": "string"},
+							"updatedat":         bson.M{"bsonType": "string"},
+							"deleted_at":        bson.M{"bsonType": bson.A{"date", "null"}},
+							"email_verified_at": bson.M{"bsonType": "date"},
+							"password_hash":     bson.M{"bsonType": "string", "minLength": 1},
+							"verify_token":      bson.M{"bsonType": "string", "minLength": 1},
+							"reset_token":       bson.M{"bsonType": "string", "minLength": 1},
+						},
+					},
+				},
+			},
+		},
+	},
+}
@@ -0,0 +1,72 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kafka"
+)
+
+// Event is a domain event waiting to be published. It is written together
+// with the change it describes, in the same document, so the change and
+// the event are stored or lost together. Message is the exact Kafka value.
+type Event struct {
+	ID         string    `json:"event_id" bson:"id"`
+	Topic      string    `json:"topic" bson:"topic"`
+	Message    string    `json:"message" bson:"message"`
+	OccurredAt time.Time `json:"occurred_at" bson:"occurred_at"`
+}
+
+// NewEvent wraps body the way the services' consumers bind it:
+// {"event_id": ..., "occurred_at": ..., "body": body}.
+func NewEvent(topic string, body any) (Event, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return Event{}, err
+	}
+	now := time.Now().UTC()
+	message, err := json.Marshal(map[string]any{
+		"event_id":    id.String(),
+		"occurred_at": now.Format(time.RFC3339),
+		"body":        body,
+	})
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:         id.String(),
+		Topic:      topic,
+		Message:    string(message),
+		OccurredAt: now,
+	}, nil
+}
+
+// Publisher is the part of the Kafka client the relay needs.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, message []byte) error
+}
+
+// NewKafkaPublisher connects a Kafka producer for the relay from the same
+// settings app.StartKafka uses. It keeps retrying the connection in the
+// background; Publish fails until it is up.
+func NewKafkaPublisher(conf *config.Config) (Publisher, error) {
+	if conf.Kafka.Broker == "" {
+		return nil, errors.New("KAFKA_BROKER is required")
+	}
+	client := kafka.New(&kafka.Config{
+		Brokers:         strings.Split(conf.Kafka.Broker, ","),
+		BatchSize:       conf.Kafka.BatchSize,
+		BatchBytes:      conf.Kafka.BatchBytes,
+		BatchTimeout:    conf.Kafka.BatchTimeout,
+		ConsumerGroupID: conf.Kafka.ConsumerGroupID,
+	})
+	if client == nil {
+		return nil, errors.New("invalid kafka configuration")
+	}
+	return client, nil
+}
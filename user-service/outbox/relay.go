@@ -0,0 +1,111 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Relay publishes the events waiting in the outbox field of the documents
+// of a collection and removes them once Kafka has accepted them. Delivery
+// is at least once: a crash between the publish and the removal sends the
+// event again, so consumers should tolerate a repeated event_id.
+type Relay struct {
+	col       *mongo.Collection
+	publisher Publisher
+	interval  time.Duration
+	batch     int64
+}
+
+func NewRelay(col *mongo.Collection, publisher Publisher, conf *config.Config) *Relay {
+	interval, err := time.ParseDuration(conf.GetOrDefault("OUTBOX_INTERVAL", "1s"))
+	if err != nil || interval <= 0 {
+		interval = time.Second
+	}
+	batch, err := strconv.ParseInt(conf.GetOrDefault("OUTBOX_BATCH", "100"), 10, 64)
+	if err != nil || batch <= 0 {
+		batch = 100
+	}
+
+	return &Relay{
+		col:       col,
+		publisher: publisher,
+		interval:  interval,
+		batch:     batch,
+	}
+}
+
+// Start runs Flush on every interval until ctx is cancelled.
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := r.Flush(ctx); err != nil {
+				log.Printf("outbox %s: %v", r.col.Name(), err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Flush publishes the pending events of up to one batch of documents, in
+// the order each document recorded them, and reports how many went out.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	filter := bson.M{"outbox.id": bson.M{"$exists": true}}
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1, "outbox": 1}).
+		SetLimit(r.batch)
+
+	cursor, err := r.col.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	var docs []struct {
+		ID     string  `bson:"_id"`
+		Outbox []Event `bson:"outbox"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, doc := range docs {
+		var published []string
+		var publishErr error
+		for _, event := range doc.Outbox {
+			if publishErr = r.publisher.Publish(ctx, event.Topic, []byte(event.Message)); publishErr != nil {
+				break
+			}
+			published = append(published, event.ID)
+		}
+
+		if len(published) > 0 {
+			_, err := r.col.UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{
+				"$pull": bson.M{"outbox": bson.M{"id": bson.M{"$in": published}}},
+			})
+			if err != nil {
+				return sent, err
+			}
+			sent += len(published)
+		}
+		if publishErr != nil {
+			return sent, publishErr
+		}
+	}
+	if sent > 0 {
+		log.Printf("outbox %s: published %d events", r.col.Name(), sent)
+	}
+	return sent, nil
+}
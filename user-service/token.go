@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-user-service/servicetoken"
+)
+
+const tokenUsage = `usage: user-service token [ttl]
+
+prints an Authorization header value for the admin routes of this service,
+issued as admin, signed with SERVICE_TOKEN_SECRET and valid for ttl
+(default 15m, at most 24h)`
+
+// maxAdminTTL bounds how long a leaked admin token can be used.
+const maxAdminTTL = 24 * time.Hour
+
+// runToken implements the "token" subcommand.
+func runToken(conf *config.Config, args []string) error {
+	ttl := 15 * time.Minute
+	if len(args) > 0 {
+		d, err := time.ParseDuration(args[0])
+		if err != nil || d <= 0 || d > maxAdminTTL {
+			return fmt.Errorf("invalid ttl: %s\n%s", args[0], tokenUsage)
+		}
+		ttl = d
+	}
+	token, err := servicetoken.Issue(conf.Get("SERVICE_TOKEN_SECRET"), servicetoken.Admin, conf.GetOrDefault("APP_NAME", "user-service"), ttl, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Println(token)
+	return nil
+}
@@ -0,0 +1,206 @@
+package user
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-user-service/apperror"
+	"github.com/sing3demons/go-user-service/mailer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccountOptions configures email verification and password reset.
+type AccountOptions struct {
+	Mailer    mailer.Mailer
+	Secret    string        // Signs the tokens, TOKEN_SECRET
+	VerifyTTL time.Duration // How long a verification link works
+	ResetTTL  time.Duration // How long a password reset link works
+	// LinkBaseURL is the front end the links in the emails point at.
+	LinkBaseURL string
+}
+
+// NewAccountOptions reads the account settings. TOKEN_SECRET has no
+// default: tokens signed with a well known secret could be forged.
+func NewAccountOptions(conf *config.Config, m mailer.Mailer) (AccountOptions, error) {
+	secret := conf.Get("TOKEN_SECRET")
+	if secret == "" {
+		return AccountOptions{}, errors.New("TOKEN_SECRET is required")
+	}
+	verifyTTL, err := time.ParseDuration(conf.GetOrDefault("EMAIL_VERIFICATION_TTL", "48h"))
+	if err != nil || verifyTTL <= 0 {
+		verifyTTL = 48 * time.Hour
+	}
+	resetTTL, err := time.ParseDuration(conf.GetOrDefault("PASSWORD_RESET_TTL", "1h"))
+	if err != nil || resetTTL <= 0 {
+		resetTTL = time.Hour
+	}
+
+	return AccountOptions{
+		Mailer:      m,
+		Secret:      secret,
+		VerifyTTL:   verifyTTL,
+		ResetTTL:    resetTTL,
+		LinkBaseURL: strings.TrimSuffix(conf.GetOrDefault("MAIL_LINK_BASE_URL", "http://localhost:3000"), "/"),
+	}, nil
+}
+
+var (
+	ErrAlreadyVerified = apperror.Conflict("email_already_verified", "email", "the email address is already verified")
+	errSendMail        = apperror.Upstream("mail_send_failed", "the email could not be sent", nil)
+)
+
+// newAccount readies a user bound from a create request for insert. The
+// account state is set by the server alone, so what the client sent for it
+// is dropped: a new user always starts unverified, and without an avatar
+// until one is uploaded, since purge and erase delete what it points to.
+func newAccount(user *UserModel) error {
+	user.Avatar = ""
+	user.AvatarThumbnail = ""
+	user.EmailVerifiedAt = nil
+	user.PasswordHash = ""
+	user.VerifyToken = ""
+	user.ResetToken = ""
+	user.DeletedAt = nil
+	user.ErasedAt = nil
+	user.Outbox = nil
+	return hashPassword(user)
+}
+
+// hashPassword moves the plain password of a new user into PasswordHash.
+func hashPassword(user *UserModel) error {
+	if user.Password == "" {
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	user.PasswordHash = string(hash)
+	user.Password = ""
+	return nil
+}
+
+func (s *userService) SendVerification(ctx *kp.Context, id string) error {
+	user, err := s.repo.GetUserByID(ctx, id, Fields{"id", "email", "email_verified_at"})
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrAlreadyVerified
+	}
+	return s.sendVerification(ctx, user)
+}
+
+// sendVerification issues a verification token, replacing any earlier one,
+// and mails the link to the user.
+func (s *userService) sendVerification(ctx *kp.Context, user *UserModel) error {
+	token, nonceHash, err := s.tokens.issue(purposeVerifyEmail, user.ID, user.Email, s.accounts.VerifyTTL)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	if err := s.repo.SetToken(ctx, user.ID, "verify_token", nonceHash); err != nil {
+		return err
+	}
+	return s.sendMail(ctx, "verify_email", mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: "Confirm this is your email address by opening the link below.\n\n" +
+			s.accounts.LinkBaseURL + "/verify-email?user_id=" + user.ID + "&token=" + token + "\n\n" +
+			"The link expires in " + s.accounts.VerifyTTL.String() + ".\n",
+	})
+}
+
+func (s *userService) VerifyEmail(ctx *kp.Context, id string, req VerifyRequest) (*UserModel, error) {
+	claims, err := s.tokens.parse(req.Token, purposeVerifyEmail, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID != id {
+		return nil, ErrInvalidToken
+	}
+	if err := s.repo.VerifyEmail(ctx, id, claims.Email, hashNonce(claims.Nonce)); err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserByID(ctx, id, nil)
+}
+
+// ForgotPassword mails a reset link when email belongs to a user. It fails
+// the same way whether or not the email has an account, so the endpoint
+// does not reveal who has one: an unknown email is not an error, and once
+// the user is found, failing to issue or mail the link is only logged.
+func (s *userService) ForgotPassword(ctx *kp.Context, req ForgotPasswordRequest) error {
+	user, err := s.repo.GetUser(ctx, "email", req.Email)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, nonceHash, err := s.tokens.issue(purposeResetPassword, user.ID, user.Email, s.accounts.ResetTTL)
+	if err != nil {
+		ctx.Log().Error(logger.NewOutbound("issue reset password token failed", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return nil
+	}
+	// SetToken and sendMail log their own failures.
+	if err := s.repo.SetToken(ctx, user.ID, "reset_token", nonceHash); err != nil {
+		return nil
+	}
+	_ = s.sendMail(ctx, "reset_password", mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "Someone asked to reset the password of your account. Choose a new password with the link below.\n\n" +
+			s.accounts.LinkBaseURL + "/reset-password?token=" + token + "\n\n" +
+			"The link expires in " + s.accounts.ResetTTL.String() + ". If you did not ask for it, ignore this email.\n",
+	})
+	return nil
+}
+
+func (s *userService) ResetPassword(ctx *kp.Context, req ResetPasswordRequest) error {
+	claims, err := s.tokens.parse(req.Token, purposeResetPassword, time.Now())
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	return s.repo.ResetPassword(ctx, claims.UserID, claims.Email, hashNonce(claims.Nonce), string(hash))
+}
+
+func (s *userService) sendMail(ctx *kp.Context, cmd string, msg mailer.Message) error {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "mail",
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+	}
+	masking := logger.MaskingOptionDto{MaskingField: "to", MaskingType: logger.Email}
+	ctx.Log().Info(logger.NewOutbound("send "+strings.ReplaceAll(cmd, "_", " ")+" email", ""), map[string]any{
+		"mailer":  s.accounts.Mailer.Name(),
+		"to":      msg.To,
+		"subject": msg.Subject,
+	}, masking)
+
+	err := s.accounts.Mailer.Send(ctx, msg)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to send email"
+		ctx.Log().SetSummary(summary).Error(logger.NewOutbound("send email failed", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return errSendMail.Wrap(err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewOutbound("send email success", ""), map[string]any{
+		"to": msg.To,
+	}, masking)
+	return nil
+}
@@ -0,0 +1,42 @@
+package user
+
+import (
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestNewAccountIgnoresAccountState(t *testing.T) {
+	body := `{
+		"username": "jane",
+		"email": "jane@example.com",
+		"password": "correct horse",
+		"email_verified_at": "2026-01-01T00:00:00Z",
+		"avatar": "users/someone-else/avatar.png",
+		"avatar_thumbnail": "users/someone-else/avatar_thumb.png"
+	}`
+	var user UserModel
+	if err := json.Unmarshal([]byte(body), &user); err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerifiedAt == nil || user.Avatar == "" || user.AvatarThumbnail == "" {
+		t.Fatal("the account state was not bound; the test no longer covers it")
+	}
+
+	if err := newAccount(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerifiedAt != nil {
+		t.Errorf("EmailVerifiedAt = %v, want nil", user.EmailVerifiedAt)
+	}
+	if user.Avatar != "" || user.AvatarThumbnail != "" {
+		t.Errorf("Avatar = %q, AvatarThumbnail = %q, want none", user.Avatar, user.AvatarThumbnail)
+	}
+	if user.Password != "" {
+		t.Error("the plain password is kept")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("correct horse")); err != nil {
+		t.Errorf("PasswordHash does not match the password: %v", err)
+	}
+}
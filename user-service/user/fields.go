@@ -0,0 +1,141 @@
+package user
+
+import (
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-user-service/apperror"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// userFields maps the public field names accepted by fields= to document
+// fields.
+var userFields = map[string]string{
+	"id":                "_id",
+	"first_name":        "firstname",
+	"last_name":         "lastname",
+	"username":          "username",
+	"email":             "email",
+	"avatar":            "avatar",
+	"avatar_thumbnail":  "avatar_thumbnail",
+	"email_verified_at": "email_verified_at",
+	"created_at":        "createdat",
+	"updated_at":        "updatedat",
+}
+
+// publicFields are the fields of a user anyone may read. The public routes
+// serve no others; the rest is only for the user's own export and for the
+// other services, on the internal routes.
+var publicFields = Fields{"id", "username", "avatar", "avatar_thumbnail", "created_at", "updated_at"}
+
+// Fields is the sparse fieldset asked for with fields=, e.g.
+// fields=id,username,email. An empty set means every field.
+type Fields []string
+
+// ParseFields reads the fields query parameter.
+func ParseFields(ctx *kp.Context) (Fields, error) {
+	value := ctx.Param("fields")
+	if value == "" {
+		return nil, nil
+	}
+
+	var fields Fields
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || fields.Has(name) {
+			continue
+		}
+		if _, ok := userFields[name]; !ok {
+			names := make([]string, 0, len(userFields))
+			for n := range userFields {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, apperror.Invalid(apperror.FieldError{
+				Field:   "fields",
+				Rule:    "oneof",
+				Message: "unknown field " + name + "; must be any of: " + strings.Join(names, ", "),
+			})
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// ParsePublicFields is ParseFields for the public routes: only publicFields
+// may be asked for, and an empty set means all of them.
+func ParsePublicFields(ctx *kp.Context) (Fields, error) {
+	fields, err := ParseFields(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return slices.Clone(publicFields), nil
+	}
+	for _, name := range fields {
+		if !slices.Contains(publicFields, name) {
+			return nil, apperror.Invalid(apperror.FieldError{
+				Field:   "fields",
+				Rule:    "oneof",
+				Message: "field " + name + " is not public; must be any of: " + strings.Join(publicFields, ", "),
+			})
+		}
+	}
+	return fields, nil
+}
+
+// Has reports whether name was asked for. Every field is in an empty set.
+func (f Fields) Has(name string) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, n := range f {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// projection returns the Mongo projection for the set. An empty set loads
+// the whole document but the pending outbox events and the credentials.
+// The extra document fields are always loaded; the repository needs them
+// even when the caller did not ask for them.
+func (f Fields) projection(extra ...string) bson.M {
+	if len(f) == 0 {
+		return bson.M{"outbox": 0, "password_hash": 0, "verify_token": 0, "reset_token": 0}
+	}
+	projection := bson.M{"_id": 1}
+	for _, name := range f {
+		projection[userFields[name]] = 1
+	}
+	for _, field := range extra {
+		projection[field] = 1
+	}
+	return projection
+}
+
+// MarshalJSON leaves out the fields that were not asked for, so a sparse
+// user is not padded with empty strings. The id and href are always kept.
+func (u UserModel) MarshalJSON() ([]byte, error) {
+	type plain UserModel
+	data, err := json.Marshal(plain(u))
+	if err != nil || len(u.fields) == 0 {
+		return data, err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	sparse := make(map[string]json.RawMessage, len(u.fields)+2)
+	for name, value := range all {
+		if name == "id" || name == "href" || u.fields.Has(name) {
+			sparse[name] = value
+		}
+	}
+	return json.Marshal(sparse)
+}
@@ -1,14 +1,24 @@
 package user
 
 import (
-	"errors"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 	"regexp"
 
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
 	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-user-service/apperror"
+	"github.com/sing3demons/go-user-service/httpcache"
+	"github.com/sing3demons/go-user-service/validation"
 )
 
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]{3,20}$`)
+
+func init() {
+	validation.Register("username", "must be 3-20 letters, digits or underscores", usernamePattern.MatchString)
+}
+
 type Handler struct {
 	svc Service
 }
@@ -29,13 +39,8 @@ func (h *Handler) CreateUser(ctx *kp.Context) error {
 		Description: "",
 	}
 
-	if err := ctx.Bind(&body); err != nil {
-		summary.Code = "400"
-		summary.Description = "invalid_request"
-		ctx.Log().SetSummary(summary)
-		return ctx.JSON(400, map[string]string{
-			"error": "invalid_request",
-		})
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
 	}
 
 	maskingOption := []logger.MaskingOptionDto{
@@ -51,6 +56,10 @@ func (h *Handler) CreateUser(ctx *kp.Context) error {
 			MaskingField: "Body.last_name",
 			MaskingType:  logger.Lastname,
 		},
+		{
+			MaskingField: "Body.password",
+			MaskingType:  logger.Full,
+		},
 	}
 
 	ctx.Log().SetSummary(summary).Info(logger.NewInbound("create_user", ""), map[string]any{
@@ -59,9 +68,7 @@ func (h *Handler) CreateUser(ctx *kp.Context) error {
 	}, maskingOption...)
 
 	if err := h.svc.CreateUser(ctx, &body); err != nil {
-		return ctx.JSON(500, map[string]string{
-			"error": "internal_server",
-		})
+		return apperror.Write(ctx, err)
 	}
 
 	ctx.Header().Set("x-rid", ctx.RequestId())
@@ -71,21 +78,27 @@ func (h *Handler) CreateUser(ctx *kp.Context) error {
 	})
 }
 
+// GetUserByID serves the public profile of a user, see publicFields.
 func (h *Handler) GetUserByID(ctx *kp.Context) error {
+	return h.getUserByID(ctx, ParsePublicFields)
+}
+
+// GetInternalUserByID serves any field of a user to the other services.
+func (h *Handler) GetInternalUserByID(ctx *kp.Context) error {
+	return h.getUserByID(ctx, ParseFields)
+}
+
+func (h *Handler) getUserByID(ctx *kp.Context, parseFields func(*kp.Context) (Fields, error)) error {
 	id := ctx.PathParam("id")
 	node := "client"
 	cmd := "get_user_by_id"
 	summary := logger.EventTag(node, cmd, "200", "")
-	if id == "" {
-		summary.Code = "400"
-		summary.Description = "invalid_request"
-		ctx.Log().SetSummary(summary).Error(logger.NewInbound(cmd, ""), map[string]any{
-			"error": "invalid_user_id",
-		})
-		ctx.Header().Set("x-rid", ctx.RequestId())
-		return ctx.JSON(http.StatusBadRequest, map[string]string{
-			"error": "invalid_user_id",
-		})
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	fields, err := parseFields(ctx)
+	if err != nil {
+		return validation.Respond(ctx, summary, err)
 	}
 
 	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
@@ -93,19 +106,31 @@ func (h *Handler) GetUserByID(ctx *kp.Context) error {
 			"key":   "id",
 			"value": id,
 		},
+		"Fields": fields,
 	})
 
-	user, err := h.svc.GetUserByID(ctx, id)
+	user, err := h.svc.GetUserByID(ctx, id, fields)
 	if err != nil {
-		return ctx.JSON(http.StatusNotFound, map[string]string{
-			"error": "data_not_found",
-		})
+		return apperror.Write(ctx, err)
+	}
+	if httpcache.NotModified(ctx, user.etag(), user.lastModified()) {
+		return nil
 	}
 	ctx.Header().Set("x-rid", ctx.RequestId())
 	return ctx.JSON(http.StatusOK, user)
 }
 
 func (h *Handler) GetAllUsers(ctx *kp.Context) error {
+	return h.getAllUsers(ctx, ParseUserQuery)
+}
+
+// GetInternalUsers lists users with any of their fields, filtered by email
+// domain too, see ParseInternalUserQuery.
+func (h *Handler) GetInternalUsers(ctx *kp.Context) error {
+	return h.getAllUsers(ctx, ParseInternalUserQuery)
+}
+
+func (h *Handler) getAllUsers(ctx *kp.Context, parseQuery func(*kp.Context) (UserQuery, error)) error {
 	node := "client"
 	cmd := "get_all_users"
 	summary := logger.LogEventTag{
@@ -115,16 +140,21 @@ func (h *Handler) GetAllUsers(ctx *kp.Context) error {
 		Description: "",
 	}
 
-	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), nil)
+	query, err := parseQuery(ctx)
+	if err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
 
-	users, err := h.svc.GetAllUsers(ctx)
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"Query": query,
+	})
+
+	page, err := h.svc.GetAllUsers(ctx, query)
 	if err != nil {
-		return ctx.JSON(500, map[string]string{
-			"error": "internal_server_error",
-		})
+		return apperror.Write(ctx, err)
 	}
 	ctx.Header().Set("x-rid", ctx.RequestId())
-	return ctx.JSON(http.StatusOK, users)
+	return ctx.JSON(http.StatusOK, page)
 }
 
 func (h *Handler) DeleteUser(ctx *kp.Context) error {
@@ -138,15 +168,8 @@ func (h *Handler) DeleteUser(ctx *kp.Context) error {
 		Description: "",
 	}
 
-	if id == "" {
-		summary.Code = "400"
-		summary.Description = "invalid_request"
-		ctx.Log().SetSummary(summary).Error(logger.NewInbound(cmd, ""), map[string]any{
-			"error": "invalid_request",
-		})
-		return ctx.JSON(http.StatusBadRequest, map[string]string{
-			"error": "invalid_request",
-		})
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
 	}
 
 	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
@@ -157,9 +180,7 @@ func (h *Handler) DeleteUser(ctx *kp.Context) error {
 	})
 
 	if err := h.svc.DeleteUser(ctx, id); err != nil {
-		return ctx.JSON(http.StatusNotFound, map[string]string{
-			"error": "data_not_found",
-		})
+		return apperror.Write(ctx, err)
 	}
 
 	ctx.Header().Set("x-rid", ctx.RequestId())
@@ -167,28 +188,113 @@ func (h *Handler) DeleteUser(ctx *kp.Context) error {
 		"message": "delete_success",
 	})
 }
+func (h *Handler) RestoreUser(ctx *kp.Context) error {
+	id := ctx.PathParam("id")
+	node := "client"
+	cmd := "restore_user"
+	summary := logger.EventTag(node, cmd, "200", "")
+
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"Param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+	})
+
+	user, err := h.svc.RestoreUser(ctx, id)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, user)
+}
+
+func (h *Handler) ExportUser(ctx *kp.Context) error {
+	id := ctx.PathParam("id")
+	node := "client"
+	cmd := "export_user"
+	summary := logger.EventTag(node, cmd, "200", "")
+
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"Param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+	})
+
+	export, err := h.svc.ExportUser(ctx, id)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	ctx.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%s-export.json"`, id))
+	return ctx.JSON(http.StatusOK, export)
+}
+
+func (h *Handler) EraseUser(ctx *kp.Context) error {
+	id := ctx.PathParam("id")
+	node := "client"
+	cmd := "erase_user"
+	summary := logger.EventTag(node, cmd, "200", "")
+
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"Param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+	})
+
+	event, err := h.svc.EraseUser(ctx, id)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]string{
+		"message":   "erase_success",
+		"id":        event.UserID,
+		"erased_at": event.ErasedAt,
+	})
+}
+
 func (h *Handler) validateUsernameAndEmail(key, value string) error {
-	if key == "" || value == "" || (key != "email" && key != "username") {
-		return errors.New("invalid_request")
-	}
-	if key == "email" {
-		// validate email format
-		emailRegex := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
-		matched, err := regexp.MatchString(emailRegex, value)
-		if err != nil || !matched {
-			return errors.New("invalid_email_format")
-		}
-	} else if key == "username" {
-		// validate username format (e.g., alphanumeric, length)
-		usernameRegex := `^[a-zA-Z0-9_]{3,20}$`
-		matched, err := regexp.MatchString(usernameRegex, value)
-		if err != nil || !matched {
-			return errors.New("invalid_username_format")
-		}
-	}
-	return nil
+	switch key {
+	case "email":
+		return validation.Var("value", value, "required,email")
+	case "username":
+		return validation.Var("value", value, "required,username")
+	}
+	return apperror.Invalid(apperror.FieldError{Field: "key", Rule: "oneof", Message: "must be one of: email, username"})
 }
+
+// GetUser serves the public profile of the user with a username. Looking
+// a user up by email would tell anyone who has an account, so that is left
+// to GetInternalUser.
 func (h *Handler) GetUser(ctx *kp.Context) error {
+	return h.getUser(ctx, true)
+}
+
+// GetInternalUser serves the whole user with a username or email to the
+// other services.
+func (h *Handler) GetInternalUser(ctx *kp.Context) error {
+	return h.getUser(ctx, false)
+}
+
+func (h *Handler) getUser(ctx *kp.Context, public bool) error {
 	key := ctx.PathParam("key")
 	value := ctx.PathParam("value")
 	node := "client"
@@ -200,19 +306,11 @@ func (h *Handler) GetUser(ctx *kp.Context) error {
 		Description: "",
 	}
 
+	if public && key != "username" {
+		return validation.Respond(ctx, summary, apperror.Invalid(apperror.FieldError{Field: "key", Rule: "oneof", Message: "must be one of: username"}))
+	}
 	if err := h.validateUsernameAndEmail(key, value); err != nil {
-		summary.Code = "400"
-		summary.Description = err.Error()
-
-		ctx.Log().SetSummary(summary).Error(logger.NewInbound(cmd, ""), map[string]any{
-			"Params": map[string]string{
-				"key":   key,
-				"value": value,
-			},
-		})
-		return ctx.JSON(http.StatusBadRequest, map[string]string{
-			"error": "invalid_request",
-		})
+		return validation.Respond(ctx, summary, err)
 	}
 
 	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
@@ -224,17 +322,148 @@ func (h *Handler) GetUser(ctx *kp.Context) error {
 
 	user, err := h.svc.GetUser(ctx, key, value)
 	if err != nil {
-		if err.Error() == "data_not_found" {
-			return ctx.JSON(http.StatusNotFound, map[string]string{
-				"error": "data_not_found",
-			})
-		} else {
-			return ctx.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "internal_server_error",
-			})
-		}
+		return apperror.Write(ctx, err)
+	}
+	if public {
+		user.fields = publicFields
+	}
+
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	return ctx.JSON(http.StatusOK, user)
+}
+
+type avatarUpload struct {
+	File multipart.FileHeader `file:"file"`
+}
+
+func (h *Handler) UploadAvatar(ctx *kp.Context) error {
+	id := ctx.PathParam("id")
+	node := "client"
+	cmd := "upload_avatar"
+	summary := logger.EventTag(node, cmd, "200", "")
+
+	var body avatarUpload
+	if err := ctx.Bind(&body); err != nil || body.File.Filename == "" {
+		return validation.Respond(ctx, summary, apperror.Invalid(apperror.FieldError{
+			Field:   "file",
+			Rule:    "required",
+			Message: "is required",
+		}))
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"Param": map[string]string{
+			"key":   "id",
+			"value": id,
+		},
+		"File": map[string]any{
+			"filename":     body.File.Filename,
+			"size":         body.File.Size,
+			"content_type": body.File.Header.Get("Content-Type"),
+		},
+	})
+
+	user, err := h.svc.UploadAvatar(ctx, id, &body.File)
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	return ctx.JSON(http.StatusOK, user)
+}
+
+func (h *Handler) SendVerification(ctx *kp.Context) error {
+	id := ctx.PathParam("id")
+	cmd := "send_verification"
+	summary := logger.EventTag("client", cmd, "200", "")
+
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"Param": map[string]string{"id": id},
+	})
+
+	err := h.svc.SendVerification(ctx, id)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(http.StatusAccepted, map[string]string{
+		"message": "verification_sent",
+		"id":      id,
+	})
+}
+
+func (h *Handler) VerifyEmail(ctx *kp.Context) error {
+	id := ctx.PathParam("id")
+	cmd := "verify_email"
+	summary := logger.EventTag("client", cmd, "200", "")
+
+	if err := validation.Var("id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
 	}
+	var body VerifyRequest
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"Param": map[string]string{"id": id},
+		"Body":  body,
+	}, logger.MaskingOptionDto{MaskingField: "Body.token", MaskingType: logger.Full})
 
+	user, err := h.svc.VerifyEmail(ctx, id, body)
 	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
 	return ctx.JSON(http.StatusOK, user)
 }
+
+// ForgotPassword answers 202 whether or not the email is known.
+func (h *Handler) ForgotPassword(ctx *kp.Context) error {
+	cmd := "forgot_password"
+	summary := logger.EventTag("client", cmd, "200", "")
+
+	var body ForgotPasswordRequest
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"Body": body,
+	}, logger.MaskingOptionDto{MaskingField: "Body.email", MaskingType: logger.Email})
+
+	err := h.svc.ForgotPassword(ctx, body)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(http.StatusAccepted, map[string]string{
+		"message": "reset_requested",
+	})
+}
+
+func (h *Handler) ResetPassword(ctx *kp.Context) error {
+	cmd := "reset_password"
+	summary := logger.EventTag("client", cmd, "200", "")
+
+	var body ResetPasswordRequest
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(cmd, ""), map[string]any{
+		"Body": body,
+	}, []logger.MaskingOptionDto{
+		{MaskingField: "Body.token", MaskingType: logger.Full},
+		{MaskingField: "Body.password", MaskingType: logger.Full},
+	}...)
+
+	err := h.svc.ResetPassword(ctx, body)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{
+		"message": "password_reset",
+	})
+}
@@ -1,17 +1,100 @@
 package user
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-user-service/address"
+	"github.com/sing3demons/go-user-service/httpcache"
+	"github.com/sing3demons/go-user-service/outbox"
+	"github.com/sing3demons/go-user-service/wishlist"
+)
 
 type UserModel struct {
-	ID        string     `json:"id" bson:"_id"`
-	Href      string     `json:"href,omitempty" bson:"-"`
-	FirstName string     `json:"first_name"`
-	LastName  string     `json:"last_name"`
-	Username  string     `json:"username"`
-	Email     string     `json:"email" bson:"email,unique"`
-	Avatar    string     `json:"avatar,omitempty"`
-	Password  string     `json:"password,omitempty" bson:"-"`
-	CreatedAt string     `json:"created_at"`
-	UpdatedAt string     `json:"updated_at"`
-	DeletedAt *time.Time `json:"-" bson:"deleted_at"`
+	ID              string     `json:"id" bson:"_id"`
+	Href            string     `json:"href,omitempty" bson:"-"`
+	FirstName       string     `json:"first_name" validate:"max=100"`
+	LastName        string     `json:"last_name" validate:"max=100"`
+	Username        string     `json:"username" validate:"required,username"`
+	Email           string     `json:"email" bson:"email,unique" validate:"required,email,max=254"`
+	Avatar          string     `json:"avatar,omitempty"`
+	AvatarThumbnail string     `json:"avatar_thumbnail,omitempty" bson:"avatar_thumbnail,omitempty"`
+	Password        string     `json:"password,omitempty" bson:"-" validate:"omitempty,min=8,max=72"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" bson:"email_verified_at,omitempty"`
+	CreatedAt       string     `json:"created_at"`
+	UpdatedAt       string     `json:"updated_at"`
+	DeletedAt       *time.Time `json:"-" bson:"deleted_at"`
+	ErasedAt        *time.Time `json:"-" bson:"erased_at,omitempty"`
+	// Outbox holds the domain events recorded with the last writes until
+	// the relay publishes them. Reads leave it out.
+	Outbox []outbox.Event `json:"-" bson:"outbox,omitempty"`
+	// PasswordHash is the bcrypt hash of Password, which itself is never
+	// stored. VerifyToken and ResetToken hold the nonce hash of the
+	// outstanding email verification and password reset token.
+	PasswordHash string `json:"-" bson:"password_hash,omitempty"`
+	VerifyToken  string `json:"-" bson:"verify_token,omitempty"`
+	ResetToken   string `json:"-" bson:"reset_token,omitempty"`
+
+	// fields is the sparse fieldset the user was loaded with, see Fields.
+	fields Fields
+}
+
+// etag identifies this representation of the user. The fieldset is part of
+// it since a sparse user is a different body. updatedat is written with
+// nanoseconds so two updates within a second still differ.
+func (u *UserModel) etag() string {
+	return httpcache.ETag(u.ID, u.UpdatedAt, strings.Join(u.fields, ","))
+}
+
+// lastModified is updated_at as a time, zero when it is missing.
+func (u *UserModel) lastModified() time.Time {
+	t, _ := time.Parse(time.RFC3339, u.UpdatedAt)
+	return t
+}
+
+// VerifyRequest is the body of POST /users/{id}/verify.
+type VerifyRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ForgotPasswordRequest is the body of POST /password/forgot.
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,max=254"`
+}
+
+// ResetPasswordRequest is the body of POST /password/reset.
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+// UserExport is the personal data archive returned by GET /internal/users/{id}/export.
+type UserExport struct {
+	ExportedAt   string               `json:"exported_at"`
+	User         *UserModel           `json:"user"`
+	Addresses    []*address.Address   `json:"addresses"`
+	Wishlists    []*wishlist.Wishlist `json:"wishlists"`
+	Orders       []map[string]any     `json:"orders"`
+	OrderHistory []map[string]any     `json:"order_history"`
+}
+
+// CustomerData is what order-service holds about a customer.
+type CustomerData struct {
+	Orders       []map[string]any `json:"orders"`
+	OrderHistory []map[string]any `json:"order_history"`
+}
+
+// UserErasedEvent is published on the user_erased topic so other services
+// can scrub their copies of the user.
+type UserErasedEvent struct {
+	UserID   string `json:"user_id"`
+	ErasedAt string `json:"erased_at"`
+}
+
+// UserEvent is the body of the user_created, user_updated and user_deleted
+// events. Changes holds the new values of the fields that were written.
+type UserEvent struct {
+	ID      string         `json:"id"`
+	Changes map[string]any `json:"changes,omitempty"`
+	At      string         `json:"at"`
 }
@@ -0,0 +1,126 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-user-service/apperror"
+	"github.com/sing3demons/go-user-service/servicetoken"
+)
+
+type HttpRequest struct {
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers"`
+	Params   map[string]string `json:"params"`
+	Protocol string            `json:"protocol"`
+	Method   string            `json:"method"`
+	Timeout  time.Duration     `json:"timeout"`
+}
+
+const contentTypeHeader = "Content-Type"
+
+var errOrderService = apperror.Upstream("order_service_error", "order-service could not provide the customer data", nil)
+
+// getCustomerData asks order-service for everything it stores about the
+// user, on its internal route.
+func getCustomerData(ctx *kp.Context, userID string) (*CustomerData, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "order_service",
+		Command:     "get_customer_data",
+		Code:        "200",
+		Description: "success",
+	}
+
+	orderServiceURL := os.Getenv("ORDER_SERVICE_URL")
+	if orderServiceURL == "" {
+		orderServiceURL = "http://localhost:8083" // Default URL if not set
+	}
+
+	httpRequest := HttpRequest{
+		URL:      orderServiceURL + "/internal/customers/" + userID + "/data",
+		Headers:  map[string]string{contentTypeHeader: "application/json"},
+		Params:   map[string]string{"customer_id": userID},
+		Protocol: "http",
+		Method:   http.MethodGet,
+		Timeout:  5 * time.Second,
+	}
+
+	ctx.Log().Info(logger.NewHTTPRequest("get customer data", ""), map[string]any{
+		"uri":      httpRequest.URL,
+		"headers":  httpRequest.Headers,
+		"params":   httpRequest.Params,
+		"protocol": httpRequest.Protocol,
+		"method":   httpRequest.Method,
+		"timeout":  httpRequest.Timeout,
+	})
+	req, err := http.NewRequest(http.MethodGet, httpRequest.URL, nil)
+	if err != nil {
+		return nil, errOrderService.Wrap(err)
+	}
+	req.Header.Set(contentTypeHeader, httpRequest.Headers[contentTypeHeader])
+	if err := servicetoken.Authorize(req, servicetoken.OrderService); err != nil {
+		return nil, errOrderService.Wrap(err)
+	}
+
+	httpClient := &http.Client{
+		Timeout: httpRequest.Timeout,
+	}
+
+	resp, err := httpClient.Do(req)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to get customer data"
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("http get customer data", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, errOrderService.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		summary.Code = fmt.Sprintf("%d", resp.StatusCode)
+		summary.Description = resp.Status
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get customer data failed", ""), map[string]string{
+			"error": fmt.Sprintf("failed to get customer data: %s", resp.Status),
+		})
+		return nil, errOrderService.Wrap(fmt.Errorf("failed to get customer data: %s", resp.Status))
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to read response body"
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get customer data failed", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, errOrderService.Wrap(err)
+	}
+
+	var data CustomerData
+	if err := json.Unmarshal(bodyBytes, &data); err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("get customer data failed", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, errOrderService.Wrap(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewHTTPResponse("get customer data success", ""), map[string]any{
+		"Headers": resp.Header,
+		"Status":  resp.Status,
+		"Body": map[string]int{
+			"orders":        len(data.Orders),
+			"order_history": len(data.OrderHistory),
+		},
+	})
+	return &data, nil
+}
@@ -0,0 +1,111 @@
+package user
+
+import (
+	"context"
+	"log"
+	"time"
+
+	config "github.com/sing3demons/go-common-kp/kp/configs"
+	"github.com/sing3demons/go-user-service/media"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Purger permanently removes users that have been soft-deleted for longer
+// than the retention period, together with their stored avatars, address
+// books and wishlists.
+type Purger struct {
+	col       *mongo.Collection
+	addresses *mongo.Collection
+	wishlists *mongo.Collection
+	storage   media.Storage
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurger(col, addresses, wishlists *mongo.Collection, storage media.Storage, conf *config.Config) *Purger {
+	retention, err := time.ParseDuration(conf.GetOrDefault("PURGE_RETENTION", "720h"))
+	if err != nil || retention <= 0 {
+		retention = 30 * 24 * time.Hour
+	}
+	interval, err := time.ParseDuration(conf.GetOrDefault("PURGE_INTERVAL", "1h"))
+	if err != nil || interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &Purger{
+		col:       col,
+		addresses: addresses,
+		wishlists: wishlists,
+		storage:   storage,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Start runs Purge on every interval until ctx is cancelled.
+func (p *Purger) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := p.Purge(ctx); err != nil {
+				log.Printf("purge users: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (p *Purger) Purge(ctx context.Context) (int64, error) {
+	cutoff := time.Now().UTC().Add(-p.retention)
+	// A user whose events are still waiting in the outbox is kept until the
+	// relay has sent them, e.g. the user_erased of an erasure.
+	filter := bson.M{"deleted_at": bson.M{"$lt": cutoff}, "outbox.id": bson.M{"$exists": false}}
+
+	cursor, err := p.col.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var docs []struct {
+		ID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return 0, err
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if err := p.storage.DeletePrefix(ctx, "avatars/"+doc.ID); err != nil {
+			log.Printf("purge avatar of user %s: %v", doc.ID, err)
+		}
+		ids = append(ids, doc.ID)
+	}
+
+	if _, err := p.addresses.DeleteMany(ctx, bson.M{"user_id": bson.M{"$in": ids}}); err != nil {
+		return 0, err
+	}
+	if _, err := p.wishlists.DeleteMany(ctx, bson.M{"user_id": bson.M{"$in": ids}}); err != nil {
+		return 0, err
+	}
+
+	result, err := p.col.DeleteMany(ctx, bson.M{
+		"_id":        bson.M{"$in": ids},
+		"deleted_at": bson.M{"$lt": cutoff},
+		"outbox.id":  bson.M{"$exists": false},
+	})
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("purged %d users soft-deleted before %s", result.DeletedCount, cutoff.Format(time.RFC3339))
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,217 @@
+package user
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-user-service/apperror"
+	"github.com/sing3demons/go-user-service/validation"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// sortFields maps the public sort keys of GET /users to document fields.
+var sortFields = map[string]string{
+	"created_at": "createdat",
+	"username":   "username",
+}
+
+// UserQuery holds the paging, sorting and filtering options of GET /users
+// and GET /internal/users. Only the internal listing filters by email
+// domain, since the public one does not serve emails.
+type UserQuery struct {
+	Limit       int    `json:"limit" validate:"min=1,max=100"`
+	After       string `json:"after"`
+	Sort        string `json:"sort" validate:"oneof=created_at -created_at username -username"`
+	Username    string `json:"username" validate:"omitempty,max=20"`
+	EmailDomain string `json:"email_domain" validate:"omitempty,fqdn"`
+	CreatedFrom string `json:"created_from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedTo   string `json:"created_to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Fields      Fields `json:"fields,omitempty"`
+
+	cursor   *userCursor
+	internal bool
+}
+
+// userCursor marks the last user of a page: the value of the sort field
+// and the id, which breaks ties between equal values.
+type userCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// UserPage is one page of GET /users.
+type UserPage struct {
+	Users []*UserModel `json:"users"`
+	Count int          `json:"count"`
+	Next  string       `json:"next,omitempty"`
+}
+
+// ParseUserQuery reads the GET /users query string.
+func ParseUserQuery(ctx *kp.Context) (UserQuery, error) {
+	return parseUserQuery(ctx, false)
+}
+
+// ParseInternalUserQuery reads the GET /internal/users query string, which
+// may also filter by email_domain and ask for any field.
+func ParseInternalUserQuery(ctx *kp.Context) (UserQuery, error) {
+	return parseUserQuery(ctx, true)
+}
+
+func parseUserQuery(ctx *kp.Context, internal bool) (UserQuery, error) {
+	q := UserQuery{
+		Limit:       defaultPageSize,
+		After:       ctx.Param("after"),
+		Sort:        "created_at",
+		Username:    ctx.Param("username"),
+		CreatedFrom: ctx.Param("created_from"),
+		CreatedTo:   ctx.Param("created_to"),
+		internal:    internal,
+	}
+	parseFields := ParsePublicFields
+	if internal {
+		q.EmailDomain = strings.ToLower(ctx.Param("email_domain"))
+		parseFields = ParseFields
+	}
+	if v := ctx.Param("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return q, apperror.Invalid(apperror.FieldError{Field: "limit", Rule: "numeric", Message: "must be a number"})
+		}
+		q.Limit = limit
+	}
+	if v := ctx.Param("sort"); v != "" {
+		q.Sort = v
+	}
+	fields, err := parseFields(ctx)
+	if err != nil {
+		return q, err
+	}
+	q.Fields = fields
+	if err := validation.Struct(q); err != nil {
+		return q, err
+	}
+
+	if q.After != "" {
+		cursor, err := decodeCursor(q.After)
+		if err != nil || cursor.Sort != q.Sort {
+			return q, apperror.Invalid(apperror.FieldError{Field: "after", Rule: "cursor", Message: "is not a cursor for this sort"})
+		}
+		q.cursor = cursor
+	}
+	return q, nil
+}
+
+// sortField returns the document field and direction (1 or -1) to sort by.
+func (q UserQuery) sortField() (string, int) {
+	if strings.HasPrefix(q.Sort, "-") {
+		return sortFields[q.Sort[1:]], -1
+	}
+	return sortFields[q.Sort], 1
+}
+
+// filter builds the Mongo filter for the query, including the keyset
+// condition that resumes after the cursor. createdat is an RFC 3339 string,
+// in UTC since it is written so but in +07:00 on older users, so the
+// created range compares it as a date rather than as a string.
+func (q UserQuery) filter() map[string]any {
+	filter := map[string]any{
+		"deleted_at": nil,
+	}
+	if q.Username != "" {
+		filter["username"] = map[string]any{"$regex": "^" + regexp.QuoteMeta(q.Username)}
+	}
+	if q.EmailDomain != "" {
+		filter["email"] = map[string]any{"$regex": "@" + regexp.QuoteMeta(q.EmailDomain) + "$", "$options": "i"}
+	}
+	createdAt := map[string]any{"$dateFromString": map[string]any{"dateString": "$createdat", "onError": nil}}
+	var created []any
+	if t, err := time.Parse(time.RFC3339, q.CreatedFrom); err == nil {
+		created = append(created, map[string]any{"$gte": []any{createdAt, t.UTC()}})
+	}
+	if t, err := time.Parse(time.RFC3339, q.CreatedTo); err == nil {
+		created = append(created, map[string]any{"$lt": []any{createdAt, t.UTC()}})
+	}
+	if len(created) > 0 {
+		filter["$expr"] = map[string]any{"$and": created}
+	}
+
+	if q.cursor != nil {
+		field, dir := q.sortField()
+		op := "$gt"
+		if dir < 0 {
+			op = "$lt"
+		}
+		filter["$or"] = []any{
+			map[string]any{field: map[string]any{op: q.cursor.Value}},
+			map[string]any{field: q.cursor.Value, "_id": map[string]any{op: q.cursor.ID}},
+		}
+	}
+	return filter
+}
+
+// next returns the link to the page after users, which must hold one
+// user more than the limit for a next page to exist.
+func (q UserQuery) next(ctx *kp.Context, users []*UserModel) string {
+	if len(users) <= q.Limit {
+		return ""
+	}
+	last := users[q.Limit-1]
+	cursor := userCursor{Sort: q.Sort, ID: last.ID, Value: last.CreatedAt}
+	if field, _ := q.sortField(); field == "username" {
+		cursor.Value = last.Username
+	}
+
+	params := url.Values{}
+	params.Set("limit", strconv.Itoa(q.Limit))
+	params.Set("sort", q.Sort)
+	params.Set("after", encodeCursor(cursor))
+	filters := map[string]string{
+		"username":     q.Username,
+		"created_from": q.CreatedFrom,
+		"created_to":   q.CreatedTo,
+		"fields":       strings.Join(q.Fields, ","),
+	}
+	path := "/users"
+	if q.internal {
+		path = "/internal/users"
+		filters["email_domain"] = q.EmailDomain
+	}
+	for key, value := range filters {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	return fmt.Sprintf("%s%s?%s", ctx.HostName(), path, params.Encode())
+}
+
+func encodeCursor(c userCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*userCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c userCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if c.ID == "" {
+		return nil, fmt.Errorf("cursor has no id")
+	}
+	return &c, nil
+}
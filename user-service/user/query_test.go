@@ -0,0 +1,35 @@
+package user
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUserQueryFilterCreatedRange(t *testing.T) {
+	q := UserQuery{CreatedFrom: "2026-01-01T07:00:00+07:00", CreatedTo: "2026-01-02T00:00:00Z"}
+	createdAt := map[string]any{"$dateFromString": map[string]any{"dateString": "$createdat", "onError": nil}}
+	want := map[string]any{"$and": []any{
+		map[string]any{"$gte": []any{createdAt, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		map[string]any{"$lt": []any{createdAt, time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)}},
+	}}
+
+	filter := q.filter()
+	if !reflect.DeepEqual(filter["$expr"], want) {
+		t.Errorf("$expr = %v, want %v", filter["$expr"], want)
+	}
+	if _, ok := filter["createdat"]; ok {
+		t.Error("createdat is compared as a string")
+	}
+}
+
+func TestUserQueryFilterEmailDomain(t *testing.T) {
+	filter := UserQuery{EmailDomain: "example.com"}.filter()
+	want := map[string]any{"$regex": `@example\.com$`, "$options": "i"}
+	if !reflect.DeepEqual(filter["email"], want) {
+		t.Errorf("email = %v, want %v", filter["email"], want)
+	}
+	if _, ok := (UserQuery{}).filter()["email"]; ok {
+		t.Error("email is filtered without a domain")
+	}
+}
@@ -3,7 +3,6 @@ package user
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -12,18 +11,27 @@ import (
 	"github.com/google/uuid"
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
 	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-user-service/apperror"
+	"github.com/sing3demons/go-user-service/outbox"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Repository interface {
-	GetUserByID(ctx *kp.Context, id string) (*UserModel, error)
+	GetUserByID(ctx *kp.Context, id string, fields Fields) (*UserModel, error)
 	GetUser(ctx *kp.Context, key, value string) (*UserModel, error)
-	GetAllUsers(ctx *kp.Context) ([]*UserModel, error)
+	GetAllUsers(ctx *kp.Context, query UserQuery) ([]*UserModel, error)
 	CreateUser(ctx *kp.Context, user *UserModel) error
 	// UpdateUser(ctx *kp.Context, user *UserModel) error
+	UpdateAvatar(ctx *kp.Context, id, avatar, thumbnail string) error
 	DeleteUser(ctx *kp.Context, id string) error
+	RestoreUser(ctx *kp.Context, id string) error
+	EraseUser(ctx *kp.Context, id string, erasedAt time.Time) (*UserModel, error)
+	SetToken(ctx *kp.Context, id, field, nonceHash string) error
+	VerifyEmail(ctx *kp.Context, id, email, nonceHash string) error
+	ResetPassword(ctx *kp.Context, id, email, nonceHash, passwordHash string) error
 }
 
 type userRepository struct {
@@ -116,13 +124,30 @@ func (r *userRepository) CreateUser(ctx *kp.Context, user *UserModel) error {
 	start := time.Now()
 	id, err := uuid.NewV7()
 	if err != nil {
-		return err
+		return apperror.Internal(err)
 	}
 	user.ID = id.String()
-	user.CreatedAt = start.Format(time.RFC3339)
-	user.UpdatedAt = start.Format(time.RFC3339)
+	user.CreatedAt = start.UTC().Format(time.RFC3339)
+	user.UpdatedAt = start.UTC().Format(time.RFC3339Nano)
 	user.DeletedAt = nil
 
+	event, err := outbox.NewEvent(userCreatedTopic, UserEvent{
+		ID: user.ID,
+		Changes: map[string]any{
+			"first_name": user.FirstName,
+			"last_name":  user.LastName,
+			"username":   user.Username,
+			"email":      user.Email,
+			"created_at": user.CreatedAt,
+		},
+		At: user.CreatedAt,
+	})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	user.Outbox = []outbox.Event{event}
+	defer func() { user.Outbox = nil }()
+
 	processReqLog := ProcessMongoReq{
 		Collection: r.col.Name(),
 		Method:     "InsertOne",
@@ -158,7 +183,7 @@ func (r *userRepository) CreateUser(ctx *kp.Context, user *UserModel) error {
 		desc := err.Error()
 		if mongo.IsDuplicateKeyError(err) {
 			code = "409"
-			desc = "duplicate_key"
+			desc = "duplicate_" + duplicateField(err)
 		}
 		ctx.Log().SetSummary(logger.LogEventTag{
 			Node:        node,
@@ -170,7 +195,10 @@ func (r *userRepository) CreateUser(ctx *kp.Context, user *UserModel) error {
 			"Error": err.Error(),
 			"Raw":   processReqLog.RawString(),
 		})
-		return err
+		if mongo.IsDuplicateKeyError(err) {
+			return duplicateError(err)
+		}
+		return apperror.Internal(err)
 	}
 
 	ctx.Log().SetSummary(logger.LogEventTag{
@@ -186,7 +214,7 @@ func (r *userRepository) CreateUser(ctx *kp.Context, user *UserModel) error {
 	return nil
 }
 
-func (r *userRepository) GetUserByID(ctx *kp.Context, id string) (*UserModel, error) {
+func (r *userRepository) GetUserByID(ctx *kp.Context, id string, fields Fields) (*UserModel, error) {
 	desc := "get user by id"
 	cmd := "get_user_by_id"
 	node := "mongo"
@@ -197,13 +225,15 @@ func (r *userRepository) GetUserByID(ctx *kp.Context, id string) (*UserModel, er
 		"deleted_at": primitive.Null{},
 		"_id":        id,
 	}
+	// updatedat is always loaded, the handler derives the ETag from it.
+	opts := options.FindOne().SetProjection(fields.projection("updatedat"))
 
 	processReqLog := ProcessMongoReq{
 		Collection: r.col.Name(),
 		Method:     "FindOne",
 		Query:      filter,
 		Document:   nil,
-		Options:    nil,
+		Options:    opts,
 	}
 
 	maskingOption := []logger.MaskingOptionDto{
@@ -227,7 +257,7 @@ func (r *userRepository) GetUserByID(ctx *kp.Context, id string) (*UserModel, er
 	})
 
 	var user UserModel
-	err := r.col.FindOne(context.Background(), filter).Decode(&user)
+	err := r.col.FindOne(context.Background(), filter, opts).Decode(&user)
 	end := time.Since(start)
 
 	summary := logger.LogEventTag{
@@ -240,7 +270,7 @@ func (r *userRepository) GetUserByID(ctx *kp.Context, id string) (*UserModel, er
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			summary.Code = "404"
-			summary.Description = "data_not_found"
+			summary.Description = ErrUserNotFound.Code
 		} else {
 			summary.Code = "500"
 			summary.Description = err.Error()
@@ -249,9 +279,13 @@ func (r *userRepository) GetUserByID(ctx *kp.Context, id string) (*UserModel, er
 			"Error": err.Error(),
 			"Raw":   processReqLog.RawString(),
 		})
-		return nil, errors.New(summary.Description)
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUserNotFound
+		}
+		return nil, apperror.Internal(err)
 	}
 
+	user.fields = fields
 	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, desc), map[string]any{
 		"Return": user,
 	}, maskingOption...)
@@ -265,22 +299,29 @@ func (r *userRepository) getHostURI(ctx *kp.Context, value string) string {
 	return fmt.Sprintf("%s/users/%s", ctx.HostName(), value)
 }
 
-func (r *userRepository) GetAllUsers(ctx *kp.Context) ([]*UserModel, error) {
+// GetAllUsers returns one page of live users. It reads one user past
+// query.Limit so the caller can tell whether another page follows.
+func (r *userRepository) GetAllUsers(ctx *kp.Context, query UserQuery) ([]*UserModel, error) {
 	desc := "get all users"
 	cmd := "get_all_users"
 	node := "mongo"
 
 	start := time.Now()
-	filter := map[string]any{
-		"deleted_at": primitive.Null{},
-	}
+	filter := query.filter()
+	field, dir := query.sortField()
+	// The sort field is loaded even when not asked for: the next page
+	// cursor is built from it.
+	opts := options.Find().
+		SetSort(bson.D{{Key: field, Value: dir}, {Key: "_id", Value: dir}}).
+		SetLimit(int64(query.Limit + 1)).
+		SetProjection(query.Fields.projection(field))
 
 	processReqLog := ProcessMongoReq{
 		Collection: r.col.Name(),
 		Method:     "Find",
 		Query:      filter,
 		Document:   nil,
-		Options:    nil,
+		Options:    opts,
 	}
 
 	maskingOption := []logger.MaskingOptionDto{
@@ -313,8 +354,8 @@ func (r *userRepository) GetAllUsers(ctx *kp.Context) ([]*UserModel, error) {
 		"Raw":  processReqLog.RawString(),
 	})
 
-	var users []*UserModel
-	cursor, err := r.col.Find(context.Background(), filter)
+	users := []*UserModel{}
+	cursor, err := r.col.Find(context.Background(), filter, opts)
 	summary.ResTime = time.Since(start).Microseconds()
 	if err != nil {
 		summary.Code = "500"
@@ -323,7 +364,7 @@ func (r *userRepository) GetAllUsers(ctx *kp.Context) ([]*UserModel, error) {
 			"Error": err.Error(),
 			"Raw":   processReqLog.RawString(),
 		})
-		return nil, err
+		return nil, apperror.Internal(err)
 	}
 	defer cursor.Close(context.Background())
 
@@ -333,7 +374,7 @@ func (r *userRepository) GetAllUsers(ctx *kp.Context) ([]*UserModel, error) {
 		if err := cursor.Decode(&user); err != nil {
 			if err == mongo.ErrNoDocuments {
 				summary.Code = "404"
-				summary.Description = "data_not_found"
+				summary.Description = ErrUserNotFound.Code
 			} else {
 				summary.Code = "500"
 				summary.Description = err.Error()
@@ -342,9 +383,10 @@ func (r *userRepository) GetAllUsers(ctx *kp.Context) ([]*UserModel, error) {
 				"Error": err.Error(),
 				"Raw":   processReqLog.RawString(),
 			})
-			return nil, err
+			return nil, apperror.Internal(err)
 		}
 		user.Href = fmt.Sprintf("%s/users/%s", uri, user.ID)
+		user.fields = query.Fields
 		users = append(users, &user)
 	}
 
@@ -357,6 +399,83 @@ func (r *userRepository) GetAllUsers(ctx *kp.Context) ([]*UserModel, error) {
 
 // func (r *userRepository) UpdateUser(ctx *kp.Context, user *UserModel) error {}
 
+func (r *userRepository) UpdateAvatar(ctx *kp.Context, id, avatar, thumbnail string) error {
+	desc := "update user avatar"
+	cmd := "update_user_avatar"
+	node := "mongo"
+
+	start := time.Now()
+
+	filter := map[string]any{
+		"_id":        id,
+		"deleted_at": nil,
+	}
+	changes := map[string]any{
+		"avatar":           avatar,
+		"avatar_thumbnail": thumbnail,
+	}
+	event, err := outbox.NewEvent(userUpdatedTopic, UserEvent{ID: id, Changes: changes, At: start.UTC().Format(time.RFC3339)})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	update := map[string]any{
+		"$set": map[string]any{
+			"avatar":           avatar,
+			"avatar_thumbnail": thumbnail,
+			"updatedat":        start.UTC().Format(time.RFC3339Nano),
+		},
+		"$push": map[string]any{
+			"outbox": event,
+		},
+	}
+	processReqLog := ProcessMongoReq{
+		Collection: r.col.Name(),
+		Method:     "UpdateOne",
+		Query:      filter,
+		Document:   update,
+		Options:    nil,
+	}
+
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, desc), map[string]any{
+		"Body": processReqLog,
+		"Raw":  processReqLog.RawString(),
+	})
+
+	result, err := r.col.UpdateOne(context.Background(), filter, update)
+	end := time.Since(start)
+
+	summary := logger.LogEventTag{
+		Node:        node,
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+		ResTime:     end.Microseconds(),
+	}
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, err.Error()), map[string]any{
+			"Error": err.Error(),
+			"Raw":   processReqLog.RawString(),
+		})
+		return apperror.Internal(err)
+	}
+	if result.MatchedCount == 0 {
+		summary.Code = "404"
+		summary.Description = ErrUserNotFound.Code
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, desc), map[string]any{
+			"Return": result,
+		})
+		return ErrUserNotFound
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, desc), map[string]any{
+		"Return": result,
+	})
+
+	return nil
+}
+
 func (r *userRepository) DeleteUser(ctx *kp.Context, id string) error {
 	desc := "delete user by id"
 	cmd := "delete_user_by_id"
@@ -368,10 +487,17 @@ func (r *userRepository) DeleteUser(ctx *kp.Context, id string) error {
 		"_id":        id,
 		"deleted_at": nil,
 	}
+	event, err := outbox.NewEvent(userDeletedTopic, UserEvent{ID: id, At: start.UTC().Format(time.RFC3339)})
+	if err != nil {
+		return apperror.Internal(err)
+	}
 	update := map[string]interface{}{
 		"$set": map[string]interface{}{
 			"deleted_at": time.Now().UTC(),
 		},
+		"$push": map[string]interface{}{
+			"outbox": event,
+		},
 	}
 	opts := options.Update().SetUpsert(false)
 	processReqLog := ProcessMongoReq{
@@ -403,7 +529,15 @@ func (r *userRepository) DeleteUser(ctx *kp.Context, id string) error {
 		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.DELETE, err.Error()), map[string]any{
 			"error": err.Error(),
 		})
-		return err
+		return apperror.Internal(err)
+	}
+	if result.MatchedCount == 0 {
+		summary.Code = "404"
+		summary.Description = ErrUserNotFound.Code
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.DELETE, desc), map[string]any{
+			"Return": result,
+		})
+		return ErrUserNotFound
 	}
 
 	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.DELETE, desc), map[string]any{
@@ -413,6 +547,220 @@ func (r *userRepository) DeleteUser(ctx *kp.Context, id string) error {
 	return nil
 }
 
+func (r *userRepository) RestoreUser(ctx *kp.Context, id string) error {
+	desc := "restore user by id"
+	cmd := "restore_user_by_id"
+	node := "mongo"
+
+	start := time.Now()
+
+	filter := map[string]any{
+		"_id":        id,
+		"deleted_at": map[string]any{"$ne": nil},
+		"erased_at":  nil,
+	}
+	event, err := outbox.NewEvent(userUpdatedTopic, UserEvent{
+		ID:      id,
+		Changes: map[string]any{"deleted": false},
+		At:      start.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	update := map[string]any{
+		"$set": map[string]any{
+			"deleted_at": nil,
+			"updatedat":  start.UTC().Format(time.RFC3339Nano),
+		},
+		"$push": map[string]any{
+			"outbox": event,
+		},
+	}
+	processReqLog := ProcessMongoReq{
+		Collection: r.col.Name(),
+		Method:     "UpdateOne",
+		Query:      filter,
+		Document:   update,
+		Options:    nil,
+	}
+
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, desc), map[string]any{
+		"Body": processReqLog,
+		"Raw":  processReqLog.RawString(),
+	})
+
+	result, err := r.col.UpdateOne(context.Background(), filter, update)
+	end := time.Since(start)
+
+	summary := logger.LogEventTag{
+		Node:        node,
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+		ResTime:     end.Microseconds(),
+	}
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		if mongo.IsDuplicateKeyError(err) {
+			summary.Code = "409"
+			summary.Description = "duplicate_" + duplicateField(err)
+		}
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, err.Error()), map[string]any{
+			"Error": err.Error(),
+			"Raw":   processReqLog.RawString(),
+		})
+		if mongo.IsDuplicateKeyError(err) {
+			return duplicateError(err)
+		}
+		return apperror.Internal(err)
+	}
+	if result.MatchedCount == 0 {
+		summary.Code = "404"
+		summary.Description = ErrUserNotFound.Code
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, desc), map[string]any{
+			"Return": result,
+		})
+		return ErrUserNotFound
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, desc), map[string]any{
+		"Return": result,
+	})
+
+	return nil
+}
+
+// EraseUser replaces the personal fields of a user with placeholders and
+// marks it deleted. The user_erased event that tells the other services to
+// scrub their copies is written to the outbox by the same update, so an
+// erased user is never left without it. It returns the document as it was
+// before the update so the caller can clean up what it referenced, e.g. the
+// avatar files. Erasing an already erased user is not an error.
+func (r *userRepository) EraseUser(ctx *kp.Context, id string, erasedAt time.Time) (*UserModel, error) {
+	desc := "erase user by id"
+	cmd := "erase_user_by_id"
+	node := "mongo"
+
+	start := time.Now()
+	now := erasedAt.UTC()
+
+	filter := map[string]any{
+		"_id": id,
+	}
+	event, err := outbox.NewEvent(userDeletedTopic, UserEvent{
+		ID:      id,
+		Changes: map[string]any{"erased": true},
+		At:      now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	erased, err := outbox.NewEvent(userErasedTopic, UserErasedEvent{
+		UserID:   id,
+		ErasedAt: now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	update := map[string]any{
+		"$set": map[string]any{
+			"firstname":  "",
+			"lastname":   "",
+			"username":   "erased-" + id,
+			"email":      "erased-" + id + "@erased.invalid",
+			"updatedat":  start.UTC().Format(time.RFC3339Nano),
+			"deleted_at": now,
+			"erased_at":  now,
+		},
+		"$unset": map[string]any{
+			"avatar":            "",
+			"avatar_thumbnail":  "",
+			"email_verified_at": "",
+			"password_hash":     "",
+			"verify_token":      "",
+			"reset_token":       "",
+		},
+		"$push": map[string]any{
+			"outbox": map[string]any{"$each": []outbox.Event{event, erased}},
+		},
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.Before).
+		SetProjection(Fields(nil).projection())
+	processReqLog := ProcessMongoReq{
+		Collection: r.col.Name(),
+		Method:     "FindOneAndUpdate",
+		Query:      filter,
+		Document:   update,
+		Options:    opts,
+	}
+
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, desc), map[string]any{
+		"Body": processReqLog,
+		"Raw":  processReqLog.RawString(),
+	})
+
+	var user UserModel
+	err = r.col.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&user)
+	end := time.Since(start)
+
+	summary := logger.LogEventTag{
+		Node:        node,
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+		ResTime:     end.Microseconds(),
+	}
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			summary.Code = "404"
+			summary.Description = ErrUserNotFound.Code
+		} else {
+			summary.Code = "500"
+			summary.Description = err.Error()
+		}
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, err.Error()), map[string]any{
+			"Error": err.Error(),
+			"Raw":   processReqLog.RawString(),
+		})
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrUserNotFound
+		}
+		return nil, apperror.Internal(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, desc), map[string]any{
+		"Return": map[string]any{
+			"id":        user.ID,
+			"erased_at": now.Format(time.RFC3339),
+		},
+	})
+
+	return &user, nil
+}
+
+// duplicateError turns a duplicate key error into a conflict naming the
+// field that clashed.
+func duplicateError(err error) error {
+	field := duplicateField(err)
+	return apperror.Conflict("duplicate_key", field, field+" is already used by another user").Wrap(err)
+}
+
+// duplicateField reports which unique index a duplicate key error hit,
+// e.g. "email" for unique_email_if_not_deleted.
+func duplicateField(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "unique_email_if_not_deleted"):
+		return "email"
+	case strings.Contains(msg, "unique_username_if_not_deleted"):
+		return "username"
+	default:
+		return "key"
+	}
+}
+
 // username or email
 func (r *userRepository) GetUser(ctx *kp.Context, key, value string) (*UserModel, error) {
 	desc := "find user by " + key
@@ -447,7 +795,7 @@ func (r *userRepository) GetUser(ctx *kp.Context, key, value string) (*UserModel
 	})
 
 	var user UserModel
-	err := r.col.FindOne(context.Background(), filter).Decode(&user)
+	err := r.col.FindOne(context.Background(), filter, options.FindOne().SetProjection(Fields(nil).projection())).Decode(&user)
 	end := time.Since(start)
 
 	summary := logger.LogEventTag{
@@ -460,12 +808,12 @@ func (r *userRepository) GetUser(ctx *kp.Context, key, value string) (*UserModel
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			summary.Code = "404"
-			summary.Description = "data_not_found"
+			summary.Description = ErrUserNotFound.Code
 			ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.QUERY, err.Error()), map[string]any{
 				"Error": err.Error(),
 				"Raw":   processReqLog.RawString(),
 			})
-			return nil, errors.New(summary.Description)
+			return nil, ErrUserNotFound
 		}
 		summary.Code = "500"
 		summary.Description = err.Error()
@@ -473,7 +821,7 @@ func (r *userRepository) GetUser(ctx *kp.Context, key, value string) (*UserModel
 			"Error": err.Error(),
 			"Raw":   processReqLog.RawString(),
 		})
-		return nil, err
+		return nil, apperror.Internal(err)
 	}
 	user.Href = r.getHostURI(ctx, user.ID)
 
@@ -483,3 +831,131 @@ func (r *userRepository) GetUser(ctx *kp.Context, key, value string) (*UserModel
 
 	return &user, nil
 }
+
+// SetToken stores the nonce hash of a newly issued token in field,
+// verify_token or reset_token, which invalidates the token issued before.
+func (r *userRepository) SetToken(ctx *kp.Context, id, field, nonceHash string) error {
+	filter := map[string]any{
+		"_id":        id,
+		"deleted_at": nil,
+	}
+	update := map[string]any{
+		"$set": map[string]any{
+			field: nonceHash,
+		},
+	}
+	return r.updateAccount(ctx, "set_user_"+field, "set user "+strings.ReplaceAll(field, "_", " "), filter, update, ErrUserNotFound)
+}
+
+// VerifyEmail marks the email of the user verified and spends the
+// verification token with nonceHash. It fails with ErrInvalidToken when
+// the token was spent or replaced, or the email has changed since.
+func (r *userRepository) VerifyEmail(ctx *kp.Context, id, email, nonceHash string) error {
+	now := time.Now().UTC()
+	event, err := outbox.NewEvent(userUpdatedTopic, UserEvent{
+		ID:      id,
+		Changes: map[string]any{"email_verified_at": now.Format(time.RFC3339)},
+		At:      now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return apperror.Internal(err)
+	}
+	filter := map[string]any{
+		"_id":          id,
+		"deleted_at":   nil,
+		"email":        email,
+		"verify_token": nonceHash,
+	}
+	update := map[string]any{
+		"$set": map[string]any{
+			"email_verified_at": now,
+			"updatedat":         now.UTC().Format(time.RFC3339Nano),
+		},
+		"$unset": map[string]any{
+			"verify_token": "",
+		},
+		"$push": map[string]any{
+			"outbox": event,
+		},
+	}
+	return r.updateAccount(ctx, "verify_user_email", "verify user email", filter, update, ErrInvalidToken)
+}
+
+// ResetPassword replaces the password hash of the user and spends the
+// reset token with nonceHash, failing like VerifyEmail when it cannot.
+func (r *userRepository) ResetPassword(ctx *kp.Context, id, email, nonceHash, passwordHash string) error {
+	filter := map[string]any{
+		"_id":         id,
+		"deleted_at":  nil,
+		"email":       email,
+		"reset_token": nonceHash,
+	}
+	update := map[string]any{
+		"$set": map[string]any{
+			"password_hash": passwordHash,
+			"updatedat":     time.Now().UTC().Format(time.RFC3339Nano),
+		},
+		"$unset": map[string]any{
+			"reset_token": "",
+		},
+	}
+	return r.updateAccount(ctx, "reset_user_password", "reset user password", filter, update, ErrInvalidToken)
+}
+
+// updateAccount applies an update to the user matching filter, returning
+// noMatch when there is none. Token hashes and password hashes are kept
+// out of the log.
+func (r *userRepository) updateAccount(ctx *kp.Context, cmd, desc string, filter, update map[string]any, noMatch *apperror.Error) error {
+	start := time.Now()
+	processReqLog := ProcessMongoReq{
+		Collection: r.col.Name(),
+		Method:     "UpdateOne",
+		Query:      filter,
+		Document:   update,
+		Options:    nil,
+	}
+
+	maskingOption := []logger.MaskingOptionDto{
+		{MaskingField: "Body.query.email", MaskingType: logger.Email},
+		{MaskingField: "Body.query.verify_token", MaskingType: logger.Full},
+		{MaskingField: "Body.query.reset_token", MaskingType: logger.Full},
+		{MaskingField: "Body.document.$set.verify_token", MaskingType: logger.Full},
+		{MaskingField: "Body.document.$set.reset_token", MaskingType: logger.Full},
+		{MaskingField: "Body.document.$set.password_hash", MaskingType: logger.Full},
+	}
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, desc), map[string]any{
+		"Body": processReqLog,
+	}, maskingOption...)
+
+	result, err := r.col.UpdateOne(context.Background(), filter, update)
+	end := time.Since(start)
+
+	summary := logger.LogEventTag{
+		Node:        "mongo",
+		Command:     cmd,
+		Code:        "200",
+		Description: "success",
+		ResTime:     end.Microseconds(),
+	}
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, err.Error()), map[string]any{
+			"Error": err.Error(),
+		})
+		return apperror.Internal(err)
+	}
+	if result.MatchedCount == 0 {
+		summary.Code = fmt.Sprintf("%d", noMatch.Status())
+		summary.Description = noMatch.Code
+		ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(logger.UPDATE, desc), map[string]any{
+			"Return": result,
+		})
+		return noMatch
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, desc), map[string]any{
+		"Return": result,
+	})
+	return nil
+}
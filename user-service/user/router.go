@@ -1,50 +1,49 @@
 package user
 
 import (
-	"context"
-
 	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/sing3demons/go-user-service/address"
+	"github.com/sing3demons/go-user-service/media"
+	"github.com/sing3demons/go-user-service/servicetoken"
+	"github.com/sing3demons/go-user-service/wishlist"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func RegisterRoutes(app kp.IApplication, col *mongo.Collection) {
-	indexEmailModel := mongo.IndexModel{
-		Keys: bson.D{
-			{Key: "email", Value: 1},
-		},
-		Options: options.Index().
-			SetName("unique_email_if_not_deleted").
-			SetUnique(true).
-			SetPartialFilterExpression(bson.D{
-				{Key: "deleted_at", Value: bson.D{{Key: "$eq", Value: nil}}},
-			}),
-	}
-
-	indexUsernameModel := mongo.IndexModel{
-		Keys: bson.D{
-			{Key: "username", Value: 1},
-		},
-		Options: options.Index().
-			SetName("unique_username_if_not_deleted").
-			SetUnique(true).
-			SetPartialFilterExpression(bson.D{
-				{Key: "deleted_at", Value: bson.D{{Key: "$eq", Value: nil}}},
-			}),
-	}
-
-	col.Indexes().CreateMany(context.Background(), []mongo.IndexModel{indexEmailModel, indexUsernameModel})
+func RegisterRoutes(app kp.IApplication, col *mongo.Collection, mediaSvc media.Service, addresses address.Repository, wishlists wishlist.Repository, accounts AccountOptions, guard *servicetoken.Guard) {
 	repo := NewUserRepository(col)
-	svc := NewUserService(repo)
+	svc := NewUserService(repo, mediaSvc, addresses, wishlists, accounts)
 	handler := NewHandler(svc)
 
 	// User routes
 	app.Post("/users", handler.CreateUser)
-	// username or email
+	liveUser := func(ctx *kp.Context, id string) error {
+		_, err := svc.GetUserByID(ctx, id, Fields{"id"})
+		return err
+	}
+	address.RegisterRoutes(app, addresses, liveUser, guard)
+	wishlist.RegisterRoutes(app, wishlists, liveUser)
+	// username; by email only on the internal route
 	app.Get("/users/{key}/{value}", handler.GetUser)
 	app.Get("/users/{id}", handler.GetUserByID)
 	app.Get("/users", handler.GetAllUsers)
 	app.Delete("/users/{id}", handler.DeleteUser)
+	app.Post("/users/{id}/avatar", handler.UploadAvatar)
+	app.Post("/users/{id}/restore", handler.RestoreUser)
+	app.Post("/users/{id}/verification", handler.SendVerification)
+	app.Post("/users/{id}/verify", handler.VerifyEmail)
+	app.Post("/password/forgot", handler.ForgotPassword)
+	app.Post("/password/reset", handler.ResetPassword)
 
+	// Internal routes for the other services, see servicetoken. The public
+	// reads only serve publicFields; these serve the whole user. Exporting
+	// and erasing a user's data, and listing users by email domain, is left
+	// to an operator with an admin token from the "token" subcommand, since
+	// there is no user login to check whose data it is.
+	// export is registered before {key}/{value}, which would match it otherwise
+	admin := guard.Admin()
+	app.Get("/internal/users/{id}/export", admin.Require(handler.ExportUser))
+	app.Post("/internal/users/{id}/erase", admin.Require(handler.EraseUser))
+	app.Get("/internal/users", admin.Require(handler.GetInternalUsers))
+	app.Get("/internal/users/{key}/{value}", guard.Require(handler.GetInternalUser))
+	app.Get("/internal/users/{id}", guard.Require(handler.GetInternalUserByID))
 }
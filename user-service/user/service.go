@@ -1,35 +1,94 @@
 package user
 
-import "github.com/sing3demons/go-common-kp/kp/pkg/kp"
+import (
+	"mime/multipart"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-user-service/address"
+	"github.com/sing3demons/go-user-service/apperror"
+	"github.com/sing3demons/go-user-service/media"
+	"github.com/sing3demons/go-user-service/wishlist"
+)
 
- 
 type Service interface {
 	CreateUser(ctx *kp.Context, user *UserModel) error
-	GetUserByID(ctx *kp.Context, id string) (*UserModel, error)
-	GetAllUsers(ctx *kp.Context) ([]*UserModel, error)
+	GetUserByID(ctx *kp.Context, id string, fields Fields) (*UserModel, error)
+	GetAllUsers(ctx *kp.Context, query UserQuery) (*UserPage, error)
 	DeleteUser(ctx *kp.Context, id string) error
 	GetUser(ctx *kp.Context, key, value string) (*UserModel, error)
+	UploadAvatar(ctx *kp.Context, id string, file *multipart.FileHeader) (*UserModel, error)
+	RestoreUser(ctx *kp.Context, id string) (*UserModel, error)
+	ExportUser(ctx *kp.Context, id string) (*UserExport, error)
+	EraseUser(ctx *kp.Context, id string) (*UserErasedEvent, error)
+	SendVerification(ctx *kp.Context, id string) error
+	VerifyEmail(ctx *kp.Context, id string, req VerifyRequest) (*UserModel, error)
+	ForgotPassword(ctx *kp.Context, req ForgotPasswordRequest) error
+	ResetPassword(ctx *kp.Context, req ResetPasswordRequest) error
 }
 
+const (
+	userCreatedTopic = "user_created"
+	userUpdatedTopic = "user_updated"
+	userDeletedTopic = "user_deleted"
+	userErasedTopic  = "user_erased"
+)
+
+var (
+	ErrUserNotFound = apperror.NotFound("user_not_found", "user not found")
+)
+
 type userService struct {
-	repo Repository
+	repo      Repository
+	media     media.Service
+	addresses address.Repository
+	wishlists wishlist.Repository
+	accounts  AccountOptions
+	tokens    tokenSigner
 }
 
-func NewUserService(repo Repository) Service {
+func NewUserService(repo Repository, mediaSvc media.Service, addresses address.Repository, wishlists wishlist.Repository, accounts AccountOptions) Service {
 	return &userService{
-		repo: repo,
+		repo:      repo,
+		media:     mediaSvc,
+		addresses: addresses,
+		wishlists: wishlists,
+		accounts:  accounts,
+		tokens:    tokenSigner{secret: []byte(accounts.Secret)},
 	}
 }
 
+// CreateUser stores the user and mails the address verification link. The
+// user is created even when the mail fails; POST /users/{id}/verification
+// sends it again.
 func (s *userService) CreateUser(ctx *kp.Context, user *UserModel) error {
-	return s.repo.CreateUser(ctx, user)
+	if err := newAccount(user); err != nil {
+		return err
+	}
+	if err := s.repo.CreateUser(ctx, user); err != nil {
+		return err
+	}
+	_ = s.sendVerification(ctx, user)
+	return nil
 }
 
-func (s *userService) GetUserByID(ctx *kp.Context, id string) (*UserModel, error) {
-	return s.repo.GetUserByID(ctx, id)
+func (s *userService) GetUserByID(ctx *kp.Context, id string, fields Fields) (*UserModel, error) {
+	return s.repo.GetUserByID(ctx, id, fields)
 }
-func (s *userService) GetAllUsers(ctx *kp.Context) ([]*UserModel, error) {
-	return s.repo.GetAllUsers(ctx)
+func (s *userService) GetAllUsers(ctx *kp.Context, query UserQuery) (*UserPage, error) {
+	users, err := s.repo.GetAllUsers(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{Next: query.next(ctx, users)}
+	if len(users) > query.Limit {
+		users = users[:query.Limit]
+	}
+	page.Users = users
+	page.Count = len(users)
+	return page, nil
 }
 
 func (s *userService) DeleteUser(ctx *kp.Context, id string) error {
@@ -38,4 +97,95 @@ func (s *userService) DeleteUser(ctx *kp.Context, id string) error {
 
 func (s *userService) GetUser(ctx *kp.Context, key, value string) (*UserModel, error) {
 	return s.repo.GetUser(ctx, key, value)
-}
\ No newline at end of file
+}
+
+func (s *userService) UploadAvatar(ctx *kp.Context, id string, file *multipart.FileHeader) (*UserModel, error) {
+	user, err := s.repo.GetUserByID(ctx, id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := s.media.Upload(ctx, "avatars/"+id, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateAvatar(ctx, id, m.URL, m.ThumbnailURL); err != nil {
+		s.media.Delete(ctx, m.Key)
+		s.media.Delete(ctx, m.ThumbnailKey)
+		return nil, err
+	}
+
+	// The replaced avatar is no longer referenced; a failure to delete it
+	// is logged by the media service and leaves an orphan behind.
+	for _, old := range []string{user.Avatar, user.AvatarThumbnail} {
+		if key := s.media.KeyOf(old); key != "" && strings.HasPrefix(key, "avatars/"+id+"/") {
+			s.media.Delete(ctx, key)
+		}
+	}
+
+	user.Avatar = m.URL
+	user.AvatarThumbnail = m.ThumbnailURL
+	return user, nil
+}
+
+func (s *userService) RestoreUser(ctx *kp.Context, id string) (*UserModel, error) {
+	if err := s.repo.RestoreUser(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserByID(ctx, id, nil)
+}
+
+func (s *userService) ExportUser(ctx *kp.Context, id string) (*UserExport, error) {
+	user, err := s.repo.GetUserByID(ctx, id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := s.addresses.FindByUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	wishlists, err := s.wishlists.FindByUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := getCustomerData(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserExport{
+		ExportedAt:   time.Now().UTC().Format(time.RFC3339),
+		User:         user,
+		Addresses:    addresses,
+		Wishlists:    wishlists,
+		Orders:       data.Orders,
+		OrderHistory: data.OrderHistory,
+	}, nil
+}
+
+func (s *userService) EraseUser(ctx *kp.Context, id string) (*UserErasedEvent, error) {
+	at := time.Now().UTC()
+	user, err := s.repo.EraseUser(ctx, id, at)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Avatar != "" {
+		s.media.DeletePrefix(ctx, "avatars/"+id)
+	}
+	if _, err := s.addresses.DeleteByUser(ctx, id); err != nil {
+		return nil, err
+	}
+	if _, err := s.wishlists.DeleteByUser(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return &UserErasedEvent{
+		UserID:   id,
+		ErasedAt: at.Format(time.RFC3339),
+	}, nil
+}
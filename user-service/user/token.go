@@ -0,0 +1,98 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-user-service/apperror"
+)
+
+// Token purposes. The purpose is signed with the token so a verification
+// token cannot be spent as a password reset and the other way round.
+const (
+	purposeVerifyEmail   = "verify_email"
+	purposeResetPassword = "reset_password"
+)
+
+var (
+	ErrInvalidToken = apperror.Validation("invalid_token", "the token is invalid or has already been used")
+	ErrTokenExpired = apperror.Validation("token_expired", "the token has expired; ask for a new one")
+)
+
+// tokenClaims is the signed payload of a verification or reset token. The
+// nonce is what makes it single use: its hash is stored on the user and
+// removed when the token is spent or a newer token is issued.
+type tokenClaims struct {
+	Purpose   string `json:"pur"`
+	UserID    string `json:"sub"`
+	Email     string `json:"email"`
+	Nonce     string `json:"nonce"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// tokenSigner issues and checks tokens of the form
+// base64url(claims) "." base64url(HMAC-SHA256(claims)).
+type tokenSigner struct {
+	secret []byte
+}
+
+// issue returns a token for the user and the hash of its nonce to store.
+func (s tokenSigner) issue(purpose, userID, email string, ttl time.Duration) (token, nonceHash string, err error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", "", err
+	}
+	claims := tokenClaims{
+		Purpose:   purpose,
+		UserID:    userID,
+		Email:     email,
+		Nonce:     hex.EncodeToString(nonce),
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.signature(encoded), hashNonce(claims.Nonce), nil
+}
+
+// parse checks the signature, purpose and expiry of token. Whether it was
+// already spent is up to the caller, by matching the nonce hash.
+func (s tokenSigner) parse(token, purpose string, now time.Time) (*tokenClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.signature(encoded))) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Purpose != purpose {
+		return nil, ErrInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	return &claims, nil
+}
+
+func (s tokenSigner) signature(encoded string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// hashNonce is what is stored of a nonce. Like a password hash, it lets a
+// token be checked without the database holding what is needed to spend it.
+func hashNonce(nonce string) string {
+	sum := sha256.Sum256([]byte(nonce))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,238 @@
+package wishlist
+
+import (
+	"net/http"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-user-service/apperror"
+	"github.com/sing3demons/go-user-service/validation"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler {
+	return &Handler{
+		svc: svc,
+	}
+}
+
+func (h *Handler) ListWishlists(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	summary := logger.EventTag("client", "list_wishlists", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID},
+	})
+
+	wishlists, err := h.svc.ListWishlists(ctx, userID)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	for _, wishlist := range wishlists {
+		h.setHref(ctx, wishlist)
+	}
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"wishlists": wishlists,
+		"count":     len(wishlists),
+	})
+}
+
+func (h *Handler) GetWishlist(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	id := ctx.PathParam("wishlist_id")
+	summary := logger.EventTag("client", "get_wishlist", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("wishlist_id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID, "wishlist_id": id},
+	})
+
+	wishlist, err := h.svc.GetWishlist(ctx, userID, id)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	h.setHref(ctx, wishlist)
+	return ctx.JSON(http.StatusOK, wishlist)
+}
+
+func (h *Handler) CreateWishlist(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	summary := logger.EventTag("client", "create_wishlist", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var body WishlistRequest
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID},
+		"Body":  body,
+	})
+
+	wishlist, err := h.svc.CreateWishlist(ctx, userID, body)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	h.setHref(ctx, wishlist)
+	return ctx.JSON(http.StatusCreated, wishlist)
+}
+
+func (h *Handler) RenameWishlist(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	id := ctx.PathParam("wishlist_id")
+	summary := logger.EventTag("client", "rename_wishlist", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("wishlist_id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var body WishlistRequest
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID, "wishlist_id": id},
+		"Body":  body,
+	})
+
+	wishlist, err := h.svc.RenameWishlist(ctx, userID, id, body)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	h.setHref(ctx, wishlist)
+	return ctx.JSON(http.StatusOK, wishlist)
+}
+
+func (h *Handler) DeleteWishlist(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	id := ctx.PathParam("wishlist_id")
+	summary := logger.EventTag("client", "delete_wishlist", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("wishlist_id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID, "wishlist_id": id},
+	})
+
+	if err := h.svc.DeleteWishlist(ctx, userID, id); err != nil {
+		return apperror.Write(ctx, err)
+	}
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	return ctx.JSON(http.StatusOK, map[string]string{
+		"message": "delete_success",
+		"id":      id,
+	})
+}
+
+func (h *Handler) AddItem(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	id := ctx.PathParam("wishlist_id")
+	summary := logger.EventTag("client", "add_wishlist_item", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("wishlist_id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var body ItemRequest
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID, "wishlist_id": id},
+		"Body":  body,
+	})
+
+	wishlist, err := h.svc.AddItem(ctx, userID, id, body)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	h.setHref(ctx, wishlist)
+	return ctx.JSON(http.StatusOK, wishlist)
+}
+
+func (h *Handler) RemoveItem(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	id := ctx.PathParam("wishlist_id")
+	productID := ctx.PathParam("product_id")
+	summary := logger.EventTag("client", "remove_wishlist_item", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("wishlist_id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("product_id", productID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID, "wishlist_id": id, "product_id": productID},
+	})
+
+	wishlist, err := h.svc.RemoveItem(ctx, userID, id, productID)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	h.setHref(ctx, wishlist)
+	return ctx.JSON(http.StatusOK, wishlist)
+}
+
+func (h *Handler) ReorderItems(ctx *kp.Context) error {
+	userID := ctx.PathParam("id")
+	id := ctx.PathParam("wishlist_id")
+	summary := logger.EventTag("client", "reorder_wishlist_items", "200", "")
+
+	if err := validation.Var("id", userID, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	if err := validation.Var("wishlist_id", id, "required"); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	var body OrderRequest
+	if err := validation.Bind(ctx, &body); err != nil {
+		return validation.Respond(ctx, summary, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewInbound(summary.Command, ""), map[string]any{
+		"Param": map[string]string{"id": userID, "wishlist_id": id},
+		"Body":  body,
+	})
+
+	wishlist, err := h.svc.ReorderItems(ctx, userID, id, body)
+	ctx.Header().Set("x-rid", ctx.RequestId())
+	if err != nil {
+		return apperror.Write(ctx, err)
+	}
+	h.setHref(ctx, wishlist)
+	return ctx.JSON(http.StatusOK, wishlist)
+}
+
+func (h *Handler) setHref(ctx *kp.Context, wishlist *Wishlist) {
+	wishlist.Href = ctx.HostName() + "/users/" + wishlist.UserID + "/wishlists/" + wishlist.ID
+}
@@ -0,0 +1,50 @@
+package wishlist
+
+// Wishlist is a named list of products a user saved, e.g. a wishlist or
+// "saved for later". Items keep the order the user gave them.
+type Wishlist struct {
+	ID        string  `json:"id" bson:"_id"`
+	Href      string  `json:"href,omitempty" bson:"-"`
+	UserID    string  `json:"user_id" bson:"user_id"`
+	Name      string  `json:"name" bson:"name"`
+	Items     []*Item `json:"items" bson:"items"`
+	CreatedAt string  `json:"created_at" bson:"created_at"`
+	UpdatedAt string  `json:"updated_at" bson:"updated_at"`
+}
+
+// Item is a product on a wishlist. Only the product id is stored; name and
+// price are read from product-service every time the list is returned.
+type Item struct {
+	ProductID string `json:"product_id" bson:"product_id"`
+	AddedAt   string `json:"added_at" bson:"added_at"`
+	Name      string `json:"name,omitempty" bson:"-"`
+	Price     string `json:"price,omitempty" bson:"-"`
+	// Deleted is set when the product was deleted, or is gone from
+	// product-service altogether, since it was saved.
+	Deleted bool `json:"deleted,omitempty" bson:"-"`
+}
+
+// WishlistRequest is the body of POST /users/{id}/wishlists and of
+// PUT /users/{id}/wishlists/{wishlist_id}, which renames the list.
+type WishlistRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// ItemRequest is the body of POST /users/{id}/wishlists/{wishlist_id}/items.
+type ItemRequest struct {
+	ProductID string `json:"product_id" validate:"required,uuid"`
+}
+
+// OrderRequest is the body of PUT /users/{id}/wishlists/{wishlist_id}/items.
+// It lists every product of the wishlist once, in the new order.
+type OrderRequest struct {
+	ProductIDs []string `json:"product_ids" validate:"required,max=100,unique,dive,uuid"`
+}
+
+// product is the part of a product-service product a wishlist shows.
+type product struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Price     string  `json:"price"`
+	DeletedAt *string `json:"deletedAt,omitempty"`
+}
@@ -0,0 +1,156 @@
+package wishlist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-user-service/apperror"
+	"github.com/sing3demons/go-user-service/servicetoken"
+)
+
+type HttpRequest struct {
+	URL      string            `json:"url"`
+	Headers  map[string]string `json:"headers"`
+	Params   map[string]string `json:"params"`
+	Protocol string            `json:"protocol"`
+	Method   string            `json:"method"`
+	Timeout  time.Duration     `json:"timeout"`
+}
+
+const (
+	contentTypeHeader = "Content-Type"
+	// productBatch is the most ids product-service takes in one ids= lookup.
+	productBatch = 100
+)
+
+var errProductService = apperror.Upstream("product_service_error", "product-service could not provide the wishlist products", nil)
+
+// getProducts looks the products up on the internal route of
+// product-service, soft-deleted ones included, and returns them by id. An id product-service does not know is
+// missing from the map.
+func getProducts(ctx *kp.Context, ids []string) (map[string]*product, error) {
+	products := make(map[string]*product, len(ids))
+	for start := 0; start < len(ids); start += productBatch {
+		end := min(start+productBatch, len(ids))
+		batch, err := findProducts(ctx, ids[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range batch {
+			products[p.ID] = p
+		}
+	}
+	return products, nil
+}
+
+func findProducts(ctx *kp.Context, ids []string) ([]*product, error) {
+	start := time.Now()
+	summary := logger.LogEventTag{
+		Node:        "product_service",
+		Command:     "find_products",
+		Code:        "200",
+		Description: "success",
+	}
+
+	productServiceURL := os.Getenv("PRODUCT_SERVICE_URL")
+	if productServiceURL == "" {
+		productServiceURL = "http://localhost:8082" // Default URL if not set
+	}
+
+	params := map[string]string{
+		"ids":             strings.Join(ids, ","),
+		"include_deleted": "true",
+		"fields":          "id,name,price,deletedAt",
+	}
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	httpRequest := HttpRequest{
+		URL:      productServiceURL + "/internal/products?" + query.Encode(),
+		Headers:  map[string]string{contentTypeHeader: "application/json"},
+		Params:   params,
+		Protocol: "http",
+		Method:   http.MethodGet,
+		Timeout:  5 * time.Second,
+	}
+
+	ctx.Log().Info(logger.NewHTTPRequest("find products", ""), map[string]any{
+		"uri":      httpRequest.URL,
+		"headers":  httpRequest.Headers,
+		"params":   httpRequest.Params,
+		"protocol": httpRequest.Protocol,
+		"method":   httpRequest.Method,
+		"timeout":  httpRequest.Timeout,
+	})
+	req, err := http.NewRequest(http.MethodGet, httpRequest.URL, nil)
+	if err != nil {
+		return nil, errProductService.Wrap(err)
+	}
+	req.Header.Set(contentTypeHeader, httpRequest.Headers[contentTypeHeader])
+	if err := servicetoken.Authorize(req, servicetoken.ProductService); err != nil {
+		return nil, errProductService.Wrap(err)
+	}
+
+	httpClient := &http.Client{
+		Timeout: httpRequest.Timeout,
+	}
+
+	resp, err := httpClient.Do(req)
+	summary.ResTime = time.Since(start).Milliseconds()
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to find products"
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("http find products", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, errProductService.Wrap(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		summary.Code = fmt.Sprintf("%d", resp.StatusCode)
+		summary.Description = resp.Status
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("find products failed", ""), map[string]string{
+			"error": fmt.Sprintf("failed to find products: %s", resp.Status),
+		})
+		return nil, errProductService.Wrap(fmt.Errorf("failed to find products: %s", resp.Status))
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		summary.Code = "500"
+		summary.Description = "failed to read response body"
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("find products failed", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, errProductService.Wrap(err)
+	}
+
+	var body struct {
+		Products []*product `json:"products"`
+	}
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		summary.Code = "500"
+		summary.Description = err.Error()
+		ctx.Log().SetSummary(summary).Error(logger.NewHTTPResponse("find products failed", ""), map[string]string{
+			"error": err.Error(),
+		})
+		return nil, errProductService.Wrap(err)
+	}
+
+	ctx.Log().SetSummary(summary).Info(logger.NewHTTPResponse("find products success", ""), map[string]any{
+		"Headers": resp.Header,
+		"Status":  resp.Status,
+		"Body":    body,
+	})
+	return body.Products, nil
+}
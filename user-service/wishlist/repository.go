@@ -0,0 +1,292 @@
+package wishlist
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-common-kp/kp/pkg/logger"
+	"github.com/sing3demons/go-user-service/apperror"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repository stores wishlists in the wishlists collection, one document per
+// list with its items embedded. A unique index keeps list names distinct
+// per user.
+type Repository interface {
+	Create(ctx *kp.Context, wishlist *Wishlist) error
+	FindByUser(ctx *kp.Context, userID string) ([]*Wishlist, error)
+	FindByID(ctx *kp.Context, userID, id string) (*Wishlist, error)
+	Rename(ctx *kp.Context, userID, id, name string) (*Wishlist, error)
+	Delete(ctx *kp.Context, userID, id string) error
+	Count(ctx *kp.Context, userID string) (int64, error)
+	AddItem(ctx *kp.Context, userID, id string, item *Item) (*Wishlist, error)
+	RemoveItem(ctx *kp.Context, userID, id, productID string) (*Wishlist, error)
+	SetItems(ctx *kp.Context, userID, id string, items []*Item) (*Wishlist, error)
+	DeleteByUser(ctx *kp.Context, userID string) (int64, error)
+}
+
+type repository struct {
+	col *mongo.Collection
+}
+
+func NewRepository(col *mongo.Collection) Repository {
+	return &repository{
+		col: col,
+	}
+}
+
+var (
+	ErrWishlistNotFound = apperror.NotFound("wishlist_not_found", "wishlist not found")
+	ErrNameTaken        = apperror.Conflict("wishlist_name_taken", "name", "the user already has a wishlist with this name")
+)
+
+// fail logs a failed operation and returns the error to give the caller:
+// ErrWishlistNotFound for no document, ErrNameTaken for a duplicate name
+// and an internal error otherwise.
+func fail(ctx *kp.Context, summary logger.LogEventTag, op logger.DBActionEnum, err error) error {
+	result := apperror.Internal(err)
+	summary.Code = "500"
+	summary.Description = err.Error()
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		result = ErrWishlistNotFound
+		summary.Code = "404"
+		summary.Description = ErrWishlistNotFound.Code
+	case mongo.IsDuplicateKeyError(err):
+		result = ErrNameTaken.Wrap(err)
+		summary.Code = "409"
+		summary.Description = ErrNameTaken.Code
+	}
+	ctx.Log().SetSummary(summary).Error(logger.NewDBResponse(op, summary.Command+" failed"), map[string]any{
+		"Error": err.Error(),
+	})
+	return result
+}
+
+func (r *repository) Create(ctx *kp.Context, wishlist *Wishlist) error {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "insert_wishlist", "200", "success")
+	ctx.Log().Info(logger.NewDBRequest(logger.INSERT, "insert wishlist"), map[string]any{
+		"collection": r.col.Name(),
+		"document":   wishlist,
+	})
+
+	_, err := r.col.InsertOne(ctx, wishlist)
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return fail(ctx, summary, logger.INSERT, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.INSERT, "insert wishlist success"), map[string]any{
+		"id": wishlist.ID,
+	})
+	return nil
+}
+
+func (r *repository) FindByUser(ctx *kp.Context, userID string) ([]*Wishlist, error) {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "find_wishlists", "200", "success")
+	filter := bson.M{"user_id": userID}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find wishlists"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	cursor, err := r.col.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		summary.ResTime = time.Since(start).Microseconds()
+		return nil, fail(ctx, summary, logger.QUERY, err)
+	}
+	wishlists := []*Wishlist{}
+	err = cursor.All(ctx, &wishlists)
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return nil, fail(ctx, summary, logger.QUERY, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find wishlists success"), map[string]any{
+		"count": len(wishlists),
+	})
+	return wishlists, nil
+}
+
+func (r *repository) FindByID(ctx *kp.Context, userID, id string) (*Wishlist, error) {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "find_wishlist", "200", "success")
+	filter := bson.M{"_id": id, "user_id": userID}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "find wishlist"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	var wishlist Wishlist
+	err := r.col.FindOne(ctx, filter).Decode(&wishlist)
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return nil, fail(ctx, summary, logger.QUERY, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "find wishlist success"), map[string]any{
+		"Return": wishlist,
+	})
+	return &wishlist, nil
+}
+
+// update applies update to the wishlist matching filter and returns the
+// updated wishlist, or nil when nothing matched.
+func (r *repository) update(ctx *kp.Context, command string, filter, update bson.M) (*Wishlist, error) {
+	start := time.Now()
+	summary := logger.EventTag("mongo", command, "200", "success")
+	ctx.Log().Info(logger.NewDBRequest(logger.UPDATE, command), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+		"update":     update,
+	})
+
+	var wishlist Wishlist
+	err := r.col.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&wishlist)
+	summary.ResTime = time.Since(start).Microseconds()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, command+" no match"), map[string]any{
+			"filter": filter,
+		})
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fail(ctx, summary, logger.UPDATE, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.UPDATE, command+" success"), map[string]any{
+		"id":    wishlist.ID,
+		"items": len(wishlist.Items),
+	})
+	return &wishlist, nil
+}
+
+func (r *repository) Rename(ctx *kp.Context, userID, id, name string) (*Wishlist, error) {
+	wishlist, err := r.update(ctx, "rename_wishlist",
+		bson.M{"_id": id, "user_id": userID},
+		bson.M{"$set": bson.M{"name": name, "updated_at": now()}},
+	)
+	if err == nil && wishlist == nil {
+		return nil, ErrWishlistNotFound
+	}
+	return wishlist, err
+}
+
+func (r *repository) Delete(ctx *kp.Context, userID, id string) error {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "delete_wishlist", "200", "success")
+	filter := bson.M{"_id": id, "user_id": userID}
+	ctx.Log().Info(logger.NewDBRequest(logger.DELETE, "delete wishlist"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	result, err := r.col.DeleteOne(ctx, filter)
+	if err == nil && result.DeletedCount == 0 {
+		err = mongo.ErrNoDocuments
+	}
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return fail(ctx, summary, logger.DELETE, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.DELETE, "delete wishlist success"), map[string]any{
+		"id": id,
+	})
+	return nil
+}
+
+func (r *repository) Count(ctx *kp.Context, userID string) (int64, error) {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "count_wishlists", "200", "success")
+	filter := bson.M{"user_id": userID}
+	ctx.Log().Info(logger.NewDBRequest(logger.QUERY, "count wishlists"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	n, err := r.col.CountDocuments(ctx, filter)
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return 0, fail(ctx, summary, logger.QUERY, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.QUERY, "count wishlists success"), map[string]any{
+		"count": n,
+	})
+	return n, nil
+}
+
+// AddItem appends item to the wishlist unless the product is already on it
+// or the list holds MaxItems. It returns nil when the item was not added
+// for any of these reasons or the wishlist does not exist.
+func (r *repository) AddItem(ctx *kp.Context, userID, id string, item *Item) (*Wishlist, error) {
+	return r.update(ctx, "add_wishlist_item",
+		bson.M{
+			"_id":                               id,
+			"user_id":                           userID,
+			"items.product_id":                  bson.M{"$ne": item.ProductID},
+			"items." + strconv.Itoa(MaxItems-1): bson.M{"$exists": false},
+		},
+		bson.M{
+			"$push": bson.M{"items": item},
+			"$set":  bson.M{"updated_at": now()},
+		},
+	)
+}
+
+// RemoveItem takes the product off the wishlist. It returns nil when the
+// wishlist does not exist or does not hold the product.
+func (r *repository) RemoveItem(ctx *kp.Context, userID, id, productID string) (*Wishlist, error) {
+	return r.update(ctx, "remove_wishlist_item",
+		bson.M{"_id": id, "user_id": userID, "items.product_id": productID},
+		bson.M{
+			"$pull": bson.M{"items": bson.M{"product_id": productID}},
+			"$set":  bson.M{"updated_at": now()},
+		},
+	)
+}
+
+// SetItems replaces the items with the same products in another order. The
+// filter only matches while the wishlist holds exactly these products, so
+// an item added or removed meanwhile is not lost; nil is returned then.
+func (r *repository) SetItems(ctx *kp.Context, userID, id string, items []*Item) (*Wishlist, error) {
+	productIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	return r.update(ctx, "reorder_wishlist_items",
+		bson.M{
+			"_id":              id,
+			"user_id":          userID,
+			"items":            bson.M{"$size": len(items)},
+			"items.product_id": bson.M{"$all": productIDs},
+		},
+		bson.M{"$set": bson.M{"items": items, "updated_at": now()}},
+	)
+}
+
+// DeleteByUser removes every wishlist of a user.
+func (r *repository) DeleteByUser(ctx *kp.Context, userID string) (int64, error) {
+	start := time.Now()
+	summary := logger.EventTag("mongo", "delete_wishlists", "200", "success")
+	filter := bson.M{"user_id": userID}
+	ctx.Log().Info(logger.NewDBRequest(logger.DELETE, "delete wishlists"), map[string]any{
+		"collection": r.col.Name(),
+		"filter":     filter,
+	})
+
+	result, err := r.col.DeleteMany(ctx, filter)
+	summary.ResTime = time.Since(start).Microseconds()
+	if err != nil {
+		return 0, fail(ctx, summary, logger.DELETE, err)
+	}
+	ctx.Log().SetSummary(summary).Info(logger.NewDBResponse(logger.DELETE, "delete wishlists success"), map[string]any{
+		"deleted": result.DeletedCount,
+	})
+	return result.DeletedCount, nil
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
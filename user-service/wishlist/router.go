@@ -0,0 +1,21 @@
+package wishlist
+
+import (
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+)
+
+// RegisterRoutes registers the wishlist routes. Like the other routes under
+// /users/{id}/, they go in before the /users/{key}/{value} lookup.
+func RegisterRoutes(app kp.IApplication, repo Repository, users UserLookup) {
+	svc := NewService(repo, users)
+	handler := NewHandler(svc)
+
+	app.Get("/users/{id}/wishlists", handler.ListWishlists)
+	app.Post("/users/{id}/wishlists", handler.CreateWishlist)
+	app.Get("/users/{id}/wishlists/{wishlist_id}", handler.GetWishlist)
+	app.Put("/users/{id}/wishlists/{wishlist_id}", handler.RenameWishlist)
+	app.Delete("/users/{id}/wishlists/{wishlist_id}", handler.DeleteWishlist)
+	app.Post("/users/{id}/wishlists/{wishlist_id}/items", handler.AddItem)
+	app.Put("/users/{id}/wishlists/{wishlist_id}/items", handler.ReorderItems)
+	app.Delete("/users/{id}/wishlists/{wishlist_id}/items/{product_id}", handler.RemoveItem)
+}
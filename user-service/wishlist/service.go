@@ -0,0 +1,267 @@
+package wishlist
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/sing3demons/go-common-kp/kp/pkg/kp"
+	"github.com/sing3demons/go-user-service/apperror"
+)
+
+const (
+	// MaxWishlists is how many wishlists one user may keep.
+	MaxWishlists = 20
+	// MaxItems is how many products one wishlist may hold.
+	MaxItems = 100
+)
+
+type Service interface {
+	ListWishlists(ctx *kp.Context, userID string) ([]*Wishlist, error)
+	GetWishlist(ctx *kp.Context, userID, id string) (*Wishlist, error)
+	CreateWishlist(ctx *kp.Context, userID string, req WishlistRequest) (*Wishlist, error)
+	RenameWishlist(ctx *kp.Context, userID, id string, req WishlistRequest) (*Wishlist, error)
+	DeleteWishlist(ctx *kp.Context, userID, id string) error
+	AddItem(ctx *kp.Context, userID, id string, req ItemRequest) (*Wishlist, error)
+	RemoveItem(ctx *kp.Context, userID, id, productID string) (*Wishlist, error)
+	ReorderItems(ctx *kp.Context, userID, id string, req OrderRequest) (*Wishlist, error)
+}
+
+// UserLookup fails when userID is not a live user.
+type UserLookup func(ctx *kp.Context, userID string) error
+
+type service struct {
+	repo  Repository
+	users UserLookup
+}
+
+func NewService(repo Repository, users UserLookup) Service {
+	return &service{
+		repo:  repo,
+		users: users,
+	}
+}
+
+var (
+	ErrTooManyWishlists = apperror.Conflict("wishlist_limit_reached", "wishlists", "a user can keep at most 20 wishlists")
+	ErrWishlistFull     = apperror.Conflict("wishlist_full", "items", "a wishlist can hold at most 100 products")
+	ErrItemNotFound     = apperror.NotFound("wishlist_item_not_found", "the product is not on the wishlist")
+	ErrProductNotFound  = apperror.NotFound("product_not_found", "product not found")
+	ErrOrderMismatch    = apperror.Validation("wishlist_order_mismatch", "product_ids must list every product of the wishlist exactly once")
+	ErrWishlistChanged  = apperror.Conflict("wishlist_changed", "items", "the wishlist changed while it was being reordered; try again")
+)
+
+func (s *service) ListWishlists(ctx *kp.Context, userID string) ([]*Wishlist, error) {
+	if err := s.users(ctx, userID); err != nil {
+		return nil, err
+	}
+	wishlists, err := s.repo.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := enrich(ctx, wishlists...); err != nil {
+		return nil, err
+	}
+	return wishlists, nil
+}
+
+func (s *service) GetWishlist(ctx *kp.Context, userID, id string) (*Wishlist, error) {
+	if err := s.users(ctx, userID); err != nil {
+		return nil, err
+	}
+	wishlist, err := s.repo.FindByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := enrich(ctx, wishlist); err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+func (s *service) CreateWishlist(ctx *kp.Context, userID string, req WishlistRequest) (*Wishlist, error) {
+	if err := s.users(ctx, userID); err != nil {
+		return nil, err
+	}
+	n, err := s.repo.Count(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n >= MaxWishlists {
+		return nil, ErrTooManyWishlists
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, apperror.Internal(err)
+	}
+	at := now()
+	wishlist := &Wishlist{
+		ID:        id.String(),
+		UserID:    userID,
+		Name:      req.Name,
+		Items:     []*Item{},
+		CreatedAt: at,
+		UpdatedAt: at,
+	}
+	if err := s.repo.Create(ctx, wishlist); err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+func (s *service) RenameWishlist(ctx *kp.Context, userID, id string, req WishlistRequest) (*Wishlist, error) {
+	if err := s.users(ctx, userID); err != nil {
+		return nil, err
+	}
+	wishlist, err := s.repo.Rename(ctx, userID, id, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := enrich(ctx, wishlist); err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+func (s *service) DeleteWishlist(ctx *kp.Context, userID, id string) error {
+	if err := s.users(ctx, userID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, userID, id)
+}
+
+// AddItem puts a live product at the end of the wishlist. Adding a product
+// the wishlist already holds leaves it where it is.
+func (s *service) AddItem(ctx *kp.Context, userID, id string, req ItemRequest) (*Wishlist, error) {
+	if err := s.users(ctx, userID); err != nil {
+		return nil, err
+	}
+	products, err := getProducts(ctx, []string{req.ProductID})
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := products[req.ProductID]; !ok || p.DeletedAt != nil {
+		return nil, ErrProductNotFound
+	}
+
+	wishlist, err := s.repo.AddItem(ctx, userID, id, &Item{ProductID: req.ProductID, AddedAt: now()})
+	if err != nil {
+		return nil, err
+	}
+	if wishlist == nil {
+		// Not added: find out whether the list is missing, full or
+		// already holds the product.
+		wishlist, err = s.repo.FindByID(ctx, userID, id)
+		if err != nil {
+			return nil, err
+		}
+		if indexOf(wishlist.Items, req.ProductID) < 0 {
+			return nil, ErrWishlistFull
+		}
+	}
+	if err := enrich(ctx, wishlist); err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+func (s *service) RemoveItem(ctx *kp.Context, userID, id, productID string) (*Wishlist, error) {
+	if err := s.users(ctx, userID); err != nil {
+		return nil, err
+	}
+	wishlist, err := s.repo.RemoveItem(ctx, userID, id, productID)
+	if err != nil {
+		return nil, err
+	}
+	if wishlist == nil {
+		if _, err := s.repo.FindByID(ctx, userID, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrItemNotFound
+	}
+	if err := enrich(ctx, wishlist); err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+// ReorderItems puts the items in the order of req.ProductIDs, which must
+// name every product on the wishlist.
+func (s *service) ReorderItems(ctx *kp.Context, userID, id string, req OrderRequest) (*Wishlist, error) {
+	if err := s.users(ctx, userID); err != nil {
+		return nil, err
+	}
+	current, err := s.repo.FindByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.ProductIDs) != len(current.Items) {
+		return nil, ErrOrderMismatch
+	}
+	items := make([]*Item, 0, len(req.ProductIDs))
+	for _, productID := range req.ProductIDs {
+		i := indexOf(current.Items, productID)
+		if i < 0 {
+			return nil, ErrOrderMismatch
+		}
+		items = append(items, current.Items[i])
+	}
+	if len(items) == 0 {
+		return current, nil
+	}
+
+	wishlist, err := s.repo.SetItems(ctx, userID, id, items)
+	if err != nil {
+		return nil, err
+	}
+	if wishlist == nil {
+		return nil, ErrWishlistChanged
+	}
+	if err := enrich(ctx, wishlist); err != nil {
+		return nil, err
+	}
+	return wishlist, nil
+}
+
+// enrich fills in the current name and price of the items from
+// product-service, looking each product up once however many wishlists
+// hold it. Products that were deleted keep their place and are flagged.
+func enrich(ctx *kp.Context, wishlists ...*Wishlist) error {
+	var ids []string
+	seen := map[string]bool{}
+	for _, wishlist := range wishlists {
+		for _, item := range wishlist.Items {
+			if !seen[item.ProductID] {
+				seen[item.ProductID] = true
+				ids = append(ids, item.ProductID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	products, err := getProducts(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, wishlist := range wishlists {
+		for _, item := range wishlist.Items {
+			p, ok := products[item.ProductID]
+			if !ok {
+				item.Deleted = true
+				continue
+			}
+			item.Name = p.Name
+			item.Price = p.Price
+			item.Deleted = p.DeletedAt != nil
+		}
+	}
+	return nil
+}
+
+func indexOf(items []*Item, productID string) int {
+	return slices.IndexFunc(items, func(item *Item) bool {
+		return item.ProductID == productID
+	})
+}